@@ -0,0 +1,612 @@
+// Command gonetsh inspects and changes the network configuration of a Windows host through the gonetsh library,
+// printing the same parsed view as a table for humans or as JSON for scripts.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rakelkar/gonetsh/hostnet"
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+const usage = `usage: gonetsh <command> [flags]
+
+commands:
+  interfaces                          list interfaces and their addresses
+  routes list|add|del|find            manage routes
+  portproxy list|ensure|del           manage portproxy rules
+  dns show|set|dhcp                   manage the DNS servers of an interface
+  forwarding show|enable|disable      manage forwarding on an interface
+
+run gonetsh <command> [<subcommand>] -h for the flags of a command
+`
+
+// errUsage is returned for a malformed command line, the usage has already been printed
+var errUsage = errors.New("invalid arguments")
+
+// app runs one command line, the constructors are replaced in tests
+type app struct {
+	out      io.Writer
+	errOut   io.Writer
+	netsh    func() netsh.Interface
+	netroute func() (netroute.Interface, error)
+}
+
+func main() {
+	a := &app{
+		out:    os.Stdout,
+		errOut: os.Stderr,
+		netsh: func() netsh.Interface {
+			return netsh.New(nil)
+		},
+		netroute: func() (netroute.Interface, error) {
+			return netroute.NewWithOptions(netroute.Options{})
+		},
+	}
+
+	if err := a.run(os.Args[1:]); err != nil {
+		if err != errUsage {
+			fmt.Fprintf(os.Stderr, "gonetsh: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run runs a command line, asking for a command's help is not an error
+func (a *app) run(args []string) error {
+	err := a.command(args)
+	if err == flag.ErrHelp {
+		return nil
+	}
+	return err
+}
+
+func (a *app) command(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(a.errOut, usage)
+		return errUsage
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "interfaces":
+		return a.interfaces(args)
+	case "routes":
+		return a.dispatch(command, args, map[string]func([]string) error{
+			"list": a.routesList,
+			"add":  a.routesAdd,
+			"del":  a.routesDel,
+			"find": a.routesFind,
+		})
+	case "portproxy":
+		return a.dispatch(command, args, map[string]func([]string) error{
+			"list":   a.portProxyList,
+			"ensure": a.portProxyEnsure,
+			"del":    a.portProxyDel,
+		})
+	case "dns":
+		return a.dispatch(command, args, map[string]func([]string) error{
+			"show": a.dnsShow,
+			"set":  a.dnsSet,
+			"dhcp": a.dnsDHCP,
+		})
+	case "forwarding":
+		return a.dispatch(command, args, map[string]func([]string) error{
+			"show":    a.forwardingShow,
+			"enable":  a.forwardingEnable,
+			"disable": a.forwardingDisable,
+		})
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(a.out, usage)
+		return nil
+	}
+
+	fmt.Fprintf(a.errOut, "unknown command %q\n\n%v", command, usage)
+	return errUsage
+}
+
+func (a *app) dispatch(command string, args []string, subcommands map[string]func([]string) error) error {
+	var names []string
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(args) == 0 {
+		fmt.Fprintf(a.errOut, "usage: gonetsh %v %v [flags]\n", command, strings.Join(names, "|"))
+		return errUsage
+	}
+	run, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(a.errOut, "unknown subcommand %q, usage: gonetsh %v %v [flags]\n", args[0], command, strings.Join(names, "|"))
+		return errUsage
+	}
+	return run(args[1:])
+}
+
+// flags returns a flag set for a command, parse errors are reported as errUsage and -h as flag.ErrHelp
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("gonetsh "+name, flag.ContinueOnError)
+	fs.SetOutput(a.errOut)
+	return fs
+}
+
+func (a *app) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(a.errOut, "unexpected arguments %v\n", fs.Args())
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func outputFlag(fs *flag.FlagSet) *string {
+	return fs.String("o", "table", "output format, table or json")
+}
+
+// print writes v as indented JSON, or as the table rendered by table
+func (a *app) print(output string, v interface{}, table func(w io.Writer)) error {
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(a.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "table":
+		w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown output format %q, use table or json", output)
+}
+
+func required(name string, value string) error {
+	if value == "" {
+		return fmt.Errorf("-%v is required", name)
+	}
+	return nil
+}
+
+func (a *app) interfaces(args []string) error {
+	fs := a.flags("interfaces")
+	output := outputFlag(fs)
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+
+	interfaces, err := a.netsh().GetInterfaces()
+	if err != nil {
+		return err
+	}
+	return a.print(*output, interfaces, func(w io.Writer) {
+		fmt.Fprintln(w, "IDX\tNAME\tADDRESS\tGATEWAY\tDHCP\tMETRIC")
+		for _, iface := range interfaces {
+			address := iface.IpAddress
+			if address != "" {
+				address = fmt.Sprintf("%v/%v", address, iface.SubnetPrefix)
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", iface.Idx, iface.Name, address, iface.DefaultGatewayAddress, iface.DhcpEnabled, iface.InterfaceMetric)
+		}
+	})
+}
+
+// routeView is a route as printed with -o json, its destination in CIDR notation since net.IPNet has no text form
+type routeView struct {
+	LinkIndex         int
+	DestinationSubnet string
+	GatewayAddress    net.IP `json:",omitempty"`
+	RouteMetric       int
+	IfMetric          int
+	InterfaceAlias    string `json:",omitempty"`
+	AddressFamily     string `json:",omitempty"`
+	Protocol          string `json:",omitempty"`
+	PolicyStore       string `json:",omitempty"`
+}
+
+func (a *app) printRoutes(output string, routes []netroute.Route) error {
+	views := make([]routeView, len(routes))
+	for i, route := range routes {
+		views[i] = routeView{
+			LinkIndex:      route.LinkIndex,
+			GatewayAddress: route.GatewayAddress,
+			RouteMetric:    route.RouteMetric,
+			IfMetric:       route.IfMetric,
+			InterfaceAlias: route.InterfaceAlias,
+			AddressFamily:  route.AddressFamily,
+			Protocol:       route.Protocol,
+			PolicyStore:    route.PolicyStore,
+		}
+		if route.DestinationSubnet != nil {
+			views[i].DestinationSubnet = route.DestinationSubnet.String()
+		}
+	}
+	return a.print(output, views, func(w io.Writer) {
+		fmt.Fprintln(w, "IFINDEX\tDESTINATION\tNEXTHOP\tMETRIC\tPROTOCOL")
+		for _, route := range routes {
+			nextHop := "on-link"
+			if !route.IsOnLink() {
+				nextHop = route.GatewayAddress.String()
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", route.LinkIndex, route.DestinationSubnet, nextHop, route.RouteMetric, route.Protocol)
+		}
+	})
+}
+
+// routeFlags holds the flags that identify a route
+type routeFlags struct {
+	linkIndex   *int
+	destination *string
+	nextHop     *string
+}
+
+func newRouteFlags(fs *flag.FlagSet) routeFlags {
+	return routeFlags{
+		linkIndex:   fs.Int("interface", 0, "interface index"),
+		destination: fs.String("destination", "", "destination prefix, e.g. 10.244.0.0/16"),
+		nextHop:     fs.String("nexthop", "", "gateway address, empty for an on-link route"),
+	}
+}
+
+// parse validates the flags, requireAll is set for commands that need the full route key
+func (f routeFlags) parse(requireAll bool) (int, *net.IPNet, net.IP, error) {
+	var destination *net.IPNet
+	if *f.destination != "" {
+		_, subnet, err := net.ParseCIDR(*f.destination)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("invalid -destination: %v", err)
+		}
+		destination = subnet
+	}
+	var nextHop net.IP
+	if *f.nextHop != "" {
+		nextHop = net.ParseIP(*f.nextHop)
+		if nextHop == nil {
+			return 0, nil, nil, fmt.Errorf("invalid -nexthop %q", *f.nextHop)
+		}
+	}
+	if requireAll && (*f.linkIndex <= 0 || destination == nil) {
+		return 0, nil, nil, fmt.Errorf("-interface and -destination are required")
+	}
+	return *f.linkIndex, destination, nextHop, nil
+}
+
+func (a *app) routesList(args []string) error {
+	fs := a.flags("routes list")
+	output := outputFlag(fs)
+	route := newRouteFlags(fs)
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	linkIndex, destination, nextHop, err := route.parse(false)
+	if err != nil {
+		return err
+	}
+
+	nr, err := a.netroute()
+	if err != nil {
+		return err
+	}
+	defer nr.Exit()
+
+	routes, err := nr.GetNetRoutesFiltered(netroute.RouteFilter{InterfaceIndex: linkIndex, DestinationPrefix: destination, NextHop: nextHop})
+	if err != nil {
+		return err
+	}
+	return a.printRoutes(*output, routes)
+}
+
+func (a *app) routesAdd(args []string) error {
+	fs := a.flags("routes add")
+	route := newRouteFlags(fs)
+	metric := fs.Int("metric", 0, "route metric, 0 keeps the default")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	linkIndex, destination, nextHop, err := route.parse(true)
+	if err != nil {
+		return err
+	}
+
+	nr, err := a.netroute()
+	if err != nil {
+		return err
+	}
+	defer nr.Exit()
+
+	return nr.NewNetRouteMultipath(netroute.MultipathRoute{
+		DestinationSubnet: destination,
+		NextHops:          []netroute.NextHop{{LinkIndex: linkIndex, GatewayAddress: nextHop, RouteMetric: *metric}},
+	})
+}
+
+func (a *app) routesDel(args []string) error {
+	fs := a.flags("routes del")
+	route := newRouteFlags(fs)
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	linkIndex, destination, nextHop, err := route.parse(true)
+	if err != nil {
+		return err
+	}
+
+	nr, err := a.netroute()
+	if err != nil {
+		return err
+	}
+	defer nr.Exit()
+
+	return nr.RemoveNetRoute(linkIndex, destination, nextHop)
+}
+
+// routesFind lists the routes covering an address, the most specific first as the host would pick them
+func (a *app) routesFind(args []string) error {
+	fs := a.flags("routes find")
+	output := outputFlag(fs)
+	address := fs.String("ip", "", "address to look up")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	if err := required("ip", *address); err != nil {
+		return err
+	}
+	ip := net.ParseIP(*address)
+	if ip == nil {
+		return fmt.Errorf("invalid -ip %q", *address)
+	}
+
+	nr, err := a.netroute()
+	if err != nil {
+		return err
+	}
+	defer nr.Exit()
+
+	all, err := nr.GetNetRoutesFiltered(netroute.RouteFilter{})
+	if err != nil {
+		return err
+	}
+	var routes []netroute.Route
+	for _, route := range all {
+		if route.DestinationSubnet != nil && route.DestinationSubnet.Contains(ip) {
+			routes = append(routes, route)
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		iOnes, _ := routes[i].DestinationSubnet.Mask.Size()
+		jOnes, _ := routes[j].DestinationSubnet.Mask.Size()
+		if iOnes != jOnes {
+			return iOnes > jOnes
+		}
+		return routes[i].RouteMetric+routes[i].IfMetric < routes[j].RouteMetric+routes[j].IfMetric
+	})
+	return a.printRoutes(*output, routes)
+}
+
+func (a *app) portProxyList(args []string) error {
+	fs := a.flags("portproxy list")
+	output := outputFlag(fs)
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+
+	rules, err := a.netsh().GetPortProxyRules()
+	if err != nil {
+		return err
+	}
+	if rules == nil {
+		rules = []netsh.PortProxyRule{}
+	}
+	return a.print(*output, rules, func(w io.Writer) {
+		fmt.Fprintln(w, "PROTOCOL\tLISTEN\tCONNECT")
+		for _, rule := range rules {
+			fmt.Fprintf(w, "%v\t%v:%v\t%v:%v\n", rule.Protocol, rule.ListenAddress, rule.ListenPort, rule.ConnectAddress, rule.ConnectPort)
+		}
+	})
+}
+
+// portProxyFlags holds the flags that describe a portproxy rule
+type portProxyFlags struct {
+	protocol       *string
+	listenAddress  *string
+	listenPort     *int
+	connectAddress *string
+	connectPort    *int
+}
+
+func newPortProxyFlags(fs *flag.FlagSet, connect bool) portProxyFlags {
+	f := portProxyFlags{
+		protocol:      fs.String("protocol", "v4tov4", "v4tov4, v4tov6, v6tov4 or v6tov6"),
+		listenAddress: fs.String("listenaddress", "", "address to listen on, empty for every address"),
+		listenPort:    fs.Int("listenport", 0, "port to listen on"),
+	}
+	if connect {
+		f.connectAddress = fs.String("connectaddress", "", "address to forward to")
+		f.connectPort = fs.Int("connectport", 0, "port to forward to")
+	}
+	return f
+}
+
+func (f portProxyFlags) rule() netsh.PortProxyRule {
+	rule := netsh.PortProxyRule{
+		Protocol:      *f.protocol,
+		ListenAddress: *f.listenAddress,
+		ListenPort:    *f.listenPort,
+	}
+	if f.connectAddress != nil {
+		rule.ConnectAddress = *f.connectAddress
+		rule.ConnectPort = *f.connectPort
+	}
+	return rule
+}
+
+// portProxyEnsure creates the rule, or updates the rule listening on the same port, unless it is already in place
+func (a *app) portProxyEnsure(args []string) error {
+	fs := a.flags("portproxy ensure")
+	output := outputFlag(fs)
+	f := newPortProxyFlags(fs, true)
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+
+	rule := f.rule()
+	spec := &hostnet.Spec{PortProxy: []hostnet.PortProxySpec{{
+		Protocol:       rule.Protocol,
+		ListenAddress:  rule.ListenAddress,
+		ListenPort:     rule.ListenPort,
+		ConnectAddress: rule.ConnectAddress,
+		ConnectPort:    rule.ConnectPort,
+	}}}
+	plan, err := hostnet.NewReconciler(a.netsh(), nil).Reconcile(spec, false)
+	if err != nil {
+		return err
+	}
+
+	changes := plan.Changes
+	if changes == nil {
+		changes = []hostnet.Change{}
+	}
+	return a.print(*output, changes, func(w io.Writer) {
+		fmt.Fprint(w, plan.String())
+	})
+}
+
+func (a *app) portProxyDel(args []string) error {
+	fs := a.flags("portproxy del")
+	f := newPortProxyFlags(fs, false)
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+
+	results, err := a.netsh().NewBatch().DeletePortProxyRule(f.rule()).Run()
+	if err != nil {
+		return err
+	}
+	return results[0].Err
+}
+
+func (a *app) dnsShow(args []string) error {
+	fs := a.flags("dns show")
+	output := outputFlag(fs)
+	iface := fs.String("interface", "", "interface name")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	if err := required("interface", *iface); err != nil {
+		return err
+	}
+
+	config, err := a.netsh().GetDNSClientConfig(*iface)
+	if err != nil {
+		return err
+	}
+	return a.print(*output, config, func(w io.Writer) {
+		var servers []string
+		for _, server := range config.Servers {
+			servers = append(servers, server.String())
+		}
+		fmt.Fprintln(w, "INTERFACE\tDHCP\tSERVERS\tSUFFIX\tREGISTER")
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", config.Interface, config.Dhcp, strings.Join(servers, ","), config.ConnectionSuffix, config.RegisterWith)
+	})
+}
+
+func (a *app) dnsSet(args []string) error {
+	fs := a.flags("dns set")
+	iface := fs.String("interface", "", "interface name")
+	list := fs.String("servers", "", "comma separated DNS servers, the first is the primary")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	if err := required("interface", *iface); err != nil {
+		return err
+	}
+	if err := required("servers", *list); err != nil {
+		return err
+	}
+
+	var servers []net.IP
+	for _, server := range strings.Split(*list, ",") {
+		ip := net.ParseIP(strings.TrimSpace(server))
+		if ip == nil {
+			return fmt.Errorf("invalid dns server %q", server)
+		}
+		servers = append(servers, ip)
+	}
+	return a.netsh().SetDNSServers(*iface, servers)
+}
+
+func (a *app) dnsDHCP(args []string) error {
+	fs := a.flags("dns dhcp")
+	iface := fs.String("interface", "", "interface name")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	if err := required("interface", *iface); err != nil {
+		return err
+	}
+
+	return a.netsh().ResetDNSToDHCP(*iface)
+}
+
+func (a *app) forwardingShow(args []string) error {
+	fs := a.flags("forwarding show")
+	output := outputFlag(fs)
+	iface := fs.String("interface", "", "interface name or index")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	if err := required("interface", *iface); err != nil {
+		return err
+	}
+
+	enabled, err := a.netsh().GetForwarding(*iface)
+	if err != nil {
+		return err
+	}
+	state := struct {
+		Interface  string
+		Forwarding bool
+	}{*iface, enabled}
+	return a.print(*output, state, func(w io.Writer) {
+		fmt.Fprintln(w, "INTERFACE\tFORWARDING")
+		fmt.Fprintf(w, "%v\t%v\n", state.Interface, state.Forwarding)
+	})
+}
+
+func (a *app) forwardingEnable(args []string) error {
+	return a.setForwarding("forwarding enable", args, true)
+}
+
+func (a *app) forwardingDisable(args []string) error {
+	return a.setForwarding("forwarding disable", args, false)
+}
+
+func (a *app) setForwarding(name string, args []string, enabled bool) error {
+	fs := a.flags(name)
+	iface := fs.String("interface", "", "interface name or index")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	if err := required("interface", *iface); err != nil {
+		return err
+	}
+
+	if enabled {
+		return a.netsh().EnableForwarding(*iface)
+	}
+	return a.netsh().DisableForwarding(*iface)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	fakenetroute "github.com/rakelkar/gonetsh/netroute/testing"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+	fakenetsh "github.com/rakelkar/gonetsh/netsh/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestApp() (*app, *bytes.Buffer, *fakenetsh.RecordingNetsh, *fakenetroute.RecordingNetroute) {
+	parse := func(cidr string) *net.IPNet {
+		_, subnet, _ := net.ParseCIDR(cidr)
+		return subnet
+	}
+	nsh := fakenetsh.NewRecording(nil)
+	nsh.Interfaces = []netsh.Ipv4Interface{
+		{Idx: 4, Name: "Ethernet", IpAddress: "10.0.0.4", SubnetPrefix: 24, DefaultGatewayAddress: "10.0.0.1", DhcpEnabled: true, InterfaceMetric: 15},
+		{Idx: 12, Name: "vEthernet (nat)", IpAddress: "172.23.0.1", SubnetPrefix: 20, InterfaceMetric: 5000},
+	}
+	nsh.PortProxy = []netsh.PortProxyRule{{Protocol: "v4tov4", ListenAddress: "*", ListenPort: 8080, ConnectAddress: "10.0.0.5", ConnectPort: 80}}
+	nsh.DNS["Ethernet"] = netsh.DNSClientConfig{Servers: []net.IP{net.ParseIP("10.96.0.10"), net.ParseIP("1.1.1.1")}, RegisterWith: "Primary only"}
+	nsh.Forwarding["Ethernet"] = true
+	nr := fakenetroute.NewRecording(nil)
+	nr.Routes = []netroute.Route{
+		{LinkIndex: 4, DestinationSubnet: parse("0.0.0.0/0"), GatewayAddress: net.ParseIP("10.0.0.1"), RouteMetric: 0, IfMetric: 15},
+		{LinkIndex: 12, DestinationSubnet: parse("10.244.0.0/16"), GatewayAddress: net.ParseIP("172.23.0.2"), RouteMetric: 256},
+		{LinkIndex: 12, DestinationSubnet: parse("10.244.1.0/24"), RouteMetric: 256, Protocol: "Local"},
+	}
+	out := &bytes.Buffer{}
+	a := &app{
+		out:    out,
+		errOut: &bytes.Buffer{},
+		netsh: func() netsh.Interface {
+			return nsh
+		},
+		netroute: func() (netroute.Interface, error) {
+			return nr, nil
+		},
+	}
+	return a, out, nsh, nr
+}
+
+func TestInterfaces(t *testing.T) {
+	a, out, _, _ := newTestApp()
+
+	assert.NoError(t, a.run([]string{"interfaces"}))
+	assert.Equal(t, "IDX  NAME             ADDRESS        GATEWAY   DHCP   METRIC\n"+
+		"4    Ethernet         10.0.0.4/24    10.0.0.1  true   15\n"+
+		"12   vEthernet (nat)  172.23.0.1/20            false  5000\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, a.run([]string{"interfaces", "-o", "json"}))
+	assert.Contains(t, out.String(), `"Name": "vEthernet (nat)"`)
+
+	assert.Error(t, a.run([]string{"interfaces", "-o", "yaml"}))
+}
+
+func TestRoutes(t *testing.T) {
+	a, out, _, nr := newTestApp()
+
+	assert.NoError(t, a.run([]string{"routes", "list", "-interface", "12"}))
+	assert.Equal(t, "IFINDEX  DESTINATION    NEXTHOP     METRIC  PROTOCOL\n"+
+		"12       10.244.0.0/16  172.23.0.2  256     \n"+
+		"12       10.244.1.0/24  on-link     256     Local\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, a.run([]string{"routes", "find", "-ip", "10.244.1.7", "-o", "json"}))
+	assert.JSONEq(t, `[
+		{"LinkIndex":12,"DestinationSubnet":"10.244.1.0/24","RouteMetric":256,"IfMetric":0,"Protocol":"Local"},
+		{"LinkIndex":12,"DestinationSubnet":"10.244.0.0/16","GatewayAddress":"172.23.0.2","RouteMetric":256,"IfMetric":0},
+		{"LinkIndex":4,"DestinationSubnet":"0.0.0.0/0","GatewayAddress":"10.0.0.1","RouteMetric":0,"IfMetric":15}
+	]`, out.String())
+
+	assert.NoError(t, a.run([]string{"routes", "add", "-interface", "12", "-destination", "10.96.0.0/12", "-metric", "10"}))
+	assert.NoError(t, a.run([]string{"routes", "del", "-interface", "12", "-destination", "10.96.0.0/12", "-nexthop", "172.23.0.2"}))
+	assert.Equal(t, []string{
+		"add route 12 10.96.0.0/12 <nil> metric 10",
+		"remove route 12 10.96.0.0/12 172.23.0.2",
+	}, *nr.Calls)
+
+	assert.Error(t, a.run([]string{"routes", "add", "-destination", "10.96.0.0/12"}))
+	assert.Error(t, a.run([]string{"routes", "find"}))
+	assert.Equal(t, errUsage, a.run([]string{"routes", "flush"}))
+}
+
+func TestPortProxy(t *testing.T) {
+	a, out, nsh, _ := newTestApp()
+
+	assert.NoError(t, a.run([]string{"portproxy", "list"}))
+	assert.Equal(t, "PROTOCOL  LISTEN  CONNECT\nv4tov4    *:8080  10.0.0.5:80\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, a.run([]string{"portproxy", "ensure", "-listenport", "8080", "-connectaddress", "10.0.0.5", "-connectport", "80"}))
+	assert.Equal(t, "no drift\n", out.String())
+	assert.Empty(t, *nsh.Calls)
+
+	deleteLine := "interface portproxy delete v4tov4 listenport=8080"
+	addLine := "interface portproxy add v4tov4 listenport=8080 connectport=80 connectaddress=10.0.0.6"
+	nsh.Fail[addLine] = errors.New("The parameter is incorrect.")
+	err := a.run([]string{"portproxy", "ensure", "-listenport", "8080", "-connectaddress", "10.0.0.6", "-connectport", "80"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), netsh.ErrNotApplied.Error())
+	assert.Equal(t, []string{deleteLine, addLine}, *nsh.Calls)
+
+	delete(nsh.Fail, addLine)
+	assert.NoError(t, a.run([]string{"portproxy", "ensure", "-listenport", "8080", "-connectaddress", "10.0.0.6", "-connectport", "80"}))
+	assert.Equal(t, []netsh.PortProxyRule{{Protocol: "v4tov4", ListenAddress: "*", ListenPort: 8080, ConnectAddress: "10.0.0.6", ConnectPort: 80}}, nsh.PortProxy)
+
+	nsh.Fail[deleteLine] = errors.New("The system cannot find the file specified.")
+	assert.Equal(t, netsh.ErrNotApplied, a.run([]string{"portproxy", "del", "-listenport", "8080"}))
+
+	delete(nsh.Fail, deleteLine)
+	assert.NoError(t, a.run([]string{"portproxy", "del", "-listenport", "8080"}))
+	assert.Empty(t, nsh.PortProxy)
+}
+
+func TestDNSAndForwarding(t *testing.T) {
+	a, out, nsh, _ := newTestApp()
+
+	assert.NoError(t, a.run([]string{"dns", "show", "-interface", "Ethernet"}))
+	assert.Equal(t, "INTERFACE  DHCP   SERVERS             SUFFIX  REGISTER\n"+
+		"Ethernet   false  10.96.0.10,1.1.1.1          Primary only\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, a.run([]string{"forwarding", "show", "-interface", "Ethernet", "-o", "json"}))
+	assert.JSONEq(t, `{"Interface":"Ethernet","Forwarding":true}`, out.String())
+
+	assert.NoError(t, a.run([]string{"dns", "set", "-interface", "Ethernet", "-servers", "10.96.0.10, 1.1.1.1"}))
+	assert.NoError(t, a.run([]string{"forwarding", "disable", "-interface", "Ethernet"}))
+	assert.Equal(t, []string{"set dns Ethernet [10.96.0.10 1.1.1.1]", "disable forwarding Ethernet"}, *nsh.Calls)
+
+	assert.Error(t, a.run([]string{"dns", "set", "-interface", "Ethernet", "-servers", "dns.example.com"}))
+	assert.Error(t, a.run([]string{"forwarding", "enable"}))
+	assert.NoError(t, a.run([]string{"forwarding", "enable", "-h"}))
+	assert.Equal(t, errUsage, a.run([]string{"forwarding", "enable", "-bogus"}))
+	assert.Equal(t, errUsage, a.run(nil))
+	assert.Equal(t, errUsage, a.run([]string{"firewall"}))
+}
@@ -0,0 +1,160 @@
+package cmdline
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNameLength bounds interface aliases and rule names, Windows caps interface aliases at 256 characters
+const MaxNameLength = 256
+
+var (
+	// wordPattern matches keyword arguments such as address families, protocols and DNS record types
+	wordPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	// hostPattern matches a DNS host name
+	hostPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*\.?$`)
+	// portListPattern matches a port, a range or a comma separated list of them
+	portListPattern = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+)
+
+// Name validates an interface alias, a firewall rule name or a NAT name. Spaces are allowed, double quotes and
+// control characters are not since netsh cannot express them and they would end an interactive command.
+func Name(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty name")
+	}
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("invalid name %q: not valid utf-8", name)
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return fmt.Errorf("invalid name %q: longer than %v characters", name, MaxNameLength)
+	}
+	if strings.ContainsRune(name, '"') {
+		return fmt.Errorf("invalid name %q: contains a double quote", name)
+	}
+	if err := printable(name); err != nil {
+		return fmt.Errorf("invalid name %q: %v", name, err)
+	}
+	return nil
+}
+
+// Text validates free text such as a name to resolve, anything printable is allowed
+func Text(text string) error {
+	if text == "" {
+		return fmt.Errorf("empty text")
+	}
+	if !utf8.ValidString(text) {
+		return fmt.Errorf("invalid text %q: not valid utf-8", text)
+	}
+	if err := printable(text); err != nil {
+		return fmt.Errorf("invalid text %q: %v", text, err)
+	}
+	return nil
+}
+
+// Word validates a keyword argument such as an address family, a protocol or a DNS record type
+func Word(word string) error {
+	if !wordPattern.MatchString(word) {
+		return fmt.Errorf("invalid keyword %q", word)
+	}
+	return nil
+}
+
+// IPv4 validates an IPv4 address
+func IPv4(ip net.IP) error {
+	if ip.To4() == nil {
+		return fmt.Errorf("invalid ipv4 address %v", ip)
+	}
+	return nil
+}
+
+// IP validates an IPv4 or IPv6 address
+func IP(ip net.IP) error {
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		return fmt.Errorf("invalid ip address %v", ip)
+	}
+	return nil
+}
+
+// CIDR validates a prefix, its address must be a valid address of the mask's family
+func CIDR(prefix *net.IPNet) error {
+	if prefix == nil {
+		return fmt.Errorf("no prefix given")
+	}
+	ones, bits := prefix.Mask.Size()
+	if bits == 0 {
+		return fmt.Errorf("invalid prefix %v: non canonical mask", prefix)
+	}
+	if bits == 8*net.IPv4len && prefix.IP.To4() == nil || bits == 8*net.IPv6len && len(prefix.IP) != net.IPv6len {
+		return fmt.Errorf("invalid prefix %v: address does not match the mask", prefix)
+	}
+	if ones > bits {
+		return fmt.Errorf("invalid prefix %v", prefix)
+	}
+	return nil
+}
+
+// Port validates a TCP or UDP port
+func Port(port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port %v", port)
+	}
+	return nil
+}
+
+// PortList validates a port, a range or a comma separated list of them, as taken by firewall rules
+func PortList(ports string) error {
+	if !portListPattern.MatchString(ports) {
+		return fmt.Errorf("invalid port list %q", ports)
+	}
+	return nil
+}
+
+// Host validates a DNS host name or domain suffix
+func Host(host string) error {
+	if !hostPattern.MatchString(host) {
+		return fmt.Errorf("invalid host name %q", host)
+	}
+	return nil
+}
+
+// Address validates an IP address or a host name given as text, as taken by portproxy rules
+func Address(address string) error {
+	if net.ParseIP(address) != nil || Host(address) == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid address %q", address)
+}
+
+// AddressList validates an address, a subnet, a range or a comma separated list of them, as taken by firewall
+// rules, or one of their keywords such as LocalSubnet
+func AddressList(addresses string) error {
+	for _, address := range strings.Split(addresses, ",") {
+		if net.ParseIP(address) != nil || wordPattern.MatchString(address) {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(address); err == nil {
+			continue
+		}
+		if bounds := strings.Split(address, "-"); len(bounds) == 2 && net.ParseIP(bounds[0]) != nil && net.ParseIP(bounds[1]) != nil {
+			continue
+		}
+		return fmt.Errorf("invalid address list %q", addresses)
+	}
+	return nil
+}
+
+// printable rejects control characters and unicode line separators, a line break would end an interactive netsh
+// command or a script line
+func printable(s string) error {
+	for _, r := range s {
+		if unicode.IsControl(r) || r == '\u2028' || r == '\u2029' {
+			return fmt.Errorf("contains control character %U", r)
+		}
+	}
+	return nil
+}
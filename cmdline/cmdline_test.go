@@ -0,0 +1,94 @@
+package cmdline
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestName(t *testing.T) {
+	for _, name := range []string{"Ethernet", "vEthernet (nat)", "Wi-Fi 2", "O'Brien", "7", strings.Repeat("a", MaxNameLength)} {
+		assert.NoError(t, Name(name), name)
+	}
+	for _, name := range []string{"", `Ethernet" for=dis "`, "Ethernet\r\nreset", "Ethernet\x00", "Ethernet\u2028reset", "\xff", strings.Repeat("a", MaxNameLength+1)} {
+		assert.Error(t, Name(name), name)
+	}
+}
+
+func TestText(t *testing.T) {
+	assert.NoError(t, Text(`it's "quoted" $(text)`))
+	assert.Error(t, Text(""))
+	assert.Error(t, Text("line\nbreak"))
+}
+
+func TestWord(t *testing.T) {
+	assert.NoError(t, Word("IPv4"))
+	assert.NoError(t, Word("ActiveStore"))
+	assert.Error(t, Word(""))
+	assert.Error(t, Word("TCP; Remove-Item"))
+	assert.Error(t, Word("in out"))
+}
+
+func TestIP(t *testing.T) {
+	assert.NoError(t, IPv4(net.ParseIP("10.0.0.1")))
+	assert.Error(t, IPv4(net.ParseIP("fd00::1")))
+	assert.Error(t, IPv4(nil))
+
+	assert.NoError(t, IP(net.ParseIP("10.0.0.1")))
+	assert.NoError(t, IP(net.ParseIP("fd00::1")))
+	assert.NoError(t, IP(net.ParseIP("10.0.0.1").To4()))
+	assert.Error(t, IP(nil))
+	assert.Error(t, IP(net.IP{1, 2, 3}))
+}
+
+func TestCIDR(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("10.244.0.0/16")
+	_, v6, _ := net.ParseCIDR("fd00::/64")
+	assert.NoError(t, CIDR(v4))
+	assert.NoError(t, CIDR(v6))
+
+	assert.Error(t, CIDR(nil))
+	assert.Error(t, CIDR(&net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.IPMask{255, 0, 255, 0}}))
+	assert.Error(t, CIDR(&net.IPNet{IP: net.ParseIP("fd00::"), Mask: net.CIDRMask(8, 32)}))
+	assert.Error(t, CIDR(&net.IPNet{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 128)}))
+}
+
+func TestPort(t *testing.T) {
+	assert.NoError(t, Port(1))
+	assert.NoError(t, Port(65535))
+	assert.Error(t, Port(0))
+	assert.Error(t, Port(65536))
+
+	assert.NoError(t, PortList("80"))
+	assert.NoError(t, PortList("80,443,8000-8080"))
+	assert.Error(t, PortList(""))
+	assert.Error(t, PortList("80 protocol=any"))
+	assert.Error(t, PortList("80,"))
+}
+
+func TestHost(t *testing.T) {
+	for _, host := range []string{"localhost", "corp.contoso.com", "kubernetes.default.svc.", "a-1.b"} {
+		assert.NoError(t, Host(host), host)
+	}
+	for _, host := range []string{"", "10.0.0.1 connectport=22", "-host", "host-", "host_name", "a..b", "x'; Remove-Item '"} {
+		assert.Error(t, Host(host), host)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	for _, address := range []string{"10.0.0.1", "fd00::1", "localhost", "kubernetes.default.svc."} {
+		assert.NoError(t, Address(address), address)
+	}
+	for _, address := range []string{"", "10.0.0.1 connectport=22", "-host", "host_name"} {
+		assert.Error(t, Address(address), address)
+	}
+
+	for _, addresses := range []string{"10.0.0.1", "10.0.0.0/8,fd00::/64", "10.0.0.1-10.0.0.9", "LocalSubnet", "10.0.0.1,DHCP"} {
+		assert.NoError(t, AddressList(addresses), addresses)
+	}
+	for _, addresses := range []string{"", "10.0.0.1,", "10.0.0.1 action=allow", "10.0.0.1-"} {
+		assert.Error(t, AddressList(addresses), addresses)
+	}
+}
@@ -0,0 +1,68 @@
+//go:build go1.18
+// +build go1.18
+
+package cmdline
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzPowerShellQuote(f *testing.F) {
+	for _, seed := range []string{"", "Ethernet", "O'Brien", "nat'; Remove-NetNat -Confirm:$false; '", "a\u2019b\u2018", "'''", "$(x) `n \"y\""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			t.Skip()
+		}
+		literal := PowerShellQuote(s)
+		unquoted, rest, ok := unquotePowerShell(literal)
+		if !ok || rest != "" {
+			t.Fatalf("%q escapes its literal %v, rest %q", s, literal, rest)
+		}
+		if unquoted != s {
+			t.Fatalf("%v reads back as %q, want %q", literal, unquoted, s)
+		}
+	})
+}
+
+func FuzzNetshLine(f *testing.F) {
+	for _, seed := range []string{"Ethernet", "vEthernet (nat)", " lead", "a=b c", "=x y", "Ethernet\r\nreset", `x" for=dis "`} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		args := []string{"interface", "ipv4", "add", "address", "name=" + name, name}
+		line, err := NetshLine(args)
+		if err != nil {
+			return
+		}
+		if strings.ContainsAny(line, "\r\n\x00") {
+			t.Fatalf("line %q for %q breaks the command", line, name)
+		}
+		if Name(name) != nil {
+			return
+		}
+		if got := splitNetshLine(line); strings.Join(got, "\x00") != strings.Join(args, "\x00") {
+			t.Fatalf("line %q tokenizes to %q, want %q", line, got, args)
+		}
+	})
+}
+
+func FuzzName(f *testing.F) {
+	for _, seed := range []string{"Ethernet", "vEthernet (nat)", "", "\"", "a b", "\xff"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		if Name(name) != nil {
+			return
+		}
+		if !utf8.ValidString(name) || strings.ContainsAny(name, "\"\r\n\x00") {
+			t.Fatalf("accepted name %q", name)
+		}
+		if _, err := NetshLine([]string{name}); err != nil {
+			t.Fatalf("accepted name %q is refused by NetshLine: %v", name, err)
+		}
+	})
+}
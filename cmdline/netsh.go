@@ -0,0 +1,30 @@
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NetshLine joins netsh args into a line for the interactive prompt or a script run with `netsh -f`. Args with
+// spaces are quoted, the value of a key=value arg on its own, args that are already quoted are kept as is. Args
+// with control characters are rejected, they would inject further commands.
+func NetshLine(args []string) (string, error) {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if err := printable(arg); err != nil {
+			return "", fmt.Errorf("invalid netsh argument %q: %v", arg, err)
+		}
+		quoted = append(quoted, netshQuote(arg))
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+func netshQuote(arg string) string {
+	if !strings.ContainsAny(arg, " \t") || strings.ContainsRune(arg, '"') {
+		return arg
+	}
+	if i := strings.IndexByte(arg, '='); i > 0 && wordPattern.MatchString(arg[:i]) {
+		return arg[:i+1] + `"` + arg[i+1:] + `"`
+	}
+	return `"` + arg + `"`
+}
@@ -0,0 +1,56 @@
+package cmdline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// splitNetshLine tokenizes a line the way netsh does, quotes group spaces and are dropped
+func splitNetshLine(line string) []string {
+	var args []string
+	var current strings.Builder
+	inArg, quoted := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+			}
+			inArg = false
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+func TestNetshLine(t *testing.T) {
+	line, err := NetshLine([]string{"int", "ipv4", "set", "int", "vEthernet (nat)", "for=en"})
+	assert.NoError(t, err)
+	assert.Equal(t, `int ipv4 set int "vEthernet (nat)" for=en`, line)
+
+	line, err = NetshLine([]string{"interface", "ipv4", "add", "address", "name=vEthernet (nat)", "address=10.0.0.5"})
+	assert.NoError(t, err)
+	assert.Equal(t, `interface ipv4 add address name="vEthernet (nat)" address=10.0.0.5`, line)
+	assert.Equal(t, []string{"interface", "ipv4", "add", "address", "name=vEthernet (nat)", "address=10.0.0.5"}, splitNetshLine(line))
+
+	// args quoted by the caller are kept
+	line, err = NetshLine([]string{"int", "ipv4", "set", "int", `"Wi-Fi 2"`, "for=en"})
+	assert.NoError(t, err)
+	assert.Equal(t, `int ipv4 set int "Wi-Fi 2" for=en`, line)
+
+	_, err = NetshLine([]string{"int", "ipv4", "set", "int", "Ethernet\r\nreset", "for=en"})
+	assert.Error(t, err)
+	_, err = NetshLine([]string{"int", "ipv4", "set", "int", "Ethernet\nreset"})
+	assert.Error(t, err)
+}
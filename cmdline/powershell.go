@@ -0,0 +1,22 @@
+package cmdline
+
+import (
+	"strings"
+)
+
+// powershellQuotes are the characters powershell accepts as single quotes, each is escaped by doubling it
+const powershellQuotes = "'\u2018\u2019\u201a\u201b"
+
+// PowerShellQuote renders s as a single quoted powershell string literal, nothing in it is expanded or run
+func PowerShellQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		if strings.ContainsRune(powershellQuotes, r) {
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package cmdline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unquotePowerShell parses a single quoted literal the way powershell does, returning the string and what follows
+// the closing quote
+func unquotePowerShell(literal string) (string, string, bool) {
+	runes := []rune(literal)
+	if len(runes) == 0 || !strings.ContainsRune(powershellQuotes, runes[0]) {
+		return "", "", false
+	}
+	var s strings.Builder
+	for i := 1; i < len(runes); i++ {
+		if !strings.ContainsRune(powershellQuotes, runes[i]) {
+			s.WriteRune(runes[i])
+			continue
+		}
+		// any two single quotes in a row are one escaped quote, the second one is kept
+		if i+1 < len(runes) && strings.ContainsRune(powershellQuotes, runes[i+1]) {
+			s.WriteRune(runes[i+1])
+			i++
+			continue
+		}
+		return s.String(), string(runes[i+1:]), true
+	}
+	return "", "", false
+}
+
+func TestPowerShellQuote(t *testing.T) {
+	assert.Equal(t, "'Ethernet'", PowerShellQuote("Ethernet"))
+	assert.Equal(t, "''", PowerShellQuote(""))
+	assert.Equal(t, "'O''Brien'", PowerShellQuote("O'Brien"))
+	assert.Equal(t, "'$(Remove-Item C:\\) \"x\"'", PowerShellQuote("$(Remove-Item C:\\) \"x\""))
+	assert.Equal(t, "'a\u2019\u2019; Remove-NetNat; \u2018\u2018'", PowerShellQuote("a\u2019; Remove-NetNat; \u2018"))
+
+	for _, s := range []string{"nat'; Remove-NetNat -Confirm:$false; '", "a\u201b'\u201a", "'''"} {
+		unquoted, rest, ok := unquotePowerShell(PowerShellQuote(s))
+		assert.True(t, ok, s)
+		assert.Equal(t, s, unquoted)
+		assert.Equal(t, "", rest)
+	}
+}
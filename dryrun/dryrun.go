@@ -0,0 +1,64 @@
+package dryrun
+
+import (
+	"strings"
+	"sync"
+)
+
+// Entry is a mutation that was recorded instead of being run
+type Entry struct {
+	// Package is the package that would have run the command, netsh or netroute
+	Package string
+	// Operation is the Interface method that issued the command, e.g. EnableForwarding
+	Operation string
+	// Args are the arguments of the method rendered as text
+	Args []string
+	// Command is the netsh command line or powershell script that would have run
+	Command string
+}
+
+// Recorder collects the mutations of netsh and netroute runners in dry-run mode. Share one Recorder between both
+// to preview a whole reconcile pass in order. It is goroutine-safe.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewRecorder returns an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record appends an entry
+func (r *Recorder) Record(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entry)
+}
+
+// Entries returns the recorded entries in order
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Entry(nil), r.entries...)
+}
+
+// Reset drops the recorded entries
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = nil
+}
+
+// String renders the recorded commands, one per line
+func (r *Recorder) String() string {
+	var commands strings.Builder
+	for _, entry := range r.Entries() {
+		commands.WriteString(entry.Command)
+		commands.WriteString("\n")
+	}
+	return commands.String()
+}
@@ -0,0 +1,37 @@
+package dryrun
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	assert.Empty(t, r.Entries())
+	assert.Equal(t, "", r.String())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Record(Entry{Package: "netsh", Operation: "EnableForwarding", Args: []string{"Ethernet"}, Command: "netsh int ipv4 set int Ethernet for=en"})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, len(r.Entries()))
+
+	r.Reset()
+	r.Record(Entry{Package: "netsh", Operation: "EnableForwarding", Args: []string{"Ethernet"}, Command: "netsh int ipv4 set int Ethernet for=en"})
+	r.Record(Entry{Package: "netroute", Operation: "ClearDNSCache", Command: "clear-dnsclientcache"})
+
+	entries := r.Entries()
+	assert.Equal(t, "EnableForwarding", entries[0].Operation)
+	assert.Equal(t, "netsh int ipv4 set int Ethernet for=en\nclear-dnsclientcache\n", r.String())
+
+	// the returned slice is a copy
+	entries[0].Operation = "changed"
+	assert.Equal(t, "EnableForwarding", r.Entries()[0].Operation)
+}
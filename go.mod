@@ -4,6 +4,12 @@ go 1.15
 
 require (
 	github.com/antoninbas/go-powershell v0.1.0
-	github.com/stretchr/testify v1.6.1
+	github.com/go-logr/logr v1.2.4
+	github.com/prometheus/client_golang v1.12.2
+	github.com/stretchr/testify v1.8.2
+	go.opentelemetry.io/otel v1.14.0
+	go.opentelemetry.io/otel/sdk v1.14.0
+	go.opentelemetry.io/otel/trace v1.14.0
+	gopkg.in/yaml.v3 v3.0.1
 	k8s.io/utils v0.0.0-20200410111917-5770800c2500
 )
@@ -0,0 +1,110 @@
+package hostnet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resource is the kind of host state a Change touches, resources are applied in the order declared here
+type Resource int
+
+const (
+	ResourceForwarding Resource = iota
+	ResourceAddress
+	ResourceDNS
+	ResourceRoute
+	ResourcePortProxy
+	ResourceFirewall
+)
+
+// Action is what a Change does to its resource
+type Action int
+
+const (
+	ActionCreate Action = iota
+	ActionUpdate
+)
+
+// Change is one difference between the spec and the host, and how to remove it
+type Change struct {
+	Resource Resource
+	Action   Action
+	// Target identifies the changed object, e.g. "Ethernet 10.0.0.5/24"
+	Target string
+	// Current and Desired describe the state before and after the change, Current is empty for a create
+	Current string
+	Desired string
+	// Applied is set once Apply made the change
+	Applied bool
+
+	apply func() error
+}
+
+// Plan is the ordered list of changes that converge the host to a spec, it is empty if there is no drift
+type Plan struct {
+	Changes []Change
+}
+
+// InSync returns true if the host already matches the spec
+func (plan *Plan) InSync() bool {
+	return len(plan.Changes) == 0
+}
+
+// String renders the plan as a drift report, one change per line
+func (plan *Plan) String() string {
+	if plan.InSync() {
+		return "no drift\n"
+	}
+
+	var report strings.Builder
+	for _, change := range plan.Changes {
+		report.WriteString(change.String())
+		report.WriteString("\n")
+	}
+	return report.String()
+}
+
+func (change Change) String() string {
+	if change.Action == ActionCreate {
+		return fmt.Sprintf("%v %v %v: %v", change.Action, change.Resource, change.Target, change.Desired)
+	}
+	return fmt.Sprintf("%v %v %v: %v -> %v", change.Action, change.Resource, change.Target, change.Current, change.Desired)
+}
+
+func (resource Resource) String() string {
+	switch resource {
+	case ResourceForwarding:
+		return "forwarding"
+	case ResourceAddress:
+		return "address"
+	case ResourceDNS:
+		return "dns"
+	case ResourceRoute:
+		return "route"
+	case ResourcePortProxy:
+		return "portproxy"
+	case ResourceFirewall:
+		return "firewall"
+	}
+	return fmt.Sprintf("Resource(%d)", int(resource))
+}
+
+// MarshalText renders the resource by name in JSON reports
+func (resource Resource) MarshalText() ([]byte, error) {
+	return []byte(resource.String()), nil
+}
+
+// MarshalText renders the action by name in JSON reports
+func (action Action) MarshalText() ([]byte, error) {
+	return []byte(action.String()), nil
+}
+
+func (action Action) String() string {
+	switch action {
+	case ActionCreate:
+		return "create"
+	case ActionUpdate:
+		return "update"
+	}
+	return fmt.Sprintf("Action(%d)", int(action))
+}
@@ -0,0 +1,27 @@
+package hostnet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanString(t *testing.T) {
+	plan := &Plan{}
+	assert.True(t, plan.InSync())
+	assert.Equal(t, "no drift\n", plan.String())
+
+	plan.Changes = []Change{
+		{Resource: ResourceAddress, Action: ActionCreate, Target: "Ethernet", Desired: "10.0.0.5/24"},
+		{Resource: ResourceForwarding, Action: ActionUpdate, Target: "Ethernet", Current: "disabled", Desired: "enabled"},
+	}
+	assert.False(t, plan.InSync())
+	assert.Equal(t, "create address Ethernet: 10.0.0.5/24\nupdate forwarding Ethernet: disabled -> enabled\n", plan.String())
+	assert.Equal(t, "Resource(9)", Resource(9).String())
+	assert.Equal(t, "Action(9)", Action(9).String())
+
+	data, err := json.Marshal(plan.Changes[1])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Resource":"forwarding","Action":"update","Target":"Ethernet","Current":"disabled","Desired":"enabled","Applied":false}`, string(data))
+}
@@ -0,0 +1,429 @@
+package hostnet
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+// Reconciler converges a host to a Spec, reading and changing it through netsh and netroute
+type Reconciler struct {
+	netsh    netsh.Interface
+	netroute netroute.Interface
+}
+
+// NewReconciler returns a Reconciler for the host behind nsh and nr
+func NewReconciler(nsh netsh.Interface, nr netroute.Interface) *Reconciler {
+	return &Reconciler{
+		netsh:    nsh,
+		netroute: nr,
+	}
+}
+
+// Reconcile plans the changes needed to reach spec and applies them unless checkOnly is set. The returned plan
+// doubles as the drift report, its Applied flags tell how far an interrupted apply got.
+func (r *Reconciler) Reconcile(spec *Spec, checkOnly bool) (*Plan, error) {
+	plan, err := r.Plan(spec)
+	if err != nil {
+		return nil, err
+	}
+	if checkOnly {
+		return plan, nil
+	}
+	return plan, r.Apply(plan)
+}
+
+// Plan reads the current host state and returns the changes needed to reach spec, in dependency order:
+// forwarding, addresses, DNS, routes, portproxy rules and firewall rules.
+func (r *Reconciler) Plan(spec *Spec) (*Plan, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	for _, iface := range spec.Interfaces {
+		if err := r.planInterface(plan, iface); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.planRoutes(plan, spec.Routes); err != nil {
+		return nil, err
+	}
+	if err := r.planPortProxy(plan, spec.PortProxy); err != nil {
+		return nil, err
+	}
+	if err := r.planFirewall(plan, spec.Firewall); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool { return plan.Changes[i].Resource < plan.Changes[j].Resource })
+	return plan, nil
+}
+
+// Apply makes the changes of plan in order, stopping at the first failure
+func (r *Reconciler) Apply(plan *Plan) error {
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		if change.Applied {
+			continue
+		}
+		if err := change.apply(); err != nil {
+			return fmt.Errorf("failed to %v: %v", change, err)
+		}
+		change.Applied = true
+	}
+	return nil
+}
+
+func (r *Reconciler) planInterface(plan *Plan, iface InterfaceSpec) error {
+	name := iface.Name
+
+	if iface.Forwarding != nil {
+		enabled, err := r.netsh.GetForwarding(name)
+		if err != nil {
+			return err
+		}
+		if enabled != *iface.Forwarding {
+			desired := *iface.Forwarding
+			plan.Changes = append(plan.Changes, Change{
+				Resource: ResourceForwarding,
+				Action:   ActionUpdate,
+				Target:   name,
+				Current:  enabledString(enabled),
+				Desired:  enabledString(desired),
+				apply: func() error {
+					if desired {
+						return r.netsh.EnableForwarding(name)
+					}
+					return r.netsh.DisableForwarding(name)
+				},
+			})
+		}
+	}
+
+	if len(iface.Addresses) > 0 {
+		current, err := r.netsh.GetIPAddresses(name)
+		if err != nil {
+			return err
+		}
+		for _, address := range iface.Addresses {
+			ip, subnet, _ := net.ParseCIDR(address)
+			desired := &net.IPNet{IP: ip.To4(), Mask: subnet.Mask}
+
+			var existing *net.IPNet
+			for _, c := range current {
+				if c.IP.Equal(desired.IP) {
+					existing = c
+				}
+			}
+			switch {
+			case existing == nil:
+				plan.Changes = append(plan.Changes, Change{
+					Resource: ResourceAddress,
+					Action:   ActionCreate,
+					Target:   name,
+					Desired:  desired.String(),
+					apply:    func() error { return r.netsh.AddIPAddress(name, desired) },
+				})
+			case existing.String() != desired.String():
+				plan.Changes = append(plan.Changes, Change{
+					Resource: ResourceAddress,
+					Action:   ActionUpdate,
+					Target:   name,
+					Current:  existing.String(),
+					Desired:  desired.String(),
+					apply: func() error {
+						if err := r.netsh.RemoveIPAddress(name, desired.IP); err != nil {
+							return err
+						}
+						return r.netsh.AddIPAddress(name, desired)
+					},
+				})
+			}
+		}
+	}
+
+	if iface.DNS != nil {
+		current, err := r.netsh.GetDNSClientConfig(name)
+		if err != nil {
+			return err
+		}
+		currentString := dnsString(current.Dhcp, current.Servers)
+		if iface.DNS.DHCP {
+			if !current.Dhcp {
+				plan.Changes = append(plan.Changes, Change{
+					Resource: ResourceDNS,
+					Action:   ActionUpdate,
+					Target:   name,
+					Current:  currentString,
+					Desired:  dnsString(true, nil),
+					apply:    func() error { return r.netsh.ResetDNSToDHCP(name) },
+				})
+			}
+		} else {
+			var servers []net.IP
+			for _, server := range iface.DNS.Servers {
+				servers = append(servers, net.ParseIP(server))
+			}
+			if current.Dhcp || dnsString(false, servers) != currentString {
+				plan.Changes = append(plan.Changes, Change{
+					Resource: ResourceDNS,
+					Action:   ActionUpdate,
+					Target:   name,
+					Current:  currentString,
+					Desired:  dnsString(false, servers),
+					apply:    func() error { return r.netsh.SetDNSServers(name, servers) },
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) planRoutes(plan *Plan, routes []RouteSpec) error {
+	indexes := map[string]int{}
+	for _, spec := range routes {
+		linkIndex, ok := indexes[spec.Interface]
+		if !ok {
+			iface, err := r.netsh.GetInterfaceByName(spec.Interface)
+			if err != nil {
+				return err
+			}
+			linkIndex = iface.Idx
+			indexes[spec.Interface] = linkIndex
+		}
+
+		_, destination, _ := net.ParseCIDR(spec.Destination)
+		gateway := net.ParseIP(spec.NextHop)
+		desired := netroute.Route{LinkIndex: linkIndex, DestinationSubnet: destination, GatewayAddress: gateway, RouteMetric: spec.Metric}
+		hop := netroute.MultipathRoute{
+			DestinationSubnet: destination,
+			NextHops:          []netroute.NextHop{{LinkIndex: linkIndex, GatewayAddress: gateway, RouteMetric: spec.Metric}},
+		}
+		target := fmt.Sprintf("%v via %v on %v", destination, nextHopString(gateway), spec.Interface)
+
+		current, err := r.netroute.GetNetRoutes(linkIndex, destination)
+		if err != nil {
+			return err
+		}
+		var existing *netroute.Route
+		for i := range current {
+			if current[i].Equal(desired) {
+				existing = &current[i]
+			}
+		}
+
+		switch {
+		case existing == nil:
+			plan.Changes = append(plan.Changes, Change{
+				Resource: ResourceRoute,
+				Action:   ActionCreate,
+				Target:   target,
+				Desired:  metricString(spec.Metric),
+				apply:    func() error { return r.netroute.NewNetRouteMultipath(hop) },
+			})
+		case spec.Metric != 0 && existing.RouteMetric != spec.Metric:
+			plan.Changes = append(plan.Changes, Change{
+				Resource: ResourceRoute,
+				Action:   ActionUpdate,
+				Target:   target,
+				Current:  metricString(existing.RouteMetric),
+				Desired:  metricString(spec.Metric),
+				apply: func() error {
+					if err := r.netroute.RemoveNetRoute(linkIndex, destination, gateway); err != nil {
+						return err
+					}
+					return r.netroute.NewNetRouteMultipath(hop)
+				},
+			})
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) planPortProxy(plan *Plan, specs []PortProxySpec) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	current, err := r.netsh.GetPortProxyRules()
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		desired := netsh.PortProxyRule{
+			Protocol:       spec.Protocol,
+			ListenAddress:  spec.ListenAddress,
+			ListenPort:     spec.ListenPort,
+			ConnectAddress: spec.ConnectAddress,
+			ConnectPort:    spec.ConnectPort,
+		}
+		if desired.Protocol == "" {
+			desired.Protocol = "v4tov4"
+		}
+		listenAddress := desired.ListenAddress
+		if listenAddress == "" {
+			listenAddress = "*"
+		}
+		target := fmt.Sprintf("%v %v:%v", desired.Protocol, listenAddress, desired.ListenPort)
+		connect := fmt.Sprintf("%v:%v", desired.ConnectAddress, desired.ConnectPort)
+
+		var existing *netsh.PortProxyRule
+		for i, rule := range current {
+			if rule.Protocol == desired.Protocol && rule.ListenAddress == listenAddress && rule.ListenPort == desired.ListenPort {
+				existing = &current[i]
+			}
+		}
+
+		switch {
+		case existing == nil:
+			plan.Changes = append(plan.Changes, Change{
+				Resource: ResourcePortProxy,
+				Action:   ActionCreate,
+				Target:   target,
+				Desired:  connect,
+				apply:    func() error { return r.applyPortProxy(desired, false) },
+			})
+		case existing.ConnectAddress != desired.ConnectAddress || existing.ConnectPort != desired.ConnectPort:
+			plan.Changes = append(plan.Changes, Change{
+				Resource: ResourcePortProxy,
+				Action:   ActionUpdate,
+				Target:   target,
+				Current:  fmt.Sprintf("%v:%v", existing.ConnectAddress, existing.ConnectPort),
+				Desired:  connect,
+				apply:    func() error { return r.applyPortProxy(desired, true) },
+			})
+		}
+	}
+
+	return nil
+}
+
+// applyPortProxy adds rule in a netsh batch, deleting the rule listening on the same port first if replace is set
+func (r *Reconciler) applyPortProxy(rule netsh.PortProxyRule, replace bool) error {
+	batch := r.netsh.NewBatch()
+	if replace {
+		batch.DeletePortProxyRule(rule)
+	}
+	results, err := batch.AddPortProxyRule(rule).Run()
+	if err != nil {
+		return err
+	}
+	return results[len(results)-1].Err
+}
+
+func (r *Reconciler) planFirewall(plan *Plan, specs []FirewallSpec) error {
+	for _, spec := range specs {
+		desired := netsh.FirewallRule{
+			Name:          spec.Name,
+			Direction:     strings.ToLower(spec.Direction),
+			Action:        strings.ToLower(spec.Action),
+			Protocol:      spec.Protocol,
+			LocalPort:     spec.LocalPort,
+			RemoteAddress: spec.RemoteAddress,
+			Enabled:       !spec.Disabled,
+		}
+
+		current, err := r.netsh.GetFirewallRules(spec.Name)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case len(current) == 0:
+			plan.Changes = append(plan.Changes, Change{
+				Resource: ResourceFirewall,
+				Action:   ActionCreate,
+				Target:   spec.Name,
+				Desired:  firewallString(desired),
+				apply:    func() error { return r.netsh.AddFirewallRule(desired) },
+			})
+		case len(current) > 1 || !firewallRuleEqual(current[0], desired):
+			var currentStrings []string
+			for _, rule := range current {
+				currentStrings = append(currentStrings, firewallString(rule))
+			}
+			plan.Changes = append(plan.Changes, Change{
+				Resource: ResourceFirewall,
+				Action:   ActionUpdate,
+				Target:   spec.Name,
+				Current:  strings.Join(currentStrings, ", "),
+				Desired:  firewallString(desired),
+				apply: func() error {
+					if err := r.netsh.DeleteFirewallRule(desired.Name); err != nil {
+						return err
+					}
+					return r.netsh.AddFirewallRule(desired)
+				},
+			})
+		}
+	}
+
+	return nil
+}
+
+func firewallRuleEqual(a, b netsh.FirewallRule) bool {
+	protocol := func(p string) string {
+		if p == "" {
+			return "any"
+		}
+		return strings.ToLower(p)
+	}
+	return a.Direction == b.Direction && a.Action == b.Action && protocol(a.Protocol) == protocol(b.Protocol) &&
+		a.LocalPort == b.LocalPort && a.RemoteAddress == b.RemoteAddress && a.Enabled == b.Enabled
+}
+
+func firewallString(rule netsh.FirewallRule) string {
+	s := fmt.Sprintf("%v %v", rule.Action, rule.Direction)
+	if rule.Protocol != "" {
+		s += " " + rule.Protocol
+	}
+	if rule.LocalPort != "" {
+		s += " port " + rule.LocalPort
+	}
+	if rule.RemoteAddress != "" {
+		s += " from " + rule.RemoteAddress
+	}
+	if !rule.Enabled {
+		s += " (disabled)"
+	}
+	return s
+}
+
+func enabledString(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func dnsString(dhcp bool, servers []net.IP) string {
+	if dhcp {
+		return "dhcp"
+	}
+	var s []string
+	for _, server := range servers {
+		s = append(s, server.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func nextHopString(gateway net.IP) string {
+	if gateway == nil || gateway.IsUnspecified() {
+		return "on-link"
+	}
+	return gateway.String()
+}
+
+func metricString(metric int) string {
+	if metric == 0 {
+		return "default metric"
+	}
+	return fmt.Sprintf("metric %v", metric)
+}
@@ -0,0 +1,138 @@
+package hostnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	fakenetroute "github.com/rakelkar/gonetsh/netroute/testing"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+	fakenetsh "github.com/rakelkar/gonetsh/netsh/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeHost() (*[]string, *fakenetsh.RecordingNetsh, *fakenetroute.RecordingNetroute) {
+	calls := &[]string{}
+	_, pods, _ := net.ParseCIDR("10.244.0.0/16")
+	nsh := fakenetsh.NewRecording(calls)
+	nsh.Interfaces = []netsh.Ipv4Interface{{Idx: 12, Name: "vEthernet (nat)"}, {Idx: 4, Name: "Ethernet"}}
+	nsh.Addresses["vEthernet (nat)"] = []*net.IPNet{{IP: net.ParseIP("172.23.0.1").To4(), Mask: net.CIDRMask(24, 32)}}
+	nsh.DNS["vEthernet (nat)"] = netsh.DNSClientConfig{Servers: []net.IP{net.ParseIP("10.96.0.10")}}
+	nsh.DNS["Ethernet"] = netsh.DNSClientConfig{Dhcp: true}
+	nsh.PortProxy = []netsh.PortProxyRule{
+		{Protocol: "v4tov4", ListenAddress: "*", ListenPort: 10250, ConnectAddress: "172.23.0.9", ConnectPort: 10250},
+	}
+	nr := fakenetroute.NewRecording(calls)
+	nr.Routes = []netroute.Route{
+		{LinkIndex: 12, DestinationSubnet: pods, GatewayAddress: net.ParseIP("172.23.0.2"), RouteMetric: 256},
+	}
+	return calls, nsh, nr
+}
+
+func TestReconcilePlansInDependencyOrder(t *testing.T) {
+	calls, nsh, nr := newFakeHost()
+	spec, err := ParseSpec([]byte(specYAML))
+	assert.NoError(t, err)
+
+	plan, err := NewReconciler(nsh, nr).Reconcile(spec, true)
+	assert.NoError(t, err)
+	assert.Empty(t, *calls)
+	assert.Equal(t, "update forwarding vEthernet (nat): disabled -> enabled\n"+
+		"update address vEthernet (nat): 172.23.0.1/24 -> 172.23.0.1/20\n"+
+		"update dns vEthernet (nat): 10.96.0.10 -> 10.96.0.10,168.63.129.16\n"+
+		"update route 10.244.0.0/16 via 172.23.0.2 on vEthernet (nat): metric 256 -> metric 10\n"+
+		"update portproxy v4tov4 *:10250: 172.23.0.9:10250 -> 172.23.0.2:10250\n"+
+		"create firewall kubelet: allow in TCP port 10250\n", plan.String())
+	for _, change := range plan.Changes {
+		assert.False(t, change.Applied)
+	}
+}
+
+func TestReconcileConverges(t *testing.T) {
+	calls, nsh, nr := newFakeHost()
+	spec, err := ParseSpec([]byte(specYAML))
+	assert.NoError(t, err)
+	reconciler := NewReconciler(nsh, nr)
+
+	plan, err := reconciler.Reconcile(spec, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(plan.Changes))
+	for _, change := range plan.Changes {
+		assert.True(t, change.Applied)
+	}
+	assert.Equal(t, []string{
+		"enable forwarding vEthernet (nat)",
+		"remove address vEthernet (nat) 172.23.0.1",
+		"add address vEthernet (nat) 172.23.0.1/20",
+		"set dns vEthernet (nat) [10.96.0.10 168.63.129.16]",
+		"remove route 12 10.244.0.0/16 172.23.0.2",
+		"add route 12 10.244.0.0/16 172.23.0.2 metric 10",
+		"interface portproxy delete v4tov4 listenport=10250",
+		"interface portproxy add v4tov4 listenport=10250 connectport=10250 connectaddress=172.23.0.2",
+		"add firewall kubelet",
+	}, *calls)
+
+	plan, err = reconciler.Reconcile(spec, true)
+	assert.NoError(t, err)
+	assert.True(t, plan.InSync(), plan.String())
+}
+
+func TestReconcileStopsAtFirstFailure(t *testing.T) {
+	_, nsh, nr := newFakeHost()
+	nsh.Fail["add address vEthernet (nat) 172.23.0.1/20"] = errors.New("The object already exists.")
+	spec, err := ParseSpec([]byte(specYAML))
+	assert.NoError(t, err)
+
+	plan, err := NewReconciler(nsh, nr).Reconcile(spec, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "update address vEthernet (nat)")
+	assert.True(t, plan.Changes[0].Applied)
+	assert.False(t, plan.Changes[1].Applied)
+	assert.False(t, plan.Changes[5].Applied)
+	assert.Empty(t, nsh.Firewall)
+}
+
+func TestPlanUnknownRouteInterface(t *testing.T) {
+	_, nsh, nr := newFakeHost()
+
+	_, err := NewReconciler(nsh, nr).Plan(&Spec{Routes: []RouteSpec{{Destination: "10.0.0.0/8", Interface: "missing"}}})
+	assert.Error(t, err)
+
+	_, err = NewReconciler(nsh, nr).Plan(&Spec{Routes: []RouteSpec{{Destination: "10.0.0.0/8"}}})
+	assert.Error(t, err)
+}
+
+// scriptShell answers every powershell script with stdout, as get-netroute would
+type scriptShell struct {
+	stdout string
+}
+
+func (s *scriptShell) Execute(cmd string) (string, string, error) {
+	return s.stdout, "", nil
+}
+
+func (s *scriptShell) Exit() {
+}
+
+func TestPlanRouteMetricFromHost(t *testing.T) {
+	_, nsh, _ := newFakeHost()
+	spec := &Spec{Routes: []RouteSpec{{Destination: "10.244.0.0/16", NextHop: "172.23.0.2", Interface: "vEthernet (nat)", Metric: 10}}}
+	getNetRoute := `
+ifIndex DestinationPrefix                              NextHop                                  RouteMetric ifMetric PolicyStore
+------- -----------------                              -------                                  ----------- -------- -----------
+12      10.244.0.0/16                                  172.23.0.2                                       %3v 15       ActiveStore`
+
+	nr, err := netroute.NewWithOptions(netroute.Options{Shell: &scriptShell{fmt.Sprintf(getNetRoute, 10)}})
+	assert.NoError(t, err)
+	plan, err := NewReconciler(nsh, nr).Plan(spec)
+	assert.NoError(t, err)
+	assert.Empty(t, plan.Changes)
+
+	nr, err = netroute.NewWithOptions(netroute.Options{Shell: &scriptShell{fmt.Sprintf(getNetRoute, 256)}})
+	assert.NoError(t, err)
+	plan, err = NewReconciler(nsh, nr).Plan(spec)
+	assert.NoError(t, err)
+	assert.Equal(t, "update route 10.244.0.0/16 via 172.23.0.2 on vEthernet (nat): metric 256 -> metric 10\n", plan.String())
+}
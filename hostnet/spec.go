@@ -0,0 +1,196 @@
+package hostnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the desired network state of a node. Only what is listed is managed, anything else on the host is left
+// alone, e.g. addresses, routes or rules missing from the spec are never removed.
+type Spec struct {
+	Interfaces []InterfaceSpec `json:"interfaces,omitempty"`
+	PortProxy  []PortProxySpec `json:"portProxy,omitempty"`
+	Firewall   []FirewallSpec  `json:"firewall,omitempty"`
+	Routes     []RouteSpec     `json:"routes,omitempty"`
+}
+
+// InterfaceSpec is the desired state of one interface, nil fields are not managed
+type InterfaceSpec struct {
+	Name string `json:"name"`
+	// Addresses are static IPv4 addresses in CIDR notation, e.g. 10.0.0.5/24
+	Addresses  []string `json:"addresses,omitempty"`
+	DNS        *DNSSpec `json:"dns,omitempty"`
+	Forwarding *bool    `json:"forwarding,omitempty"`
+}
+
+// DNSSpec either lists static DNS servers in order or asks for the DHCP provided ones
+type DNSSpec struct {
+	Servers []string `json:"servers,omitempty"`
+	DHCP    bool     `json:"dhcp,omitempty"`
+}
+
+// PortProxySpec is a portproxy rule, an empty Protocol means v4tov4 and an empty ListenAddress every address
+type PortProxySpec struct {
+	Protocol       string `json:"protocol,omitempty"`
+	ListenAddress  string `json:"listenAddress,omitempty"`
+	ListenPort     int    `json:"listenPort"`
+	ConnectAddress string `json:"connectAddress"`
+	ConnectPort    int    `json:"connectPort"`
+}
+
+// FirewallSpec is a firewall rule, identified by its name
+type FirewallSpec struct {
+	Name string `json:"name"`
+	// Direction is in or out
+	Direction string `json:"direction"`
+	// Action is allow or block
+	Action        string `json:"action"`
+	Protocol      string `json:"protocol,omitempty"`
+	LocalPort     string `json:"localPort,omitempty"`
+	RemoteAddress string `json:"remoteAddress,omitempty"`
+	// Disabled keeps the rule but turns it off
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// RouteSpec is a route through the named interface, an empty NextHop makes it on-link
+type RouteSpec struct {
+	Destination string `json:"destination"`
+	NextHop     string `json:"nextHop,omitempty"`
+	Interface   string `json:"interface"`
+	Metric      int    `json:"metric,omitempty"`
+}
+
+// ParseSpec reads a Spec from a YAML or JSON document, rejecting unknown fields and invalid values
+func ParseSpec(data []byte) (*Spec, error) {
+	// decode YAML generically and re-encode it, so one set of json tags serves both formats
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+	if generic == nil {
+		return &Spec{}, nil
+	}
+	encoded, err := json.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	var spec Spec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// ReadSpecFile reads a Spec from a YAML or JSON file
+func ReadSpecFile(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSpec(data)
+}
+
+// Validate checks every value of the spec, reporting all problems at once
+func (spec *Spec) Validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	names := map[string]bool{}
+	for i, iface := range spec.Interfaces {
+		if iface.Name == "" {
+			addProblem("interfaces[%v]: name is required", i)
+		} else if names[iface.Name] {
+			addProblem("interfaces[%v]: duplicate interface %v", i, iface.Name)
+		}
+		names[iface.Name] = true
+
+		for _, address := range iface.Addresses {
+			if ip, _, err := net.ParseCIDR(address); err != nil || ip.To4() == nil {
+				addProblem("interfaces[%v]: invalid ipv4 address %q", i, address)
+			}
+		}
+		if iface.DNS != nil {
+			if iface.DNS.DHCP == (len(iface.DNS.Servers) > 0) {
+				addProblem("interfaces[%v]: dns needs either servers or dhcp", i)
+			}
+			for _, server := range iface.DNS.Servers {
+				if ip := net.ParseIP(server); ip == nil || ip.To4() == nil {
+					addProblem("interfaces[%v]: invalid ipv4 dns server %q", i, server)
+				}
+			}
+		}
+	}
+
+	for i, rule := range spec.PortProxy {
+		switch rule.Protocol {
+		case "", "v4tov4", "v4tov6", "v6tov4", "v6tov6":
+		default:
+			addProblem("portProxy[%v]: invalid protocol %q", i, rule.Protocol)
+		}
+		if rule.ListenAddress != "" && net.ParseIP(rule.ListenAddress) == nil {
+			addProblem("portProxy[%v]: invalid listen address %q", i, rule.ListenAddress)
+		}
+		if rule.ConnectAddress == "" {
+			addProblem("portProxy[%v]: connect address is required", i)
+		}
+		if !validPort(rule.ListenPort) || !validPort(rule.ConnectPort) {
+			addProblem("portProxy[%v]: ports must be between 1 and 65535", i)
+		}
+	}
+
+	rules := map[string]bool{}
+	for i, rule := range spec.Firewall {
+		if rule.Name == "" || strings.EqualFold(rule.Name, "all") {
+			addProblem("firewall[%v]: invalid name %q", i, rule.Name)
+		} else if rules[rule.Name] {
+			addProblem("firewall[%v]: duplicate rule %v", i, rule.Name)
+		}
+		rules[rule.Name] = true
+
+		if d := strings.ToLower(rule.Direction); d != "in" && d != "out" {
+			addProblem("firewall[%v]: direction must be in or out", i)
+		}
+		if a := strings.ToLower(rule.Action); a != "allow" && a != "block" {
+			addProblem("firewall[%v]: action must be allow or block", i)
+		}
+	}
+
+	for i, route := range spec.Routes {
+		if _, _, err := net.ParseCIDR(route.Destination); err != nil {
+			addProblem("routes[%v]: invalid destination %q", i, route.Destination)
+		}
+		if route.NextHop != "" && net.ParseIP(route.NextHop) == nil {
+			addProblem("routes[%v]: invalid next hop %q", i, route.NextHop)
+		}
+		if route.Interface == "" {
+			addProblem("routes[%v]: interface is required", i)
+		}
+		if route.Metric < 0 {
+			addProblem("routes[%v]: metric must not be negative", i)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid spec: %v", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
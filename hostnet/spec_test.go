@@ -0,0 +1,114 @@
+package hostnet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const specYAML = `
+interfaces:
+  - name: vEthernet (nat)
+    addresses: [172.23.0.1/20]
+    forwarding: true
+    dns:
+      servers: [10.96.0.10, 168.63.129.16]
+  - name: Ethernet
+    dns:
+      dhcp: true
+portProxy:
+  - listenPort: 10250
+    connectAddress: 172.23.0.2
+    connectPort: 10250
+firewall:
+  - name: kubelet
+    direction: in
+    action: allow
+    protocol: TCP
+    localPort: "10250"
+routes:
+  - destination: 10.244.0.0/16
+    nextHop: 172.23.0.2
+    interface: vEthernet (nat)
+    metric: 10
+`
+
+func TestParseSpecYAML(t *testing.T) {
+	spec, err := ParseSpec([]byte(specYAML))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(spec.Interfaces))
+	assert.Equal(t, "vEthernet (nat)", spec.Interfaces[0].Name)
+	assert.Equal(t, []string{"172.23.0.1/20"}, spec.Interfaces[0].Addresses)
+	assert.True(t, *spec.Interfaces[0].Forwarding)
+	assert.Equal(t, []string{"10.96.0.10", "168.63.129.16"}, spec.Interfaces[0].DNS.Servers)
+	assert.Nil(t, spec.Interfaces[1].Forwarding)
+	assert.True(t, spec.Interfaces[1].DNS.DHCP)
+	assert.Equal(t, []PortProxySpec{{ListenPort: 10250, ConnectAddress: "172.23.0.2", ConnectPort: 10250}}, spec.PortProxy)
+	assert.Equal(t, "10250", spec.Firewall[0].LocalPort)
+	assert.Equal(t, RouteSpec{Destination: "10.244.0.0/16", NextHop: "172.23.0.2", Interface: "vEthernet (nat)", Metric: 10}, spec.Routes[0])
+}
+
+func TestParseSpecJSON(t *testing.T) {
+	spec, err := ParseSpec([]byte(`{"interfaces": [{"name": "Ethernet", "forwarding": false}]}`))
+	assert.NoError(t, err)
+	assert.False(t, *spec.Interfaces[0].Forwarding)
+
+	spec, err = ParseSpec(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &Spec{}, spec)
+}
+
+func TestParseSpecRejectsInvalid(t *testing.T) {
+	_, err := ParseSpec([]byte("interfaces:\n  - name: Ethernet\n    mtu: 1500\n"))
+	assert.Error(t, err)
+
+	_, err = ParseSpec([]byte(`
+interfaces:
+  - name: Ethernet
+    addresses: [10.0.0.5]
+    dns: {}
+  - name: Ethernet
+portProxy:
+  - listenPort: 70000
+firewall:
+  - name: all
+    direction: sideways
+    action: allow
+routes:
+  - destination: 10.0.0.0
+`))
+	assert.Error(t, err)
+	for _, problem := range []string{
+		`invalid ipv4 address "10.0.0.5"`,
+		"dns needs either servers or dhcp",
+		"duplicate interface Ethernet",
+		"connect address is required",
+		"ports must be between 1 and 65535",
+		`invalid name "all"`,
+		"direction must be in or out",
+		`invalid destination "10.0.0.0"`,
+		"interface is required",
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+}
+
+func TestReadSpecFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostnet")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "node.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(specYAML), 0644))
+
+	spec, err := ReadSpecFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(spec.Interfaces))
+
+	_, err = ReadSpecFile(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,375 @@
+package ipam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+// ErrExhausted is returned when every address in the subnet is reserved, allocated or in use.
+var ErrExhausted = errors.New("no free addresses left in subnet")
+
+// Range is an inclusive range of addresses that must never be handed out.
+type Range struct {
+	Start net.IP
+	End   net.IP
+}
+
+// Config describes the subnet an Allocator hands addresses out of.
+type Config struct {
+	// Subnet to allocate from, e.g. the pod CIDR of the node
+	Subnet *net.IPNet
+	// Reserved ranges inside the subnet, e.g. the gateway address
+	Reserved []Range
+	// StateFile is where allocations are persisted, empty keeps state in memory only
+	StateFile string
+}
+
+// Allocator hands out host-local addresses from a subnet. It is goroutine-safe.
+type Allocator struct {
+	mu        sync.Mutex
+	subnet    *net.IPNet
+	reserved  []Range
+	stateFile string
+	netsh     netsh.Interface
+	netroute  netroute.Interface
+	state     state
+}
+
+// state is the on-disk representation of the allocations
+type state struct {
+	Subnet      string            `json:"subnet"`
+	Allocations map[string]string `json:"allocations"`
+	Last        string            `json:"last,omitempty"`
+}
+
+// New returns an Allocator for the configured subnet, loading any previously persisted state.
+// nsh and nr are used to skip addresses already in use on the host, either may be nil.
+func New(config Config, nsh netsh.Interface, nr netroute.Interface) (*Allocator, error) {
+	if config.Subnet == nil {
+		return nil, fmt.Errorf("subnet is required")
+	}
+
+	for _, r := range config.Reserved {
+		if r.Start == nil || r.End == nil {
+			return nil, fmt.Errorf("invalid reserved range %v-%v", r.Start, r.End)
+		}
+		if !config.Subnet.Contains(r.Start) || !config.Subnet.Contains(r.End) {
+			return nil, fmt.Errorf("reserved range %v-%v is outside of subnet %v", r.Start, r.End, config.Subnet)
+		}
+		if netroute.IpToInt(r.Start).Cmp(netroute.IpToInt(r.End)) > 0 {
+			return nil, fmt.Errorf("reserved range %v-%v has start after end", r.Start, r.End)
+		}
+	}
+
+	allocator := &Allocator{
+		subnet:    config.Subnet,
+		reserved:  config.Reserved,
+		stateFile: config.StateFile,
+		netsh:     nsh,
+		netroute:  nr,
+		state: state{
+			Subnet:      config.Subnet.String(),
+			Allocations: map[string]string{},
+		},
+	}
+
+	if err := allocator.load(); err != nil {
+		return nil, err
+	}
+
+	return allocator, nil
+}
+
+// Allocate reserves the next free address in the subnet for id.
+func (a *Allocator) Allocate(id string) (net.IP, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	inUse, err := a.hostAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := a.bounds()
+	size := new(big.Int).Sub(last, first)
+	size.Add(size, big.NewInt(1))
+
+	// continue after the last allocated address so released addresses are not reused right away
+	current := new(big.Int).Sub(first, big.NewInt(1))
+	if ip := net.ParseIP(a.state.Last); ip != nil && a.subnet.Contains(ip) {
+		current = netroute.IpToInt(ip)
+	}
+
+	for i := big.NewInt(0); i.Cmp(size) < 0; i.Add(i, big.NewInt(1)) {
+		current = new(big.Int).Add(current, big.NewInt(1))
+		if current.Cmp(last) > 0 {
+			current = new(big.Int).Set(first)
+		}
+
+		ip := intToIP(current, len(a.subnetIP()))
+		if !a.isFree(ip, inUse) {
+			continue
+		}
+
+		a.state.Allocations[ip.String()] = id
+		a.state.Last = ip.String()
+		if err := a.save(); err != nil {
+			delete(a.state.Allocations, ip.String())
+			return nil, err
+		}
+		return ip, nil
+	}
+
+	return nil, ErrExhausted
+}
+
+// AllocateIP reserves a specific address for id.
+func (a *Allocator) AllocateIP(id string, ip net.IP) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.subnet.Contains(ip) {
+		return fmt.Errorf("address %v is outside of subnet %v", ip, a.subnet)
+	}
+	if owner, ok := a.state.Allocations[ip.String()]; ok {
+		if owner == id {
+			return nil
+		}
+		return fmt.Errorf("address %v is already allocated to %v", ip, owner)
+	}
+
+	inUse, err := a.hostAddresses()
+	if err != nil {
+		return err
+	}
+	if !a.isFree(ip, inUse) {
+		return fmt.Errorf("address %v is reserved or in use on the host", ip)
+	}
+
+	a.state.Allocations[ip.String()] = id
+	if err := a.save(); err != nil {
+		delete(a.state.Allocations, ip.String())
+		return err
+	}
+	return nil
+}
+
+// Release frees every address allocated to id. Releasing an unknown id is not an error.
+func (a *Allocator) Release(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	released := map[string]string{}
+	for ip, owner := range a.state.Allocations {
+		if owner == id {
+			released[ip] = owner
+			delete(a.state.Allocations, ip)
+		}
+	}
+	if len(released) == 0 {
+		return nil
+	}
+
+	if err := a.save(); err != nil {
+		for ip, owner := range released {
+			a.state.Allocations[ip] = owner
+		}
+		return err
+	}
+	return nil
+}
+
+// ReleaseIP frees a single address. Releasing an address that is not allocated is not an error.
+func (a *Allocator) ReleaseIP(ip net.IP) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	owner, ok := a.state.Allocations[ip.String()]
+	if !ok {
+		return nil
+	}
+
+	delete(a.state.Allocations, ip.String())
+	if err := a.save(); err != nil {
+		a.state.Allocations[ip.String()] = owner
+		return err
+	}
+	return nil
+}
+
+// Allocations returns a copy of the current allocations keyed by address.
+func (a *Allocator) Allocations() map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	allocations := make(map[string]string, len(a.state.Allocations))
+	for ip, owner := range a.state.Allocations {
+		allocations[ip] = owner
+	}
+	return allocations
+}
+
+// isFree returns true if ip may be handed out
+func (a *Allocator) isFree(ip net.IP, inUse map[string]bool) bool {
+	if _, ok := a.state.Allocations[ip.String()]; ok {
+		return false
+	}
+	if inUse[ip.String()] {
+		return false
+	}
+
+	value := netroute.IpToInt(ip)
+	for _, r := range a.reserved {
+		if value.Cmp(netroute.IpToInt(r.Start)) >= 0 && value.Cmp(netroute.IpToInt(r.End)) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bounds returns the first and last assignable address, skipping the network and IPv4 broadcast addresses
+func (a *Allocator) bounds() (*big.Int, *big.Int) {
+	ip := a.subnetIP()
+	network := netroute.IpToInt(ip.Mask(a.subnet.Mask))
+
+	ones, bits := a.subnet.Mask.Size()
+	hostBits := uint(bits - ones)
+	broadcast := new(big.Int).Lsh(big.NewInt(1), hostBits)
+	broadcast.Sub(broadcast, big.NewInt(1))
+	broadcast.Or(broadcast, network)
+
+	first := new(big.Int).Add(network, big.NewInt(1))
+	last := broadcast
+	if ip.To4() != nil {
+		last = new(big.Int).Sub(broadcast, big.NewInt(1))
+	}
+
+	// /31, /32 and friends have no room for a separate network address
+	if first.Cmp(last) > 0 {
+		return network, broadcast
+	}
+	return first, last
+}
+
+// subnetIP returns the subnet address in its shortest form
+func (a *Allocator) subnetIP() net.IP {
+	if v := a.subnet.IP.To4(); v != nil {
+		return v
+	}
+	return a.subnet.IP.To16()
+}
+
+// hostAddresses returns the addresses assigned to host interfaces or used as route gateways
+func (a *Allocator) hostAddresses() (map[string]bool, error) {
+	inUse := map[string]bool{}
+
+	if a.netsh != nil {
+		interfaces, err := a.netsh.GetInterfaces()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list host interfaces: %v", err)
+		}
+		for _, iface := range interfaces {
+			for _, addr := range []string{iface.IpAddress, iface.DefaultGatewayAddress} {
+				if ip := net.ParseIP(addr); ip != nil {
+					inUse[ip.String()] = true
+				}
+			}
+		}
+	}
+
+	if a.netroute != nil {
+		routes, err := a.netroute.GetNetRoutesAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list host routes: %v", err)
+		}
+		for _, route := range routes {
+			if route.GatewayAddress != nil {
+				inUse[route.GatewayAddress.String()] = true
+			}
+		}
+	}
+
+	return inUse, nil
+}
+
+// load reads persisted allocations, a missing state file is not an error
+func (a *Allocator) load() error {
+	if a.stateFile == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(a.stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ipam state %v: %v", a.stateFile, err)
+	}
+
+	var persisted state
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return fmt.Errorf("failed to parse ipam state %v: %v", a.stateFile, err)
+	}
+	if persisted.Subnet != a.state.Subnet {
+		return fmt.Errorf("ipam state %v is for subnet %v, not %v", a.stateFile, persisted.Subnet, a.state.Subnet)
+	}
+	if persisted.Allocations == nil {
+		persisted.Allocations = map[string]string{}
+	}
+
+	a.state = persisted
+	return nil
+}
+
+// save persists allocations by writing a temp file next to the state file and renaming it over
+func (a *Allocator) save() error {
+	if a.stateFile == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(a.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(a.stateFile)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(a.stateFile)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save ipam state: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save ipam state: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save ipam state: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save ipam state: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), a.stateFile); err != nil {
+		return fmt.Errorf("failed to save ipam state: %v", err)
+	}
+	return nil
+}
+
+// intToIP converts i back to an address of the given byte length
+func intToIP(i *big.Int, length int) net.IP {
+	ip := make(net.IP, length)
+	b := i.Bytes()
+	copy(ip[length-len(b):], b)
+	return ip
+}
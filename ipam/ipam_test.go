@@ -0,0 +1,172 @@
+package ipam
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	fakenetroute "github.com/rakelkar/gonetsh/netroute/testing"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+	fakenetsh "github.com/rakelkar/gonetsh/netsh/testing"
+)
+
+type fakeNetsh struct {
+	*fakenetsh.FakeNetsh
+	interfaces []netsh.Ipv4Interface
+}
+
+func (f *fakeNetsh) GetInterfaces() ([]netsh.Ipv4Interface, error) {
+	return f.interfaces, nil
+}
+
+type fakeNetroute struct {
+	*fakenetroute.FakeNetroute
+	routes []netroute.Route
+}
+
+func (f *fakeNetroute) GetNetRoutesAll() ([]netroute.Route, error) {
+	return f.routes, nil
+}
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	_, subnet, err := net.ParseCIDR(cidr)
+	require.NoError(t, err)
+	return subnet
+}
+
+func TestAllocateSkipsReservedAndHostAddresses(t *testing.T) {
+	nsh := &fakeNetsh{
+		FakeNetsh: fakenetsh.NewFake(),
+		interfaces: []netsh.Ipv4Interface{
+			{Name: "vEthernet (cbr0)", IpAddress: "10.244.1.3"},
+		},
+	}
+	nr := &fakeNetroute{
+		FakeNetroute: fakenetroute.NewFake(),
+		routes: []netroute.Route{
+			{LinkIndex: 4, DestinationSubnet: mustParseCIDR(t, "0.0.0.0/0"), GatewayAddress: net.ParseIP("10.244.1.4")},
+		},
+	}
+
+	allocator, err := New(Config{
+		Subnet:   mustParseCIDR(t, "10.244.1.0/29"),
+		Reserved: []Range{{Start: net.ParseIP("10.244.1.1"), End: net.ParseIP("10.244.1.2")}},
+	}, nsh, nr)
+	require.NoError(t, err)
+
+	var allocated []string
+	for i := 0; i < 2; i++ {
+		ip, err := allocator.Allocate("pod")
+		require.NoError(t, err)
+		allocated = append(allocated, ip.String())
+	}
+	assert.Equal(t, []string{"10.244.1.5", "10.244.1.6"}, allocated)
+
+	// .7 is the broadcast address
+	_, err = allocator.Allocate("pod")
+	assert.Equal(t, ErrExhausted, err)
+}
+
+func TestReleaseAndReuse(t *testing.T) {
+	allocator, err := New(Config{Subnet: mustParseCIDR(t, "192.168.0.0/30")}, nil, nil)
+	require.NoError(t, err)
+
+	first, err := allocator.Allocate("a")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.0.1", first.String())
+
+	second, err := allocator.Allocate("b")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.0.2", second.String())
+
+	_, err = allocator.Allocate("c")
+	assert.Equal(t, ErrExhausted, err)
+
+	require.NoError(t, allocator.Release("a"))
+	third, err := allocator.Allocate("c")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.0.1", third.String())
+	assert.Equal(t, map[string]string{"192.168.0.1": "c", "192.168.0.2": "b"}, allocator.Allocations())
+}
+
+func TestAllocateIP(t *testing.T) {
+	allocator, err := New(Config{
+		Subnet:   mustParseCIDR(t, "10.0.0.0/24"),
+		Reserved: []Range{{Start: net.ParseIP("10.0.0.1"), End: net.ParseIP("10.0.0.1")}},
+	}, nil, nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, allocator.AllocateIP("a", net.ParseIP("10.0.0.10")))
+	assert.NoError(t, allocator.AllocateIP("a", net.ParseIP("10.0.0.10")))
+	assert.Error(t, allocator.AllocateIP("b", net.ParseIP("10.0.0.10")))
+	assert.Error(t, allocator.AllocateIP("b", net.ParseIP("10.0.0.1")))
+	assert.Error(t, allocator.AllocateIP("b", net.ParseIP("10.0.1.1")))
+
+	assert.NoError(t, allocator.ReleaseIP(net.ParseIP("10.0.0.10")))
+	assert.Empty(t, allocator.Allocations())
+}
+
+func TestIPv6Allocation(t *testing.T) {
+	allocator, err := New(Config{Subnet: mustParseCIDR(t, "fd00::/120")}, nil, nil)
+	require.NoError(t, err)
+
+	ip, err := allocator.Allocate("a")
+	require.NoError(t, err)
+	assert.Equal(t, "fd00::1", ip.String())
+}
+
+func TestStateIsPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipam")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := Config{
+		Subnet:    mustParseCIDR(t, "10.1.0.0/24"),
+		StateFile: filepath.Join(dir, "state.json"),
+	}
+
+	allocator, err := New(config, nil, nil)
+	require.NoError(t, err)
+	ip, err := allocator.Allocate("a")
+	require.NoError(t, err)
+
+	reloaded, err := New(config, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{ip.String(): "a"}, reloaded.Allocations())
+
+	next, err := reloaded.Allocate("b")
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.0.2", next.String())
+
+	// no temp files are left behind
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	config.Subnet = mustParseCIDR(t, "10.2.0.0/24")
+	_, err = New(config, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := New(Config{}, nil, nil)
+	assert.Error(t, err)
+
+	_, err = New(Config{
+		Subnet:   mustParseCIDR(t, "10.0.0.0/24"),
+		Reserved: []Range{{Start: net.ParseIP("10.0.0.5"), End: net.ParseIP("10.0.0.1")}},
+	}, nil, nil)
+	assert.Error(t, err)
+
+	_, err = New(Config{
+		Subnet:   mustParseCIDR(t, "10.0.0.0/24"),
+		Reserved: []Range{{Start: net.ParseIP("10.0.0.5"), End: net.ParseIP("10.0.1.1")}},
+	}, nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	utilexec "k8s.io/utils/exec"
+)
+
+// Error classes, the class label of the error counter
+const (
+	ErrorClassTimeout       = "timeout"
+	ErrorClassAccessDenied  = "access_denied"
+	ErrorClassNotFound      = "not_found"
+	ErrorClassAlreadyExists = "already_exists"
+	ErrorClassInUse         = "in_use"
+	ErrorClassShellClosed   = "shell_closed"
+	ErrorClassExitStatus    = "exit_status"
+	ErrorClassOther         = "other"
+)
+
+// Metrics holds the collectors shared by every instrumented netsh and netroute Interface
+type Metrics struct {
+	duration  *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+	sessions  prometheus.Gauge
+	queueWait *prometheus.HistogramVec
+}
+
+// New creates the collectors and registers them with registerer
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gonetsh",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of netsh and netroute operations, including every command they ran.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, []string{"package", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gonetsh",
+			Name:      "operation_errors_total",
+			Help:      "Failed netsh and netroute operations by error class.",
+		}, []string{"package", "operation", "class"}),
+		sessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "gonetsh",
+			Name:      "powershell_sessions",
+			Help:      "Running powershell sessions of netroute.",
+		}),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gonetsh",
+			Name:      "queue_wait_seconds",
+			Help:      "Time netsh waited for a mutation lock or for the process spawn rate limit.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"queue"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.duration, m.errors, m.sessions, m.queueWait} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// SetPowershellSessions sets the live powershell sessions gauge, pass it as netroute's Options.SessionHook
+func (m *Metrics) SetPowershellSessions(live int) {
+	m.sessions.Set(float64(live))
+}
+
+// ObserveQueueWait records the time spent waiting in a netsh queue, pass it as netsh's ConcurrencyOptions.WaitHook
+func (m *Metrics) ObserveQueueWait(queue string, wait time.Duration) {
+	m.queueWait.WithLabelValues(queue).Observe(wait.Seconds())
+}
+
+// observe records an operation that started at start and ended with *err
+func (m *Metrics) observe(pkg string, operation string, start time.Time, err *error) {
+	m.duration.WithLabelValues(pkg, operation).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		m.errors.WithLabelValues(pkg, operation, ErrorClass(*err)).Inc()
+	}
+}
+
+// ErrorClass buckets an error of netsh or netroute by the text netsh and powershell report for common failures
+func ErrorClass(err error) string {
+	if err == netroute.ErrShellClosed {
+		return ErrorClassShellClosed
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "timed out"):
+		return ErrorClassTimeout
+	case strings.Contains(message, "access is denied") || strings.Contains(message, "requires elevation"):
+		return ErrorClassAccessDenied
+	case strings.Contains(message, "not found") || strings.Contains(message, "no matching") || strings.Contains(message, "cannot find"):
+		return ErrorClassNotFound
+	case strings.Contains(message, "already exists"):
+		return ErrorClassAlreadyExists
+	case strings.Contains(message, "in use"):
+		return ErrorClassInUse
+	}
+
+	if _, ok := err.(utilexec.ExitError); ok {
+		return ErrorClassExitStatus
+	}
+	return ErrorClassOther
+}
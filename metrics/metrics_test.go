@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	"github.com/stretchr/testify/assert"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestNew(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	m, err := New(registry)
+	assert.NoError(t, err)
+
+	m.SetPowershellSessions(3)
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP gonetsh_powershell_sessions Running powershell sessions of netroute.
+# TYPE gonetsh_powershell_sessions gauge
+gonetsh_powershell_sessions 3
+`), "gonetsh_powershell_sessions"))
+
+	m.ObserveQueueWait("lock", 3*time.Millisecond)
+	m.ObserveQueueWait("lock", time.Second)
+	assert.Equal(t, 1, testutil.CollectAndCount(m.queueWait))
+	count, err := testutil.GatherAndCount(registry, "gonetsh_queue_wait_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// the collectors can only be registered once per registry
+	_, err = New(registry)
+	assert.Error(t, err)
+}
+
+func TestErrorClass(t *testing.T) {
+	for err, class := range map[error]string{
+		errors.New("powershell command timed out after 1s: get-netroute"):                    ErrorClassTimeout,
+		errors.New("failed to add address, error: exit status 1. stdout: Access is denied."): ErrorClassAccessDenied,
+		errors.New("The requested operation requires elevation (Run as administrator)."):     ErrorClassAccessDenied,
+		errors.New("Element not found."):                                                     ErrorClassNotFound,
+		errors.New("No matching MSFT_NetRoute objects found by CIM query"):                   ErrorClassNotFound,
+		errors.New("Instance MSFT_NetRoute already exists"):                                  ErrorClassAlreadyExists,
+		errors.New("The process cannot access the file because the object is in use."):       ErrorClassInUse,
+		netroute.ErrShellClosed:               ErrorClassShellClosed,
+		&fakeexec.FakeExitError{Status: 1}:    ErrorClassExitStatus,
+		errors.New("unexpected netsh output"): ErrorClassOther,
+	} {
+		assert.Equal(t, class, ErrorClass(err), err.Error())
+	}
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"net"
+	"time"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+)
+
+// instrumentedNetroute records the duration and errors of every call to the wrapped Interface
+type instrumentedNetroute struct {
+	netroute.Interface
+	metrics *Metrics
+}
+
+// Netroute returns nr instrumented with m. ApplyRouteBatch counts as failed only if the batch as a whole failed.
+func (m *Metrics) Netroute(nr netroute.Interface) netroute.Interface {
+	return &instrumentedNetroute{
+		Interface: nr,
+		metrics:   m,
+	}
+}
+
+func (n *instrumentedNetroute) observe(operation string, start time.Time, err *error) {
+	n.metrics.observe("netroute", operation, start, err)
+}
+
+func (n *instrumentedNetroute) GetNetRoutesAll() (routes []netroute.Route, err error) {
+	defer n.observe("GetNetRoutesAll", time.Now(), &err)
+	return n.Interface.GetNetRoutesAll()
+}
+
+func (n *instrumentedNetroute) GetNetRoutes(linkIndex int, destinationSubnet *net.IPNet) (routes []netroute.Route, err error) {
+	defer n.observe("GetNetRoutes", time.Now(), &err)
+	return n.Interface.GetNetRoutes(linkIndex, destinationSubnet)
+}
+
+func (n *instrumentedNetroute) GetNetRoutesFiltered(filter netroute.RouteFilter) (routes []netroute.Route, err error) {
+	defer n.observe("GetNetRoutesFiltered", time.Now(), &err)
+	return n.Interface.GetNetRoutesFiltered(filter)
+}
+
+func (n *instrumentedNetroute) NewNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) (err error) {
+	defer n.observe("NewNetRoute", time.Now(), &err)
+	return n.Interface.NewNetRoute(linkIndex, destinationSubnet, gatewayAddress)
+}
+
+func (n *instrumentedNetroute) RemoveNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) (err error) {
+	defer n.observe("RemoveNetRoute", time.Now(), &err)
+	return n.Interface.RemoveNetRoute(linkIndex, destinationSubnet, gatewayAddress)
+}
+
+func (n *instrumentedNetroute) ApplyRouteBatch(ops []netroute.RouteOp) (results []netroute.RouteOpResult, err error) {
+	defer n.observe("ApplyRouteBatch", time.Now(), &err)
+	return n.Interface.ApplyRouteBatch(ops)
+}
+
+func (n *instrumentedNetroute) NewNetRouteMultipath(route netroute.MultipathRoute) (err error) {
+	defer n.observe("NewNetRouteMultipath", time.Now(), &err)
+	return n.Interface.NewNetRouteMultipath(route)
+}
+
+func (n *instrumentedNetroute) RemoveNetRouteMultipath(route netroute.MultipathRoute) (err error) {
+	defer n.observe("RemoveNetRouteMultipath", time.Now(), &err)
+	return n.Interface.RemoveNetRouteMultipath(route)
+}
+
+func (n *instrumentedNetroute) NewBlackholeRoute(destinationSubnet *net.IPNet) (err error) {
+	defer n.observe("NewBlackholeRoute", time.Now(), &err)
+	return n.Interface.NewBlackholeRoute(destinationSubnet)
+}
+
+func (n *instrumentedNetroute) RemoveBlackholeRoute(destinationSubnet *net.IPNet) (err error) {
+	defer n.observe("RemoveBlackholeRoute", time.Now(), &err)
+	return n.Interface.RemoveBlackholeRoute(destinationSubnet)
+}
+
+func (n *instrumentedNetroute) GetLoopbackInterfaceIndex() (index int, err error) {
+	defer n.observe("GetLoopbackInterfaceIndex", time.Now(), &err)
+	return n.Interface.GetLoopbackInterfaceIndex()
+}
+
+func (n *instrumentedNetroute) GetNetIPAddresses(filter netroute.IPAddressFilter) (addresses []netroute.IPAddress, err error) {
+	defer n.observe("GetNetIPAddresses", time.Now(), &err)
+	return n.Interface.GetNetIPAddresses(filter)
+}
+
+func (n *instrumentedNetroute) NewNetIPAddress(linkIndex int, address net.IP, prefixLength int) (err error) {
+	defer n.observe("NewNetIPAddress", time.Now(), &err)
+	return n.Interface.NewNetIPAddress(linkIndex, address, prefixLength)
+}
+
+func (n *instrumentedNetroute) RemoveNetIPAddress(linkIndex int, address net.IP) (err error) {
+	defer n.observe("RemoveNetIPAddress", time.Now(), &err)
+	return n.Interface.RemoveNetIPAddress(linkIndex, address)
+}
+
+func (n *instrumentedNetroute) GetNetAdapters() (adapters []netroute.NetAdapter, err error) {
+	defer n.observe("GetNetAdapters", time.Now(), &err)
+	return n.Interface.GetNetAdapters()
+}
+
+func (n *instrumentedNetroute) GetNetNats() (nats []netroute.NetNat, err error) {
+	defer n.observe("GetNetNats", time.Now(), &err)
+	return n.Interface.GetNetNats()
+}
+
+func (n *instrumentedNetroute) NewNetNat(name string, internalPrefix *net.IPNet) (err error) {
+	defer n.observe("NewNetNat", time.Now(), &err)
+	return n.Interface.NewNetNat(name, internalPrefix)
+}
+
+func (n *instrumentedNetroute) RemoveNetNat(name string) (err error) {
+	defer n.observe("RemoveNetNat", time.Now(), &err)
+	return n.Interface.RemoveNetNat(name)
+}
+
+func (n *instrumentedNetroute) GetNetNatStaticMappings(natName string) (mappings []netroute.NetNatStaticMapping, err error) {
+	defer n.observe("GetNetNatStaticMappings", time.Now(), &err)
+	return n.Interface.GetNetNatStaticMappings(natName)
+}
+
+func (n *instrumentedNetroute) EnsureNetNatStaticMapping(mapping netroute.NetNatStaticMapping) (changed bool, err error) {
+	defer n.observe("EnsureNetNatStaticMapping", time.Now(), &err)
+	return n.Interface.EnsureNetNatStaticMapping(mapping)
+}
+
+func (n *instrumentedNetroute) RemoveNetNatStaticMapping(natName string, staticMappingID int) (err error) {
+	defer n.observe("RemoveNetNatStaticMapping", time.Now(), &err)
+	return n.Interface.RemoveNetNatStaticMapping(natName, staticMappingID)
+}
+
+func (n *instrumentedNetroute) GetDNSCache() (entries []netroute.DNSCacheEntry, err error) {
+	defer n.observe("GetDNSCache", time.Now(), &err)
+	return n.Interface.GetDNSCache()
+}
+
+func (n *instrumentedNetroute) ClearDNSCache() (err error) {
+	defer n.observe("ClearDNSCache", time.Now(), &err)
+	return n.Interface.ClearDNSCache()
+}
+
+func (n *instrumentedNetroute) ResolveName(name string, recordType string, server net.IP) (records []netroute.DNSRecord, err error) {
+	defer n.observe("ResolveName", time.Now(), &err)
+	return n.Interface.ResolveName(name, recordType, server)
+}
+
+func (n *instrumentedNetroute) GetNetCompartments() (compartments []netroute.Compartment, err error) {
+	defer n.observe("GetNetCompartments", time.Now(), &err)
+	return n.Interface.GetNetCompartments()
+}
+
+// InCompartment instruments the scoped Interface with the same metrics
+func (n *instrumentedNetroute) InCompartment(id int) netroute.Interface {
+	return n.metrics.Netroute(n.Interface.InCompartment(id))
+}
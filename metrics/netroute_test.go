@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	fakenetroute "github.com/rakelkar/gonetsh/netroute/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+type closedNetroute struct {
+	*fakenetroute.FakeNetroute
+}
+
+func (f *closedNetroute) NewNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	return netroute.ErrShellClosed
+}
+
+func TestNetroute(t *testing.T) {
+	m, err := New(prometheus.NewRegistry())
+	assert.NoError(t, err)
+
+	nr := m.Netroute(&closedNetroute{FakeNetroute: fakenetroute.NewFake()})
+	_, subnet, _ := net.ParseCIDR("10.244.1.0/24")
+	_, err = nr.GetNetRoutesAll()
+	assert.NoError(t, err)
+	assert.Equal(t, netroute.ErrShellClosed, nr.NewNetRoute(4, subnet, nil))
+	_, err = nr.InCompartment(2).GetNetRoutesAll()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(m.duration))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.errors.WithLabelValues("netroute", "NewNetRoute", ErrorClassShellClosed)))
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"net"
+	"time"
+
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+// instrumentedNetsh records the duration and errors of every call to the wrapped Interface
+type instrumentedNetsh struct {
+	netsh.Interface
+	metrics *Metrics
+}
+
+// Netsh returns nsh instrumented with m. Batches are returned as is, the script they run is not timed.
+func (m *Metrics) Netsh(nsh netsh.Interface) netsh.Interface {
+	return &instrumentedNetsh{
+		Interface: nsh,
+		metrics:   m,
+	}
+}
+
+func (n *instrumentedNetsh) observe(operation string, start time.Time, err *error) {
+	n.metrics.observe("netsh", operation, start, err)
+}
+
+func (n *instrumentedNetsh) EnsurePortProxyRule(args []string) (exists bool, err error) {
+	defer n.observe("EnsurePortProxyRule", time.Now(), &err)
+	return n.Interface.EnsurePortProxyRule(args)
+}
+
+func (n *instrumentedNetsh) DeletePortProxyRule(args []string) (err error) {
+	defer n.observe("DeletePortProxyRule", time.Now(), &err)
+	return n.Interface.DeletePortProxyRule(args)
+}
+
+func (n *instrumentedNetsh) GetPortProxyRules() (rules []netsh.PortProxyRule, err error) {
+	defer n.observe("GetPortProxyRules", time.Now(), &err)
+	return n.Interface.GetPortProxyRules()
+}
+
+func (n *instrumentedNetsh) DeleteIPAddress(args []string) (err error) {
+	defer n.observe("DeleteIPAddress", time.Now(), &err)
+	return n.Interface.DeleteIPAddress(args)
+}
+
+func (n *instrumentedNetsh) Restore(args []string) (err error) {
+	defer n.observe("Restore", time.Now(), &err)
+	return n.Interface.Restore(args)
+}
+
+func (n *instrumentedNetsh) GetDefaultGatewayIfaceName() (name string, err error) {
+	defer n.observe("GetDefaultGatewayIfaceName", time.Now(), &err)
+	return n.Interface.GetDefaultGatewayIfaceName()
+}
+
+func (n *instrumentedNetsh) GetInterfaces() (interfaces []netsh.Ipv4Interface, err error) {
+	defer n.observe("GetInterfaces", time.Now(), &err)
+	return n.Interface.GetInterfaces()
+}
+
+func (n *instrumentedNetsh) GetInterfaceByName(name string) (iface netsh.Ipv4Interface, err error) {
+	defer n.observe("GetInterfaceByName", time.Now(), &err)
+	return n.Interface.GetInterfaceByName(name)
+}
+
+func (n *instrumentedNetsh) GetInterfaceByIP(ipAddr string) (iface netsh.Ipv4Interface, err error) {
+	defer n.observe("GetInterfaceByIP", time.Now(), &err)
+	return n.Interface.GetInterfaceByIP(ipAddr)
+}
+
+func (n *instrumentedNetsh) EnableForwarding(iface string) (err error) {
+	defer n.observe("EnableForwarding", time.Now(), &err)
+	return n.Interface.EnableForwarding(iface)
+}
+
+func (n *instrumentedNetsh) DisableForwarding(iface string) (err error) {
+	defer n.observe("DisableForwarding", time.Now(), &err)
+	return n.Interface.DisableForwarding(iface)
+}
+
+func (n *instrumentedNetsh) GetForwarding(iface string) (enabled bool, err error) {
+	defer n.observe("GetForwarding", time.Now(), &err)
+	return n.Interface.GetForwarding(iface)
+}
+
+func (n *instrumentedNetsh) GetIPAddresses(iface string) (addresses []*net.IPNet, err error) {
+	defer n.observe("GetIPAddresses", time.Now(), &err)
+	return n.Interface.GetIPAddresses(iface)
+}
+
+func (n *instrumentedNetsh) AddIPAddress(iface string, address *net.IPNet) (err error) {
+	defer n.observe("AddIPAddress", time.Now(), &err)
+	return n.Interface.AddIPAddress(iface, address)
+}
+
+func (n *instrumentedNetsh) RemoveIPAddress(iface string, ip net.IP) (err error) {
+	defer n.observe("RemoveIPAddress", time.Now(), &err)
+	return n.Interface.RemoveIPAddress(iface, ip)
+}
+
+func (n *instrumentedNetsh) SetDNSServer(iface string, dns string) (err error) {
+	defer n.observe("SetDNSServer", time.Now(), &err)
+	return n.Interface.SetDNSServer(iface, dns)
+}
+
+func (n *instrumentedNetsh) GetDNSClientConfig(iface string) (config netsh.DNSClientConfig, err error) {
+	defer n.observe("GetDNSClientConfig", time.Now(), &err)
+	return n.Interface.GetDNSClientConfig(iface)
+}
+
+func (n *instrumentedNetsh) SetDNSServers(iface string, servers []net.IP) (err error) {
+	defer n.observe("SetDNSServers", time.Now(), &err)
+	return n.Interface.SetDNSServers(iface, servers)
+}
+
+func (n *instrumentedNetsh) ResetDNSToDHCP(iface string) (err error) {
+	defer n.observe("ResetDNSToDHCP", time.Now(), &err)
+	return n.Interface.ResetDNSToDHCP(iface)
+}
+
+func (n *instrumentedNetsh) SetDNSSuffix(iface string, suffix string) (err error) {
+	defer n.observe("SetDNSSuffix", time.Now(), &err)
+	return n.Interface.SetDNSSuffix(iface, suffix)
+}
+
+func (n *instrumentedNetsh) SetSuffixSearchList(suffixes []string) (err error) {
+	defer n.observe("SetSuffixSearchList", time.Now(), &err)
+	return n.Interface.SetSuffixSearchList(suffixes)
+}
+
+func (n *instrumentedNetsh) SetDNSRegistration(iface string, register bool, useSuffix bool) (err error) {
+	defer n.observe("SetDNSRegistration", time.Now(), &err)
+	return n.Interface.SetDNSRegistration(iface, register, useSuffix)
+}
+
+func (n *instrumentedNetsh) GetCompartments() (compartments []netsh.Compartment, err error) {
+	defer n.observe("GetCompartments", time.Now(), &err)
+	return n.Interface.GetCompartments()
+}
+
+// InCompartment instruments the scoped Interface with the same metrics
+func (n *instrumentedNetsh) InCompartment(id int) netsh.Interface {
+	return n.metrics.Netsh(n.Interface.InCompartment(id))
+}
+
+func (n *instrumentedNetsh) GetFirewallRules(name string) (rules []netsh.FirewallRule, err error) {
+	defer n.observe("GetFirewallRules", time.Now(), &err)
+	return n.Interface.GetFirewallRules(name)
+}
+
+func (n *instrumentedNetsh) AddFirewallRule(rule netsh.FirewallRule) (err error) {
+	defer n.observe("AddFirewallRule", time.Now(), &err)
+	return n.Interface.AddFirewallRule(rule)
+}
+
+func (n *instrumentedNetsh) DeleteFirewallRule(name string) (err error) {
+	defer n.observe("DeleteFirewallRule", time.Now(), &err)
+	return n.Interface.DeleteFirewallRule(name)
+}
+
+func (n *instrumentedNetsh) RunScript(script string) (output []byte, err error) {
+	defer n.observe("RunScript", time.Now(), &err)
+	return n.Interface.RunScript(script)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+	fakenetsh "github.com/rakelkar/gonetsh/netsh/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingNetsh struct {
+	*fakenetsh.FakeNetsh
+	compartment int
+}
+
+func (f *failingNetsh) EnableForwarding(iface string) error {
+	return errors.New("failed to enable forwarding on [" + iface + "], stdout: Element not found.")
+}
+
+func (f *failingNetsh) InCompartment(id int) netsh.Interface {
+	return &failingNetsh{FakeNetsh: f.FakeNetsh, compartment: id}
+}
+
+func TestNetsh(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	m, err := New(registry)
+	assert.NoError(t, err)
+
+	nsh := m.Netsh(&failingNetsh{FakeNetsh: fakenetsh.NewFake()})
+	_, err = nsh.GetInterfaces()
+	assert.NoError(t, err)
+	assert.Error(t, nsh.EnableForwarding("Ethernet"))
+	assert.Error(t, nsh.InCompartment(2).EnableForwarding("Ethernet"))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(m.duration))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.errors.WithLabelValues("netsh", "EnableForwarding", ErrorClassNotFound)))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.errors))
+
+	count, err := testutil.GatherAndCount(registry, "gonetsh_operation_duration_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,85 @@
+package netroute
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compartment models a MSFT_NetCompartment as returned by get-netcompartment
+type Compartment struct {
+	ID          int
+	Description string
+	GUID        string
+}
+
+const compartmentProperties = "CompartmentId,CompartmentDescription,CompartmentGuid"
+
+type compartmentJSON struct {
+	CompartmentId          int
+	CompartmentDescription string
+	CompartmentGuid        string
+}
+
+func (shell *shell) GetNetCompartments() ([]Compartment, error) {
+	getCompartmentCmdLine := "get-netcompartment -erroraction Ignore"
+	var raw []compartmentJSON
+	if err := shell.runJSON(getCompartmentCmdLine, compartmentProperties, &raw); err != nil {
+		return nil, err
+	}
+
+	var compartments []Compartment
+	for _, r := range raw {
+		compartments = append(compartments, Compartment{
+			ID:          r.CompartmentId,
+			Description: r.CompartmentDescription,
+			GUID:        r.CompartmentGuid,
+		})
+	}
+
+	return compartments, nil
+}
+
+// InCompartment returns an Interface whose queries and mutations are limited to the interfaces of a compartment.
+// It shares the powershell sessions of shell, so calling Exit on either exits both.
+func (shell *shell) InCompartment(id int) Interface {
+	scoped := *shell
+	scoped.compartmentID = id
+	scoped.loopback = &loopbackCache{}
+	return &scoped
+}
+
+// compartmentPrefix collects the interface indexes of the scoped compartment into $ifs
+func (shell *shell) compartmentPrefix() string {
+	if shell.compartmentID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("$ifs = @(get-netipinterface -IncludeAllCompartments -erroraction Ignore | where-object CompartmentId -eq %v | foreach-object ifIndex); ", shell.compartmentID)
+}
+
+// compartmentFilter keeps only objects on an interface of the scoped compartment
+func (shell *shell) compartmentFilter() string {
+	if shell.compartmentID == 0 {
+		return ""
+	}
+	return " | where-object { $ifs -contains $_.InterfaceIndex }"
+}
+
+// scopeQuery makes a get-net* query span all compartments and then keeps the scoped compartment's results
+func (shell *shell) scopeQuery(cmdLine string) string {
+	if shell.compartmentID == 0 {
+		return cmdLine
+	}
+	parts := strings.SplitN(cmdLine, " ", 2)
+	if len(parts) == 1 {
+		return parts[0] + " -IncludeAllCompartments" + shell.compartmentFilter()
+	}
+	return parts[0] + " -IncludeAllCompartments " + parts[1] + shell.compartmentFilter()
+}
+
+// scopeMutation refuses to change an interface outside of the scoped compartment
+func (shell *shell) scopeMutation(linkIndex int, cmdLine string) string {
+	if shell.compartmentID == 0 {
+		return cmdLine
+	}
+	return fmt.Sprintf("if ($ifs -notcontains %v) { throw 'interface %v is not in compartment %v' }; %v", linkIndex, linkIndex, shell.compartmentID, cmdLine)
+}
@@ -0,0 +1,73 @@
+package netroute
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	scopedPrefix = "$ifs = @(get-netipinterface -IncludeAllCompartments -erroraction Ignore | where-object CompartmentId -eq 3 | foreach-object ifIndex); "
+)
+
+func TestGetNetCompartments(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["ConvertTo-Json -Compress -InputObject @(get-netcompartment -erroraction Ignore | select-object "+compartmentProperties+")"] = fakeResponse{
+		`[{"CompartmentId":1,"CompartmentDescription":"Default Compartment","CompartmentGuid":"{b1062982-2b18-4b4f-b3d5-a78ddb9cdd49}"},{"CompartmentId":3,"CompartmentDescription":"Container 1b5c0ec9e1b0","CompartmentGuid":"{0e2b6c3b-bf0f-4bb4-8c73-3d7fd2a9b5a1}"}]`,
+		"",
+		nil,
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	compartments, err := nr.GetNetCompartments()
+	assert.NoError(t, err)
+	assert.Equal(t, []Compartment{
+		{ID: 1, Description: "Default Compartment", GUID: "{b1062982-2b18-4b4f-b3d5-a78ddb9cdd49}"},
+		{ID: 3, Description: "Container 1b5c0ec9e1b0", GUID: "{0e2b6c3b-bf0f-4bb4-8c73-3d7fd2a9b5a1}"},
+	}, compartments)
+}
+
+func TestInCompartmentQueries(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap[scopedPrefix+"get-netroute -IncludeAllCompartments -erroraction Ignore | where-object { $ifs -contains $_.InterfaceIndex }"] = fakeResponse{
+		GetRouteStdOut,
+		"",
+		nil,
+	}
+	fs.RequestMap[scopedPrefix+"ConvertTo-Json -Compress -InputObject @(get-netipaddress -IncludeAllCompartments -InterfaceIndex 12 -erroraction Ignore | where-object { $ifs -contains $_.InterfaceIndex } | select-object "+ipAddressProperties+")"] = fakeResponse{
+		"[]",
+		"",
+		nil,
+	}
+
+	host := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+	nr := host.InCompartment(3)
+
+	routes, err := nr.GetNetRoutesAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(routes))
+
+	addresses, err := nr.GetNetIPAddresses(IPAddressFilter{InterfaceIndex: 12})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(addresses))
+}
+
+func TestInCompartmentMutations(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap[scopedPrefix+"if ($ifs -notcontains 12) { throw 'interface 12 is not in compartment 3' }; new-netroute -InterfaceIndex 12 -DestinationPrefix 10.0.0.0/8 -NextHop  10.244.0.1 -Verbose"] = fakeResponse{}
+	fs.RequestMap["new-netroute -InterfaceIndex 12 -DestinationPrefix 10.0.0.0/8 -NextHop  10.244.0.1 -Verbose"] = fakeResponse{}
+
+	host := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/8")
+
+	assert.NoError(t, host.InCompartment(3).NewNetRoute(12, subnet, net.ParseIP("10.244.0.1")))
+	assert.NoError(t, host.NewNetRoute(12, subnet, net.ParseIP("10.244.0.1")))
+}
@@ -0,0 +1,160 @@
+package netroute
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/rakelkar/gonetsh/cmdline"
+)
+
+// DNSCacheEntry models a MSFT_DNSClientCache record as returned by get-dnsclientcache
+type DNSCacheEntry struct {
+	// Entry is the name that was looked up, Name the name of the record, which differs for CNAME chains
+	Entry string
+	Name  string
+	// Type is the record type, e.g. A, AAAA or CNAME
+	Type string
+	// Status is Success, NotExist or NoRecords, negative results are cached too
+	Status  string
+	Section string
+	TTL     time.Duration
+	Data    string
+}
+
+// DNSRecord is a record returned by resolve-dnsname
+type DNSRecord struct {
+	Name    string
+	Type    string
+	Section string
+	TTL     time.Duration
+	// Data is the address of A and AAAA records and the target name of others
+	Data string
+}
+
+// dnsRecordTypes names the record types get-dnsclientcache reports as numbers
+var dnsRecordTypes = map[int]string{
+	1:  "A",
+	2:  "NS",
+	5:  "CNAME",
+	6:  "SOA",
+	12: "PTR",
+	15: "MX",
+	16: "TXT",
+	28: "AAAA",
+	33: "SRV",
+}
+
+var dnsStatuses = map[int]string{
+	0:    "Success",
+	9003: "NotExist",
+	9501: "NoRecords",
+}
+
+var dnsSections = map[int]string{
+	1: "Answer",
+	2: "Authority",
+	3: "Additional",
+}
+
+const dnsCacheProperties = "Entry,Name,Type,Status,Section,TimeToLive,Data"
+
+type dnsCacheJSON struct {
+	Entry      string
+	Name       string
+	Type       int
+	Status     int
+	Section    int
+	TimeToLive int
+	Data       string
+}
+
+const dnsRecordProperties = "Name,@{n='Type';e={$_.Type.ToString()}},@{n='Section';e={$_.Section.ToString()}},TTL,IPAddress,NameHost"
+
+type dnsRecordJSON struct {
+	Name      string
+	Type      string
+	Section   string
+	TTL       int
+	IPAddress string
+	NameHost  string
+}
+
+func (shell *shell) GetDNSCache() ([]DNSCacheEntry, error) {
+	getCacheCmdLine := "get-dnsclientcache -erroraction Ignore"
+	var raw []dnsCacheJSON
+	if err := shell.runJSON(getCacheCmdLine, dnsCacheProperties, &raw); err != nil {
+		return nil, err
+	}
+
+	var entries []DNSCacheEntry
+	for _, r := range raw {
+		entries = append(entries, DNSCacheEntry{
+			Entry:   r.Entry,
+			Name:    r.Name,
+			Type:    enumName(dnsRecordTypes, r.Type),
+			Status:  enumName(dnsStatuses, r.Status),
+			Section: enumName(dnsSections, r.Section),
+			TTL:     time.Duration(r.TimeToLive) * time.Second,
+			Data:    r.Data,
+		})
+	}
+
+	return entries, nil
+}
+
+func (shell *shell) ClearDNSCache() error {
+	_, err := shell.mutate("ClearDNSCache", nil, "clear-dnsclientcache")
+
+	return err
+}
+
+// ResolveName queries DNS for name, bypassing the client cache. An empty recordType queries A and AAAA,
+// a nil server uses the servers configured on the host.
+func (shell *shell) ResolveName(name string, recordType string, server net.IP) ([]DNSRecord, error) {
+	if err := cmdline.Text(name); err != nil {
+		return nil, fmt.Errorf("invalid name to resolve: %v", err)
+	}
+	if recordType != "" && cmdline.Word(recordType) != nil {
+		return nil, fmt.Errorf("invalid dns record type %q", recordType)
+	}
+
+	resolveCmdLine := fmt.Sprintf("resolve-dnsname -Name %v -DnsOnly -NoHostsFile", cmdline.PowerShellQuote(name))
+	if recordType != "" {
+		resolveCmdLine += fmt.Sprintf(" -Type %v", recordType)
+	}
+	if server != nil {
+		resolveCmdLine += fmt.Sprintf(" -Server %v", server.String())
+	}
+	resolveCmdLine += " -ErrorAction Stop"
+
+	var raw []dnsRecordJSON
+	if err := shell.runJSON(resolveCmdLine, dnsRecordProperties, &raw); err != nil {
+		return nil, err
+	}
+
+	var records []DNSRecord
+	for _, r := range raw {
+		data := r.IPAddress
+		if data == "" {
+			data = r.NameHost
+		}
+		records = append(records, DNSRecord{
+			Name:    r.Name,
+			Type:    r.Type,
+			Section: r.Section,
+			TTL:     time.Duration(r.TTL) * time.Second,
+			Data:    data,
+		})
+	}
+
+	return records, nil
+}
+
+func enumName(names map[int]string, value int) string {
+	if name, ok := names[value]; ok {
+		return name
+	}
+	return strconv.Itoa(value)
+}
@@ -0,0 +1,77 @@
+package netroute
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDNSCache(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["ConvertTo-Json -Compress -InputObject @(get-dnsclientcache -erroraction Ignore | select-object "+dnsCacheProperties+")"] = fakeResponse{
+		`[{"Entry":"kubernetes.default.svc.cluster.local","Name":"kubernetes.default.svc.cluster.local","Type":1,"Status":0,"Section":1,"TimeToLive":5,"Data":"10.96.0.1"},` +
+			`{"Entry":"missing.svc.cluster.local","Name":"missing.svc.cluster.local","Type":1,"Status":9003,"Section":0,"TimeToLive":60,"Data":""},` +
+			`{"Entry":"odd.example","Name":"odd.example","Type":99,"Status":0,"Section":1,"TimeToLive":1,"Data":"x"}]`,
+		"",
+		nil,
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	entries, err := nr.GetDNSCache()
+	assert.NoError(t, err)
+	assert.Equal(t, []DNSCacheEntry{
+		{Entry: "kubernetes.default.svc.cluster.local", Name: "kubernetes.default.svc.cluster.local", Type: "A", Status: "Success", Section: "Answer", TTL: 5 * time.Second, Data: "10.96.0.1"},
+		{Entry: "missing.svc.cluster.local", Name: "missing.svc.cluster.local", Type: "A", Status: "NotExist", Section: "0", TTL: time.Minute},
+		{Entry: "odd.example", Name: "odd.example", Type: "99", Status: "Success", Section: "Answer", TTL: time.Second, Data: "x"},
+	}, entries)
+}
+
+func TestClearDNSCache(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["clear-dnsclientcache"] = fakeResponse{}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	assert.NoError(t, nr.ClearDNSCache())
+}
+
+func TestResolveName(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["ConvertTo-Json -Compress -InputObject @(resolve-dnsname -Name 'kubernetes.default.svc.cluster.local' -DnsOnly -NoHostsFile -Type A -Server 10.96.0.10 -ErrorAction Stop | select-object "+dnsRecordProperties+")"] = fakeResponse{
+		`[{"Name":"kubernetes.default.svc.cluster.local","Type":"CNAME","Section":"Answer","TTL":5,"IPAddress":null,"NameHost":"api.cluster.local"},{"Name":"api.cluster.local","Type":"A","Section":"Answer","TTL":5,"IPAddress":"10.96.0.1","NameHost":null}]`,
+		"",
+		nil,
+	}
+	fs.RequestMap["ConvertTo-Json -Compress -InputObject @(resolve-dnsname -Name 'missing' -DnsOnly -NoHostsFile -ErrorAction Stop | select-object "+dnsRecordProperties+")"] = fakeResponse{
+		"",
+		"",
+		errors.New("missing : DNS name does not exist"),
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	records, err := nr.ResolveName("kubernetes.default.svc.cluster.local", "A", net.ParseIP("10.96.0.10"))
+	assert.NoError(t, err)
+	assert.Equal(t, []DNSRecord{
+		{Name: "kubernetes.default.svc.cluster.local", Type: "CNAME", Section: "Answer", TTL: 5 * time.Second, Data: "api.cluster.local"},
+		{Name: "api.cluster.local", Type: "A", Section: "Answer", TTL: 5 * time.Second, Data: "10.96.0.1"},
+	}, records)
+
+	_, err = nr.ResolveName("missing", "", nil)
+	assert.Error(t, err)
+
+	_, err = nr.ResolveName("kubernetes.default.svc.cluster.local", "A; Clear-DnsClientCache", nil)
+	assert.Error(t, err)
+	_, err = nr.ResolveName("missing\r\nClear-DnsClientCache", "", nil)
+	assert.Error(t, err)
+}
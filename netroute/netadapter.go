@@ -0,0 +1,104 @@
+package netroute
+
+import (
+	"net"
+
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+// NetAdapter models a MSFT_NetAdapter as returned by get-netadapter
+type NetAdapter struct {
+	Name                 string
+	InterfaceDescription string
+	InterfaceIndex       int
+	InterfaceGuid        string
+	NetLuid              uint64
+	MacAddress           net.HardwareAddr
+	DriverDescription    string
+	DriverName           string
+	// LinkSpeed in bits per second
+	LinkSpeed            uint64
+	Status               string
+	MediaConnectionState string
+	MtuSize              int
+	Virtual              bool
+	Hidden               bool
+}
+
+// AdapterInterface is a netsh interface enriched with the adapter that backs it
+type AdapterInterface struct {
+	netsh.Ipv4Interface
+	// Adapter is nil if no adapter has the interface's index, e.g. for the loopback pseudo-interface
+	Adapter *NetAdapter
+}
+
+const netAdapterProperties = "Name,InterfaceDescription,ifIndex,InterfaceGuid,NetLuid,MacAddress,DriverDescription,DriverName,Speed,Status," +
+	"@{n='MediaConnectionState';e={$_.MediaConnectionState.ToString()}}," +
+	"MtuSize,Virtual,Hidden"
+
+type netAdapterJSON struct {
+	Name                 string
+	InterfaceDescription string
+	IfIndex              int `json:"ifIndex"`
+	InterfaceGuid        string
+	NetLuid              uint64
+	MacAddress           string
+	DriverDescription    string
+	DriverName           string
+	Speed                uint64
+	Status               string
+	MediaConnectionState string
+	MtuSize              int
+	Virtual              bool
+	Hidden               bool
+}
+
+func (shell *shell) GetNetAdapters() ([]NetAdapter, error) {
+	getAdapterCmdLine := "get-netadapter -IncludeHidden -erroraction Ignore"
+	var raw []netAdapterJSON
+	if err := shell.runJSON(getAdapterCmdLine+shell.compartmentFilter(), netAdapterProperties, &raw); err != nil {
+		return nil, err
+	}
+
+	var adapters []NetAdapter
+	for _, r := range raw {
+		// adapters without a hardware address, e.g. some tunnels, report an empty MacAddress
+		mac, _ := net.ParseMAC(r.MacAddress)
+		adapters = append(adapters, NetAdapter{
+			Name:                 r.Name,
+			InterfaceDescription: r.InterfaceDescription,
+			InterfaceIndex:       r.IfIndex,
+			InterfaceGuid:        r.InterfaceGuid,
+			NetLuid:              r.NetLuid,
+			MacAddress:           mac,
+			DriverDescription:    r.DriverDescription,
+			DriverName:           r.DriverName,
+			LinkSpeed:            r.Speed,
+			Status:               r.Status,
+			MediaConnectionState: r.MediaConnectionState,
+			MtuSize:              r.MtuSize,
+			Virtual:              r.Virtual,
+			Hidden:               r.Hidden,
+		})
+	}
+
+	return adapters, nil
+}
+
+// JoinAdapters enriches netsh interfaces with the adapters that share their interface index
+func JoinAdapters(interfaces []netsh.Ipv4Interface, adapters []NetAdapter) []AdapterInterface {
+	byIndex := make(map[int]*NetAdapter, len(adapters))
+	for i := range adapters {
+		byIndex[adapters[i].InterfaceIndex] = &adapters[i]
+	}
+
+	joined := make([]AdapterInterface, 0, len(interfaces))
+	for _, iface := range interfaces {
+		joined = append(joined, AdapterInterface{
+			Ipv4Interface: iface,
+			Adapter:       byIndex[iface.Idx],
+		})
+	}
+
+	return joined
+}
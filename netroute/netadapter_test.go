@@ -0,0 +1,71 @@
+package netroute
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+const (
+	GetNetAdaptersStdOut = `[{"Name":"Ethernet","InterfaceDescription":"Microsoft Hyper-V Network Adapter","ifIndex":6,"InterfaceGuid":"{A1B2C3D4-0000-1111-2222-333344445555}","NetLuid":1689399632855040,"MacAddress":"00-15-5D-01-02-03","DriverDescription":"Microsoft Hyper-V Network Adapter","DriverName":"\\SystemRoot\\System32\\drivers\\netvsc.sys","Speed":10000000000,"Status":"Up","MediaConnectionState":"Connected","MtuSize":1500,"Virtual":false,"Hidden":false},` +
+		`{"Name":"vEthernet (nat)","InterfaceDescription":"Hyper-V Virtual Ethernet Adapter","ifIndex":12,"InterfaceGuid":"{B1B2C3D4-0000-1111-2222-333344445555}","NetLuid":1689399632855041,"MacAddress":"","DriverDescription":"Hyper-V Virtual Ethernet Adapter","DriverName":"\\SystemRoot\\System32\\drivers\\VmsProxyHNic.sys","Speed":0,"Status":"Disconnected","MediaConnectionState":"Disconnected","MtuSize":1500,"Virtual":true,"Hidden":false}]`
+)
+
+func TestGetNetAdapters(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["ConvertTo-Json -Compress -InputObject @(get-netadapter -IncludeHidden -erroraction Ignore | select-object "+netAdapterProperties+")"] = fakeResponse{
+		GetNetAdaptersStdOut,
+		"",
+		nil,
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	adapters, err := nr.GetNetAdapters()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(adapters))
+
+	mac, _ := net.ParseMAC("00:15:5d:01:02:03")
+	assert.Equal(t, NetAdapter{
+		Name:                 "Ethernet",
+		InterfaceDescription: "Microsoft Hyper-V Network Adapter",
+		InterfaceIndex:       6,
+		InterfaceGuid:        "{A1B2C3D4-0000-1111-2222-333344445555}",
+		NetLuid:              1689399632855040,
+		MacAddress:           mac,
+		DriverDescription:    "Microsoft Hyper-V Network Adapter",
+		DriverName:           `\SystemRoot\System32\drivers\netvsc.sys`,
+		LinkSpeed:            10000000000,
+		Status:               "Up",
+		MediaConnectionState: "Connected",
+		MtuSize:              1500,
+	}, adapters[0])
+	assert.Nil(t, adapters[1].MacAddress)
+	assert.Equal(t, "Disconnected", adapters[1].MediaConnectionState)
+	assert.True(t, adapters[1].Virtual)
+}
+
+func TestJoinAdapters(t *testing.T) {
+	interfaces := []netsh.Ipv4Interface{
+		{Idx: 6, Name: "Ethernet"},
+		{Idx: 1, Name: "Loopback Pseudo-Interface 1"},
+	}
+	adapters := []NetAdapter{
+		{InterfaceIndex: 6, Name: "Ethernet", InterfaceGuid: "{A1B2C3D4-0000-1111-2222-333344445555}"},
+		{InterfaceIndex: 12, Name: "vEthernet (nat)"},
+	}
+
+	joined := JoinAdapters(interfaces, adapters)
+
+	assert.Equal(t, 2, len(joined))
+	assert.Equal(t, "Ethernet", joined[0].Name)
+	assert.Equal(t, "{A1B2C3D4-0000-1111-2222-333344445555}", joined[0].Adapter.InterfaceGuid)
+	assert.Equal(t, "Loopback Pseudo-Interface 1", joined[1].Name)
+	assert.Nil(t, joined[1].Adapter)
+}
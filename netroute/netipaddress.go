@@ -0,0 +1,203 @@
+package netroute
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rakelkar/gonetsh/cmdline"
+)
+
+// InfiniteLifetime is reported for addresses whose lifetime never expires
+const InfiniteLifetime = time.Duration(math.MaxInt64)
+
+// IPAddress models a MSFT_NetIPAddress as returned by get-netipaddress
+type IPAddress struct {
+	IPAddress         net.IP
+	InterfaceIndex    int
+	InterfaceAlias    string
+	PrefixLength      int
+	AddressFamily     string
+	Type              string
+	PrefixOrigin      string
+	SuffixOrigin      string
+	AddressState      string
+	SkipAsSource      bool
+	ValidLifetime     time.Duration
+	PreferredLifetime time.Duration
+}
+
+// IPAddressFilter narrows get-netipaddress results, zero values match everything
+type IPAddressFilter struct {
+	InterfaceIndex int
+	InterfaceAlias string
+	// AddressFamily is either IPv4 or IPv6
+	AddressFamily string
+	IPAddress     net.IP
+}
+
+// ipAddressProperties projects enums to their names and lifetimes to seconds so they survive ConvertTo-Json
+const ipAddressProperties = "IPAddress,InterfaceIndex,InterfaceAlias,PrefixLength," +
+	"@{n='AddressFamily';e={$_.AddressFamily.ToString()}}," +
+	"@{n='Type';e={$_.Type.ToString()}}," +
+	"@{n='PrefixOrigin';e={$_.PrefixOrigin.ToString()}}," +
+	"@{n='SuffixOrigin';e={$_.SuffixOrigin.ToString()}}," +
+	"@{n='AddressState';e={$_.AddressState.ToString()}}," +
+	"@{n='SkipAsSource';e={[bool]$_.SkipAsSource}}," +
+	"@{n='ValidLifetime';e={$_.ValidLifetime.TotalSeconds}}," +
+	"@{n='PreferredLifetime';e={$_.PreferredLifetime.TotalSeconds}}"
+
+type ipAddressJSON struct {
+	IPAddress         string
+	InterfaceIndex    int
+	InterfaceAlias    string
+	PrefixLength      int
+	AddressFamily     string
+	Type              string
+	PrefixOrigin      string
+	SuffixOrigin      string
+	AddressState      string
+	SkipAsSource      bool
+	ValidLifetime     *float64
+	PreferredLifetime *float64
+}
+
+func (shell *shell) GetNetIPAddresses(filter IPAddressFilter) ([]IPAddress, error) {
+	args, err := filter.args()
+	if err != nil {
+		return nil, err
+	}
+	getAddressCmdLine := "get-netipaddress" + args + " -erroraction Ignore"
+	var raw []ipAddressJSON
+	if err := shell.runJSON(shell.scopeQuery(getAddressCmdLine), ipAddressProperties, &raw); err != nil {
+		return nil, err
+	}
+
+	var addresses []IPAddress
+	for _, r := range raw {
+		// link-local addresses carry a zone suffix such as %12
+		ip := net.ParseIP(strings.SplitN(r.IPAddress, "%", 2)[0])
+		if ip == nil {
+			continue
+		}
+		addresses = append(addresses, IPAddress{
+			IPAddress:         ip,
+			InterfaceIndex:    r.InterfaceIndex,
+			InterfaceAlias:    r.InterfaceAlias,
+			PrefixLength:      r.PrefixLength,
+			AddressFamily:     r.AddressFamily,
+			Type:              r.Type,
+			PrefixOrigin:      r.PrefixOrigin,
+			SuffixOrigin:      r.SuffixOrigin,
+			AddressState:      r.AddressState,
+			SkipAsSource:      r.SkipAsSource,
+			ValidLifetime:     secondsToDuration(r.ValidLifetime),
+			PreferredLifetime: secondsToDuration(r.PreferredLifetime),
+		})
+	}
+
+	return addresses, nil
+}
+
+func (shell *shell) NewNetIPAddress(linkIndex int, address net.IP, prefixLength int) error {
+	if err := validateIPAddress(linkIndex, address); err != nil {
+		return err
+	}
+	bits := 8 * net.IPv6len
+	if address.To4() != nil {
+		bits = 8 * net.IPv4len
+	}
+	if prefixLength <= 0 || prefixLength > bits {
+		return fmt.Errorf("invalid prefix length %v for %v", prefixLength, address)
+	}
+	newAddressCmdLine := fmt.Sprintf("new-netipaddress -InterfaceIndex %v -IPAddress %v -PrefixLength %v -Verbose", linkIndex, address.String(), prefixLength)
+	_, err := shell.mutate("NewNetIPAddress", []string{strconv.Itoa(linkIndex), address.String(), strconv.Itoa(prefixLength)}, shell.scopeMutation(linkIndex, newAddressCmdLine))
+
+	return err
+}
+
+func (shell *shell) RemoveNetIPAddress(linkIndex int, address net.IP) error {
+	if err := validateIPAddress(linkIndex, address); err != nil {
+		return err
+	}
+	removeAddressCmdLine := fmt.Sprintf("remove-netipaddress -InterfaceIndex %v -IPAddress %v -Verbose -Confirm:$false", linkIndex, address.String())
+	_, err := shell.mutate("RemoveNetIPAddress", []string{strconv.Itoa(linkIndex), address.String()}, shell.scopeMutation(linkIndex, removeAddressCmdLine))
+
+	return err
+}
+
+// validateIPAddress fails on an interface index that is not positive or a missing or malformed address
+func validateIPAddress(linkIndex int, address net.IP) error {
+	if linkIndex <= 0 {
+		return fmt.Errorf("invalid interface index %v", linkIndex)
+	}
+	return cmdline.IP(address)
+}
+
+// args renders the filter as get-netipaddress parameters, failing on values that are not safe to pass to powershell
+func (filter IPAddressFilter) args() (string, error) {
+	if filter.AddressFamily != "" && cmdline.Word(filter.AddressFamily) != nil {
+		return "", fmt.Errorf("invalid address filter %q", filter.AddressFamily)
+	}
+
+	var args []string
+	if filter.InterfaceIndex != 0 {
+		args = append(args, fmt.Sprintf("-InterfaceIndex %v", filter.InterfaceIndex))
+	}
+	if filter.InterfaceAlias != "" {
+		if err := cmdline.Name(filter.InterfaceAlias); err != nil {
+			return "", fmt.Errorf("invalid address filter: %v", err)
+		}
+		args = append(args, "-InterfaceAlias "+cmdline.PowerShellQuote(filter.InterfaceAlias))
+	}
+	if filter.AddressFamily != "" {
+		args = append(args, fmt.Sprintf("-AddressFamily %v", filter.AddressFamily))
+	}
+	if filter.IPAddress != nil {
+		args = append(args, fmt.Sprintf("-IPAddress %v", filter.IPAddress.String()))
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+	return " " + strings.Join(args, " "), nil
+}
+
+// runJSON runs the read only cmdLine, projects the given properties and decodes the resulting json array into v
+func (shell *shell) runJSON(cmdLine string, properties string, v interface{}) error {
+	jsonCmdLine := fmt.Sprintf("ConvertTo-Json -Compress -InputObject @(%v | select-object %v)", cmdLine, properties)
+	stdout, err := shell.query(jsonCmdLine)
+	if err != nil {
+		return err
+	}
+
+	return decodeJSONList(stdout, v)
+}
+
+// decodeJSONList decodes a ConvertTo-Json array, tolerating empty output and single objects
+func decodeJSONList(stdout string, v interface{}) error {
+	stdout = strings.TrimSpace(stdout)
+	if stdout == "" {
+		stdout = "[]"
+	}
+	if !strings.HasPrefix(stdout, "[") {
+		stdout = "[" + stdout + "]"
+	}
+	if err := json.Unmarshal([]byte(stdout), v); err != nil {
+		return fmt.Errorf("failed to parse powershell output: %v: %v", err, stdout)
+	}
+	return nil
+}
+
+func secondsToDuration(seconds *float64) time.Duration {
+	if seconds == nil {
+		return 0
+	}
+	if *seconds >= InfiniteLifetime.Seconds() {
+		return InfiniteLifetime
+	}
+	return time.Duration(*seconds * float64(time.Second))
+}
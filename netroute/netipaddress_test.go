@@ -0,0 +1,129 @@
+package netroute
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	GetIPAddressesStdOut = `[{"IPAddress":"fe80::1c2d:3e4f:5a6b:7c8d%12","InterfaceIndex":12,"InterfaceAlias":"vEthernet (nat)","PrefixLength":64,"AddressFamily":"IPv6","Type":"Unicast","PrefixOrigin":"WellKnown","SuffixOrigin":"Link","AddressState":"Preferred","SkipAsSource":false,"ValidLifetime":922337203685.47754,"PreferredLifetime":922337203685.47754},` +
+		`{"IPAddress":"172.20.16.1","InterfaceIndex":12,"InterfaceAlias":"vEthernet (nat)","PrefixLength":20,"AddressFamily":"IPv4","Type":"Unicast","PrefixOrigin":"Manual","SuffixOrigin":"Manual","AddressState":"Preferred","SkipAsSource":true,"ValidLifetime":3600,"PreferredLifetime":1800.5}]`
+	GetIPAddressSingleStdOut = `{"IPAddress":"10.0.0.4","InterfaceIndex":3,"InterfaceAlias":"Ethernet","PrefixLength":24,"AddressFamily":"IPv4","Type":"Unicast","PrefixOrigin":"Dhcp","SuffixOrigin":"Dhcp","AddressState":"Preferred","SkipAsSource":false,"ValidLifetime":null,"PreferredLifetime":null}`
+)
+
+func TestGetNetIPAddresses(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["ConvertTo-Json -Compress -InputObject @(get-netipaddress -InterfaceIndex 12 -erroraction Ignore | select-object "+ipAddressProperties+")"] = fakeResponse{
+		GetIPAddressesStdOut,
+		"",
+		nil,
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	addresses, err := nr.GetNetIPAddresses(IPAddressFilter{InterfaceIndex: 12})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(addresses))
+	assert.Equal(t, "fe80::1c2d:3e4f:5a6b:7c8d", addresses[0].IPAddress.String())
+	assert.Equal(t, InfiniteLifetime, addresses[0].ValidLifetime)
+	assert.Equal(t, IPAddress{
+		IPAddress:         net.ParseIP("172.20.16.1"),
+		InterfaceIndex:    12,
+		InterfaceAlias:    "vEthernet (nat)",
+		PrefixLength:      20,
+		AddressFamily:     "IPv4",
+		Type:              "Unicast",
+		PrefixOrigin:      "Manual",
+		SuffixOrigin:      "Manual",
+		AddressState:      "Preferred",
+		SkipAsSource:      true,
+		ValidLifetime:     time.Hour,
+		PreferredLifetime: 30*time.Minute + 500*time.Millisecond,
+	}, addresses[1])
+}
+
+func TestGetNetIPAddressesSingleAndEmpty(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.DefaultResponse = &fakeResponse{GetIPAddressSingleStdOut, "", nil}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	addresses, err := nr.GetNetIPAddresses(IPAddressFilter{InterfaceAlias: "Ethernet", AddressFamily: "IPv4"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(addresses))
+	assert.Equal(t, "10.0.0.4", addresses[0].IPAddress.String())
+	assert.Equal(t, time.Duration(0), addresses[0].ValidLifetime)
+
+	fs.DefaultResponse = &fakeResponse{"[]\r\n", "", nil}
+	addresses, err = nr.GetNetIPAddresses(IPAddressFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(addresses))
+
+	fs.DefaultResponse = &fakeResponse{"not json", "", nil}
+	_, err = nr.GetNetIPAddresses(IPAddressFilter{})
+	assert.Error(t, err)
+}
+
+func TestIPAddressFilterArgs(t *testing.T) {
+	args, err := IPAddressFilter{}.args()
+	assert.NoError(t, err)
+	assert.Equal(t, "", args)
+	args, err = IPAddressFilter{
+		InterfaceIndex: 3,
+		InterfaceAlias: "Wi-Fi",
+		AddressFamily:  "IPv6",
+		IPAddress:      net.ParseIP("fd00::1"),
+	}.args()
+	assert.NoError(t, err)
+	assert.Equal(t, " -InterfaceIndex 3 -InterfaceAlias 'Wi-Fi' -AddressFamily IPv6 -IPAddress fd00::1", args)
+
+	_, err = IPAddressFilter{AddressFamily: "IPv4 | Remove-NetIPAddress"}.args()
+	assert.Error(t, err)
+}
+
+func TestNewAndRemoveNetIPAddress(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["new-netipaddress -InterfaceIndex 7 -IPAddress 10.1.2.3 -PrefixLength 24 -Verbose"] = fakeResponse{}
+	fs.RequestMap["remove-netipaddress -InterfaceIndex 7 -IPAddress 10.1.2.3 -Verbose -Confirm:$false"] = fakeResponse{}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	assert.NoError(t, nr.NewNetIPAddress(7, net.ParseIP("10.1.2.3"), 24))
+	assert.NoError(t, nr.RemoveNetIPAddress(7, net.ParseIP("10.1.2.3")))
+}
+
+func TestNetIPAddressValidation(t *testing.T) {
+	nr := &shell{
+		pool: newSingleSessionPool(NewFakeShell(t), 0),
+	}
+
+	for name, tc := range map[string]struct {
+		linkIndex    int
+		address      net.IP
+		prefixLength int
+	}{
+		"nil address":          {7, nil, 24},
+		"malformed address":    {7, net.IP{10, 1, 2}, 24},
+		"zero interface index": {0, net.ParseIP("10.1.2.3"), 24},
+		"negative index":       {-3, net.ParseIP("10.1.2.3"), 24},
+		"ipv4 prefix too long": {7, net.ParseIP("10.1.2.3"), 33},
+		"ipv6 prefix too long": {7, net.ParseIP("fd00::1"), 129},
+		"zero prefix":          {7, net.ParseIP("10.1.2.3"), 0},
+		"negative prefix":      {7, net.ParseIP("fd00::1"), -64},
+	} {
+		assert.Error(t, nr.NewNetIPAddress(tc.linkIndex, tc.address, tc.prefixLength), name)
+	}
+
+	assert.Error(t, nr.RemoveNetIPAddress(7, nil))
+	assert.Error(t, nr.RemoveNetIPAddress(0, net.ParseIP("10.1.2.3")))
+}
@@ -0,0 +1,243 @@
+package netroute
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rakelkar/gonetsh/cmdline"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+// NetNat models a MSFT_NetNat as returned by get-netnat
+type NetNat struct {
+	Name                             string
+	InternalIPInterfaceAddressPrefix *net.IPNet
+	Active                           bool
+}
+
+// NetNatStaticMapping models a MSFT_NetNatStaticMapping as returned by get-netnatstaticmapping
+type NetNatStaticMapping struct {
+	StaticMappingID int
+	NatName         string
+	// Protocol is either TCP or UDP
+	Protocol          string
+	ExternalIPAddress net.IP
+	ExternalPort      int
+	InternalIPAddress net.IP
+	InternalPort      int
+	Active            bool
+}
+
+// NatConflict is a static mapping that competes with a portproxy rule for the same listen port
+type NatConflict struct {
+	Mapping   NetNatStaticMapping
+	PortProxy netsh.PortProxyRule
+}
+
+const netNatProperties = "Name,InternalIPInterfaceAddressPrefix,Active"
+
+type netNatJSON struct {
+	Name                             string
+	InternalIPInterfaceAddressPrefix string
+	Active                           bool
+}
+
+const netNatStaticMappingProperties = "StaticMappingID,NatName,@{n='Protocol';e={$_.Protocol.ToString()}},ExternalIPAddress,ExternalPort,InternalIPAddress,InternalPort,Active"
+
+type netNatStaticMappingJSON struct {
+	StaticMappingID   int
+	NatName           string
+	Protocol          string
+	ExternalIPAddress string
+	ExternalPort      int
+	InternalIPAddress string
+	InternalPort      int
+	Active            bool
+}
+
+func (shell *shell) GetNetNats() ([]NetNat, error) {
+	getNatCmdLine := "get-netnat -erroraction Ignore"
+	var raw []netNatJSON
+	if err := shell.runJSON(getNatCmdLine, netNatProperties, &raw); err != nil {
+		return nil, err
+	}
+
+	var nats []NetNat
+	for _, r := range raw {
+		// the prefix is empty for nats created with an external prefix only
+		_, prefix, _ := net.ParseCIDR(r.InternalIPInterfaceAddressPrefix)
+		nats = append(nats, NetNat{
+			Name:                             r.Name,
+			InternalIPInterfaceAddressPrefix: prefix,
+			Active:                           r.Active,
+		})
+	}
+
+	return nats, nil
+}
+
+func (shell *shell) NewNetNat(name string, internalPrefix *net.IPNet) error {
+	if err := cmdline.Name(name); err != nil {
+		return fmt.Errorf("invalid nat: %v", err)
+	}
+	if err := cmdline.CIDR(internalPrefix); err != nil {
+		return fmt.Errorf("invalid internal prefix of nat [%v]: %v", name, err)
+	}
+
+	newNatCmdLine := fmt.Sprintf("new-netnat -Name %v -InternalIPInterfaceAddressPrefix %v -Verbose", cmdline.PowerShellQuote(name), internalPrefix.String())
+	_, err := shell.mutate("NewNetNat", []string{name, internalPrefix.String()}, newNatCmdLine)
+
+	return err
+}
+
+func (shell *shell) RemoveNetNat(name string) error {
+	if err := cmdline.Name(name); err != nil {
+		return fmt.Errorf("invalid nat: %v", err)
+	}
+
+	removeNatCmdLine := fmt.Sprintf("remove-netnat -Name %v -Verbose -Confirm:$false", cmdline.PowerShellQuote(name))
+	_, err := shell.mutate("RemoveNetNat", []string{name}, removeNatCmdLine)
+
+	return err
+}
+
+func (shell *shell) GetNetNatStaticMappings(natName string) ([]NetNatStaticMapping, error) {
+	getMappingCmdLine := "get-netnatstaticmapping -erroraction Ignore"
+	if natName != "" {
+		if err := cmdline.Name(natName); err != nil {
+			return nil, fmt.Errorf("invalid nat: %v", err)
+		}
+		getMappingCmdLine = fmt.Sprintf("get-netnatstaticmapping -NatName %v -erroraction Ignore", cmdline.PowerShellQuote(natName))
+	}
+	var raw []netNatStaticMappingJSON
+	if err := shell.runJSON(getMappingCmdLine, netNatStaticMappingProperties, &raw); err != nil {
+		return nil, err
+	}
+
+	var mappings []NetNatStaticMapping
+	for _, r := range raw {
+		mappings = append(mappings, NetNatStaticMapping{
+			StaticMappingID:   r.StaticMappingID,
+			NatName:           r.NatName,
+			Protocol:          r.Protocol,
+			ExternalIPAddress: net.ParseIP(r.ExternalIPAddress),
+			ExternalPort:      r.ExternalPort,
+			InternalIPAddress: net.ParseIP(r.InternalIPAddress),
+			InternalPort:      r.InternalPort,
+			Active:            r.Active,
+		})
+	}
+
+	return mappings, nil
+}
+
+// EnsureNetNatStaticMapping checks if the mapping exists, if not creates it, replacing a mapping of the same
+// external address and port that points elsewhere. Returns true if anything was changed.
+func (shell *shell) EnsureNetNatStaticMapping(mapping NetNatStaticMapping) (bool, error) {
+	if err := mapping.validate(); err != nil {
+		return false, err
+	}
+
+	existing, err := shell.GetNetNatStaticMappings(mapping.NatName)
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range existing {
+		if !strings.EqualFold(m.Protocol, mapping.Protocol) || !m.ExternalIPAddress.Equal(mapping.ExternalIPAddress) || m.ExternalPort != mapping.ExternalPort {
+			continue
+		}
+		if m.InternalIPAddress.Equal(mapping.InternalIPAddress) && m.InternalPort == mapping.InternalPort {
+			return false, nil
+		}
+		if err := shell.RemoveNetNatStaticMapping(m.NatName, m.StaticMappingID); err != nil {
+			return false, err
+		}
+	}
+
+	addMappingCmdLine := fmt.Sprintf("add-netnatstaticmapping -NatName %v -Protocol %v -ExternalIPAddress %v -ExternalPort %v -InternalIPAddress %v -InternalPort %v -Verbose",
+		cmdline.PowerShellQuote(mapping.NatName), strings.ToUpper(mapping.Protocol), mapping.ExternalIPAddress.String(), mapping.ExternalPort, mapping.InternalIPAddress.String(), mapping.InternalPort)
+	err = shell.retry.Do(func() error {
+		_, err := shell.mutate("EnsureNetNatStaticMapping", mapping.params(), addMappingCmdLine)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (shell *shell) RemoveNetNatStaticMapping(natName string, staticMappingID int) error {
+	if err := cmdline.Name(natName); err != nil {
+		return fmt.Errorf("invalid nat: %v", err)
+	}
+
+	removeMappingCmdLine := fmt.Sprintf("remove-netnatstaticmapping -NatName %v -StaticMappingID %v -Verbose -Confirm:$false", cmdline.PowerShellQuote(natName), staticMappingID)
+	_, err := shell.mutate("RemoveNetNatStaticMapping", []string{natName, strconv.Itoa(staticMappingID)}, removeMappingCmdLine)
+
+	return err
+}
+
+// params renders the mapping for a dry-run entry
+func (mapping NetNatStaticMapping) params() []string {
+	return []string{mapping.NatName, mapping.Protocol, mapping.ExternalIPAddress.String(), strconv.Itoa(mapping.ExternalPort), mapping.InternalIPAddress.String(), strconv.Itoa(mapping.InternalPort)}
+}
+
+// validate checks every field of mapping that ends up in the add-netnatstaticmapping script
+func (mapping NetNatStaticMapping) validate() error {
+	if err := cmdline.Name(mapping.NatName); err != nil {
+		return fmt.Errorf("invalid nat: %v", err)
+	}
+	if err := cmdline.Word(mapping.Protocol); err != nil {
+		return fmt.Errorf("invalid protocol of mapping on nat [%v]: %v", mapping.NatName, err)
+	}
+	for _, ip := range []net.IP{mapping.ExternalIPAddress, mapping.InternalIPAddress} {
+		if err := cmdline.IP(ip); err != nil {
+			return fmt.Errorf("invalid address of mapping on nat [%v]: %v", mapping.NatName, err)
+		}
+	}
+	for _, port := range []int{mapping.ExternalPort, mapping.InternalPort} {
+		if err := cmdline.Port(port); err != nil {
+			return fmt.Errorf("invalid port of mapping on nat [%v]: %v", mapping.NatName, err)
+		}
+	}
+	return nil
+}
+
+// FindPortProxyConflicts returns the TCP static mappings whose external port is also a portproxy listen port on an
+// overlapping address. WinNAT and portproxy both claim such ports and which one wins is not well defined.
+func FindPortProxyConflicts(mappings []NetNatStaticMapping, rules []netsh.PortProxyRule) []NatConflict {
+	var conflicts []NatConflict
+	for _, mapping := range mappings {
+		// portproxy only forwards tcp
+		if !strings.EqualFold(mapping.Protocol, "TCP") {
+			continue
+		}
+		for _, rule := range rules {
+			if rule.ListenPort != mapping.ExternalPort {
+				continue
+			}
+			if !addressesOverlap(rule.ListenAddress, mapping.ExternalIPAddress) {
+				continue
+			}
+			conflicts = append(conflicts, NatConflict{Mapping: mapping, PortProxy: rule})
+		}
+	}
+
+	return conflicts
+}
+
+// addressesOverlap returns true if a portproxy listen address and a nat external address can match the same packet
+func addressesOverlap(listenAddress string, external net.IP) bool {
+	listen := net.ParseIP(listenAddress)
+	if listenAddress == "*" || listen == nil || listen.IsUnspecified() {
+		return true
+	}
+	if external == nil || external.IsUnspecified() {
+		return true
+	}
+	return listen.Equal(external)
+}
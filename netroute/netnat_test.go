@@ -0,0 +1,128 @@
+package netroute
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+const (
+	GetNetNatStaticMappingsCommand = "ConvertTo-Json -Compress -InputObject @(get-netnatstaticmapping -NatName 'nat' -erroraction Ignore | select-object " + netNatStaticMappingProperties + ")"
+	GetNetNatStaticMappingsStdOut  = `[{"StaticMappingID":0,"NatName":"nat","Protocol":"TCP","ExternalIPAddress":"0.0.0.0","ExternalPort":8080,"InternalIPAddress":"172.16.0.2","InternalPort":80,"Active":true},` +
+		`{"StaticMappingID":1,"NatName":"nat","Protocol":"UDP","ExternalIPAddress":"0.0.0.0","ExternalPort":53,"InternalIPAddress":"172.16.0.3","InternalPort":53,"Active":true}]`
+)
+
+func TestGetNetNats(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["ConvertTo-Json -Compress -InputObject @(get-netnat -erroraction Ignore | select-object "+netNatProperties+")"] = fakeResponse{
+		`{"Name":"nat","InternalIPInterfaceAddressPrefix":"172.16.0.0/12","Active":true}`,
+		"",
+		nil,
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	nats, err := nr.GetNetNats()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nats))
+	assert.Equal(t, "nat", nats[0].Name)
+	assert.Equal(t, "172.16.0.0/12", nats[0].InternalIPInterfaceAddressPrefix.String())
+	assert.True(t, nats[0].Active)
+}
+
+func TestNewAndRemoveNetNat(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["new-netnat -Name 'nat' -InternalIPInterfaceAddressPrefix 172.16.0.0/12 -Verbose"] = fakeResponse{}
+	fs.RequestMap["remove-netnat -Name 'nat' -Verbose -Confirm:$false"] = fakeResponse{}
+	fs.RequestMap["remove-netnat -Name 'nat''; Remove-NetNat -Confirm:$false; ''' -Verbose -Confirm:$false"] = fakeResponse{}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+	_, prefix, _ := net.ParseCIDR("172.16.0.0/12")
+
+	assert.NoError(t, nr.NewNetNat("nat", prefix))
+	assert.NoError(t, nr.RemoveNetNat("nat"))
+
+	// quotes stay inside the name literal, line breaks and prefixes that do not match their mask are refused
+	assert.NoError(t, nr.RemoveNetNat("nat'; Remove-NetNat -Confirm:$false; '"))
+	assert.Error(t, nr.RemoveNetNat("nat\nRemove-NetNat"))
+	assert.Error(t, nr.NewNetNat("nat", &net.IPNet{IP: net.ParseIP("fd00::"), Mask: net.CIDRMask(12, 32)}))
+}
+
+func TestGetNetNatStaticMappings(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap[GetNetNatStaticMappingsCommand] = fakeResponse{GetNetNatStaticMappingsStdOut, "", nil}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	mappings, err := nr.GetNetNatStaticMappings("nat")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(mappings))
+	assert.Equal(t, NetNatStaticMapping{
+		StaticMappingID:   0,
+		NatName:           "nat",
+		Protocol:          "TCP",
+		ExternalIPAddress: net.ParseIP("0.0.0.0"),
+		ExternalPort:      8080,
+		InternalIPAddress: net.ParseIP("172.16.0.2"),
+		InternalPort:      80,
+		Active:            true,
+	}, mappings[0])
+}
+
+func TestEnsureNetNatStaticMapping(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap[GetNetNatStaticMappingsCommand] = fakeResponse{GetNetNatStaticMappingsStdOut, "", nil}
+	fs.RequestMap["remove-netnatstaticmapping -NatName 'nat' -StaticMappingID 0 -Verbose -Confirm:$false"] = fakeResponse{}
+	fs.RequestMap["add-netnatstaticmapping -NatName 'nat' -Protocol TCP -ExternalIPAddress 0.0.0.0 -ExternalPort 8080 -InternalIPAddress 172.16.0.9 -InternalPort 80 -Verbose"] = fakeResponse{}
+	fs.RequestMap["add-netnatstaticmapping -NatName 'nat' -Protocol TCP -ExternalIPAddress 0.0.0.0 -ExternalPort 9090 -InternalIPAddress 172.16.0.2 -InternalPort 90 -Verbose"] = fakeResponse{}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	// already present
+	changed, err := nr.EnsureNetNatStaticMapping(NetNatStaticMapping{
+		NatName: "nat", Protocol: "tcp", ExternalIPAddress: net.ParseIP("0.0.0.0"), ExternalPort: 8080, InternalIPAddress: net.ParseIP("172.16.0.2"), InternalPort: 80,
+	})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// points elsewhere, replaced
+	changed, err = nr.EnsureNetNatStaticMapping(NetNatStaticMapping{
+		NatName: "nat", Protocol: "tcp", ExternalIPAddress: net.ParseIP("0.0.0.0"), ExternalPort: 8080, InternalIPAddress: net.ParseIP("172.16.0.9"), InternalPort: 80,
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	// new
+	changed, err = nr.EnsureNetNatStaticMapping(NetNatStaticMapping{
+		NatName: "nat", Protocol: "TCP", ExternalIPAddress: net.ParseIP("0.0.0.0"), ExternalPort: 9090, InternalIPAddress: net.ParseIP("172.16.0.2"), InternalPort: 90,
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestFindPortProxyConflicts(t *testing.T) {
+	mappings := []NetNatStaticMapping{
+		{NatName: "nat", Protocol: "TCP", ExternalIPAddress: net.ParseIP("0.0.0.0"), ExternalPort: 8080},
+		{NatName: "nat", Protocol: "UDP", ExternalIPAddress: net.ParseIP("0.0.0.0"), ExternalPort: 9090},
+		{NatName: "nat", Protocol: "TCP", ExternalIPAddress: net.ParseIP("10.0.0.4"), ExternalPort: 443},
+	}
+	rules := []netsh.PortProxyRule{
+		{Protocol: "v4tov4", ListenAddress: "10.0.0.4", ListenPort: 8080, ConnectAddress: "10.244.0.2", ConnectPort: 80},
+		{Protocol: "v4tov4", ListenAddress: "*", ListenPort: 9090, ConnectAddress: "10.244.0.3", ConnectPort: 90},
+		{Protocol: "v4tov4", ListenAddress: "10.0.0.5", ListenPort: 443, ConnectAddress: "10.244.0.4", ConnectPort: 443},
+	}
+
+	conflicts := FindPortProxyConflicts(mappings, rules)
+	assert.Equal(t, []NatConflict{{Mapping: mappings[0], PortProxy: rules[0]}}, conflicts)
+}
@@ -3,13 +3,19 @@ package netroute
 import (
 	"bufio"
 	"bytes"
+	"io"
 	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	ps "github.com/antoninbas/go-powershell"
 	psbe "github.com/antoninbas/go-powershell/backend"
+	"github.com/rakelkar/gonetsh/cmdline"
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/rakelkar/gonetsh/observer"
+	"github.com/rakelkar/gonetsh/retry"
 
 	"fmt"
 	"math/big"
@@ -17,18 +23,84 @@ import (
 
 // Interface is an injectable interface for running MSFT_NetRoute commands. Implementations must be goroutine-safe.
 type Interface interface {
-	// Get all net routes on the host
+	// Get all net routes on the host, with their metrics but without alias, family, protocol or store
 	GetNetRoutesAll() ([]Route, error)
 
-	// Get net routes by link and destination subnet
+	// Get net routes by link and destination subnet, populated like GetNetRoutesAll
 	GetNetRoutes(linkIndex int, destinationSubnet *net.IPNet) ([]Route, error)
 
-	// Create a new route
+	// Get net routes matching the filter
+	GetNetRoutesFiltered(filter RouteFilter) ([]Route, error)
+
+	// Create a new route, a nil gatewayAddress creates an on-link route
 	NewNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error
 
-	// Remove an existing route
+	// Remove an existing route, a nil gatewayAddress removes the on-link route
 	RemoveNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error
 
+	// Apply route additions and removals in bulk, returning one result per op
+	ApplyRouteBatch(ops []RouteOp) ([]RouteOpResult, error)
+
+	// Create one route per next hop of a multipath route, removing them again if one fails
+	NewNetRouteMultipath(route MultipathRoute) error
+
+	// Remove the routes of every next hop of a multipath route
+	RemoveNetRouteMultipath(route MultipathRoute) error
+
+	// Create a route that drops all traffic to the destination
+	NewBlackholeRoute(destinationSubnet *net.IPNet) error
+
+	// Remove a route created by NewBlackholeRoute
+	RemoveBlackholeRoute(destinationSubnet *net.IPNet) error
+
+	// Get the ifIndex of the loopback interface, the one blackhole routes point to
+	GetLoopbackInterfaceIndex() (int, error)
+
+	// Get ip addresses matching the filter
+	GetNetIPAddresses(filter IPAddressFilter) ([]IPAddress, error)
+
+	// Assign a new ip address to an interface
+	NewNetIPAddress(linkIndex int, address net.IP, prefixLength int) error
+
+	// Remove an ip address from an interface
+	RemoveNetIPAddress(linkIndex int, address net.IP) error
+
+	// Get the network adapters on the host, including hidden ones
+	GetNetAdapters() ([]NetAdapter, error)
+
+	// Get the WinNAT instances on the host
+	GetNetNats() ([]NetNat, error)
+
+	// Create a WinNAT instance for an internal prefix
+	NewNetNat(name string, internalPrefix *net.IPNet) error
+
+	// Remove a WinNAT instance
+	RemoveNetNat(name string) error
+
+	// Get the static mappings of a WinNAT instance, or of all instances if natName is empty
+	GetNetNatStaticMappings(natName string) ([]NetNatStaticMapping, error)
+
+	// Create a static mapping unless an identical one exists
+	EnsureNetNatStaticMapping(mapping NetNatStaticMapping) (bool, error)
+
+	// Remove a static mapping
+	RemoveNetNatStaticMapping(natName string, staticMappingID int) error
+
+	// Get the entries of the DNS client cache
+	GetDNSCache() ([]DNSCacheEntry, error)
+
+	// Flush the DNS client cache
+	ClearDNSCache() error
+
+	// Query DNS for a name, bypassing the client cache
+	ResolveName(name string, recordType string, server net.IP) ([]DNSRecord, error)
+
+	// Get the network compartments on the host
+	GetNetCompartments() ([]Compartment, error)
+
+	// Get an Interface limited to the interfaces of a network compartment
+	InCompartment(id int) Interface
+
 	// exit the shell
 	Exit()
 }
@@ -39,31 +111,160 @@ type Route struct {
 	GatewayAddress    net.IP
 	RouteMetric       int
 	IfMetric          int
+	InterfaceAlias    string
+	AddressFamily     string
+	Protocol          string
+	PolicyStore       string
 }
 
 type shell struct {
-	shellInstance ps.Shell
+	pool *sessionPool
+	// compartmentID limits queries and mutations to a network compartment, zero means no limit
+	compartmentID int
+	// startErr is returned by every call if the powershell session could not be started
+	startErr error
+	// dryRun records mutations instead of running them when set
+	dryRun *dryrun.Recorder
+	// observer is notified of every script that ran, if set
+	observer observer.Observer
+	// retry reruns reads and Ensure operations that failed transiently, nil runs them once
+	retry *retry.Policy
+	// loopback caches the ifIndex of the loopback interface of the compartment, nil looks it up on every use
+	loopback *loopbackCache
 }
 
+// Options configures the powershell sessions created by NewWithOptions
+type Options struct {
+	// Shell is used as is when set, Starter, Executable and PoolSize are then ignored and the shell is never restarted
+	Shell ps.Shell
+	// Starter launches the powershell process, defaults to psbe.Local. Use remote.Starter to run powershell on
+	// another machine, over SSH or an adapted WinRM client.
+	Starter psbe.Starter
+	// Executable is the powershell binary to launch, defaults to powershell.exe. Use pwsh for PowerShell Core.
+	Executable string
+	// PoolSize is the number of powershell sessions commands are spread over, defaults to 1
+	PoolSize int
+	// CommandTimeout bounds a single command, a session that exceeds it is restarted. Zero waits forever.
+	CommandTimeout time.Duration
+	// HealthCheckInterval, if set, probes idle sessions in the background and restarts dead ones
+	HealthCheckInterval time.Duration
+	// DryRun records every script that would change the host instead of running it and reports it as successful.
+	// Queries still run, against a fixture if Shell is one.
+	DryRun *dryrun.Recorder
+	// Observer is notified of every script with its duration and output. Wrap it with observer.Redacting to mask
+	// sensitive arguments.
+	Observer observer.Observer
+	// SessionHook, if set, is called with the number of running powershell sessions right away and whenever it
+	// changes, e.g. to feed a gauge
+	SessionHook func(live int)
+	// Retry reruns the scripts of reads and EnsureNetNatStaticMapping when they fail transiently, e.g. on an adapter
+	// that was just created. Other mutations are never retried.
+	Retry *retry.Policy
+}
+
+const (
+	defaultPowershellExecutable string = "powershell.exe"
+)
+
+// New returns a new Interface backed by a local powershell.exe session.
+// If powershell cannot be started every call returns the startup error, use NewWithOptions to get it up front.
 func New() Interface {
 
-	s, _ := ps.New(&psbe.Local{})
+	runner, err := NewWithOptions(Options{})
+	if err != nil {
+		return &shell{
+			startErr: err,
+		}
+	}
+
+	return runner
+}
+
+// NewWithOptions returns a new Interface backed by the configured pool of powershell sessions.
+// Each session runs one command at a time, sessions that crash or hang are restarted on next use.
+func NewWithOptions(opts Options) (Interface, error) {
+	var pool *sessionPool
+	if opts.Shell != nil {
+		pool = newSingleSessionPool(opts.Shell, opts.CommandTimeout)
+	} else {
+		starter := opts.Starter
+		if starter == nil {
+			starter = &psbe.Local{}
+		}
+
+		executable := opts.Executable
+		if executable == "" {
+			executable = defaultPowershellExecutable
+		}
+
+		size := opts.PoolSize
+		if size <= 0 {
+			size = 1
+		}
+
+		factory := func() (ps.Shell, error) {
+			s, err := ps.New(&executableStarter{starter: starter, executable: executable})
+			if err != nil {
+				return nil, fmt.Errorf("failed to start %v: %v", executable, err)
+			}
+			return s, nil
+		}
+
+		var err error
+		pool, err = newSessionPool(size, opts.CommandTimeout, factory)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.SessionHook != nil {
+		pool.setSessionHook(opts.SessionHook)
+	}
+
+	if opts.HealthCheckInterval > 0 {
+		go pool.probeEvery(opts.HealthCheckInterval)
+	}
 
 	runner := &shell{
-		shellInstance: s,
+		pool:     pool,
+		dryRun:   opts.DryRun,
+		observer: opts.Observer,
+		retry:    opts.Retry,
+		loopback: &loopbackCache{},
 	}
 
-	return runner
+	return runner, nil
+}
+
+// WithObserver returns a copy of the shell that also reports its scripts to obs, sharing the powershell sessions.
+// It is not part of Interface, decorators look for it to attribute the scripts of a single call to that call.
+func (shell *shell) WithObserver(obs observer.Observer) Interface {
+	scoped := *shell
+	scoped.observer = observer.Multi(shell.observer, obs)
+	return &scoped
+}
+
+// executableStarter launches the configured executable in place of the powershell.exe hardcoded by ps.New
+type executableStarter struct {
+	starter    psbe.Starter
+	executable string
+}
+
+func (s *executableStarter) StartProcess(cmd string, args ...string) (psbe.Waiter, io.Writer, io.Reader, io.Reader, error) {
+	return s.starter.StartProcess(s.executable, args...)
 }
 
+// Exit stops every powershell session, later calls return ErrShellClosed
 func (shell *shell) Exit() {
-	shell.shellInstance.Exit()
-	shell.shellInstance = nil
+	if shell.pool == nil {
+		return
+	}
+	shell.pool.Exit()
 }
 
 func (shell *shell) GetNetRoutesAll() ([]Route, error) {
 	getRouteCmdLine := "get-netroute -erroraction Ignore"
-	stdout, err := shell.runScript(getRouteCmdLine)
+	stdout, err := shell.query(shell.scopeQuery(getRouteCmdLine))
 	if err != nil {
 		return nil, err
 	}
@@ -71,23 +272,36 @@ func (shell *shell) GetNetRoutesAll() ([]Route, error) {
 }
 func (shell *shell) GetNetRoutes(linkIndex int, destinationSubnet *net.IPNet) ([]Route, error) {
 	getRouteCmdLine := fmt.Sprintf("get-netroute -InterfaceIndex %v -DestinationPrefix %v -erroraction Ignore", linkIndex, destinationSubnet.String())
-	stdout, err := shell.runScript(getRouteCmdLine)
+	stdout, err := shell.query(shell.scopeQuery(getRouteCmdLine))
 	if err != nil {
 		return nil, err
 	}
 	return parseRoutesList(stdout), nil
 }
 
+// RemoveNetRoute removes a route, a nil gatewayAddress removes the on-link route
 func (shell *shell) RemoveNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
-	removeRouteCmdLine := fmt.Sprintf("remove-netroute -InterfaceIndex %v -DestinationPrefix %v -NextHop  %v -Verbose -Confirm:$false", linkIndex, destinationSubnet.String(), gatewayAddress.String())
-	_, err := shell.runScript(removeRouteCmdLine)
+	return shell.removeNetRoute("RemoveNetRoute", linkIndex, destinationSubnet, gatewayAddress)
+}
+
+// removeNetRoute removes a route on behalf of operation, which names it in dry-run entries
+func (shell *shell) removeNetRoute(operation string, linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	if err := validateRoute(linkIndex, destinationSubnet, gatewayAddress); err != nil {
+		return err
+	}
+	removeRouteCmdLine := fmt.Sprintf("remove-netroute -InterfaceIndex %v -DestinationPrefix %v -NextHop  %v -Verbose -Confirm:$false", linkIndex, destinationSubnet.String(), nextHop(destinationSubnet, gatewayAddress))
+	_, err := shell.mutate(operation, routeParams(linkIndex, destinationSubnet, gatewayAddress), shell.scopeMutation(linkIndex, removeRouteCmdLine))
 
 	return err
 }
 
+// NewNetRoute creates a route, a nil gatewayAddress creates an on-link route
 func (shell *shell) NewNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
-	newRouteCmdLine := fmt.Sprintf("new-netroute -InterfaceIndex %v -DestinationPrefix %v -NextHop  %v -Verbose", linkIndex, destinationSubnet.String(), gatewayAddress.String())
-	_, err := shell.runScript(newRouteCmdLine)
+	if err := validateRoute(linkIndex, destinationSubnet, gatewayAddress); err != nil {
+		return err
+	}
+	newRouteCmdLine := fmt.Sprintf("new-netroute -InterfaceIndex %v -DestinationPrefix %v -NextHop  %v -Verbose", linkIndex, destinationSubnet.String(), nextHop(destinationSubnet, gatewayAddress))
+	_, err := shell.mutate("NewNetRoute", routeParams(linkIndex, destinationSubnet, gatewayAddress), shell.scopeMutation(linkIndex, newRouteCmdLine))
 
 	return err
 }
@@ -103,7 +317,20 @@ func parseRoutesList(stdout string) []Route {
 		}
 
 		parts := strings.Split(line, "|")
-		if len(parts) != 5 {
+		if len(parts) != 5 && len(parts) != 6 {
+			continue
+		}
+		// the RouteMetric and ifMetric columns are one space apart, so they usually share a part
+		metrics := strings.Fields(strings.Join(parts[3:len(parts)-1], " "))
+		if len(metrics) != 2 {
+			continue
+		}
+		routeMetric, err := strconv.Atoi(metrics[0])
+		if err != nil {
+			continue
+		}
+		ifMetric, err := strconv.Atoi(metrics[1])
+		if err != nil {
 			continue
 		}
 
@@ -121,10 +348,16 @@ func parseRoutesList(stdout string) []Route {
 		if err != nil {
 			continue
 		}
+		// on-link routes have an unspecified next hop, they are modeled by a nil gateway
+		if gatewayAddress.IsUnspecified() {
+			gatewayAddress = nil
+		}
 		route := Route{
 			DestinationSubnet: destinationSubnet,
 			GatewayAddress:    gatewayAddress,
 			LinkIndex:         linkIndex,
+			RouteMetric:       routeMetric,
+			IfMetric:          ifMetric,
 		}
 
 		routes = append(routes, route)
@@ -133,8 +366,18 @@ func parseRoutesList(stdout string) []Route {
 	return routes
 }
 
+// Equal compares routes on their full key: link, destination and next hop
 func (r *Route) Equal(route Route) bool {
-	if r.DestinationSubnet.IP.Equal(route.DestinationSubnet.IP) && r.GatewayAddress.Equal(route.GatewayAddress) && bytes.Equal(r.DestinationSubnet.Mask, route.DestinationSubnet.Mask) {
+	if r.LinkIndex != route.LinkIndex {
+		return false
+	}
+	if r.IsOnLink() != route.IsOnLink() {
+		return false
+	}
+	if !r.IsOnLink() && !r.GatewayAddress.Equal(route.GatewayAddress) {
+		return false
+	}
+	if r.DestinationSubnet.IP.Equal(route.DestinationSubnet.IP) && bytes.Equal(r.DestinationSubnet.Mask, route.DestinationSubnet.Mask) {
 		return true
 	}
 
@@ -142,8 +385,14 @@ func (r *Route) Equal(route Route) bool {
 }
 
 func (shell *shell) runScript(cmdLine string) (string, error) {
+	if shell.startErr != nil {
+		return "", shell.startErr
+	}
 
-	stdout, _, err := shell.shellInstance.Execute(cmdLine)
+	script := shell.compartmentPrefix() + cmdLine
+	command := observer.Start(shell.observer, observer.Event{Package: "netroute", Program: "powershell", Args: []string{script}})
+	stdout, stderr, err := shell.pool.Execute(script)
+	command.Done([]byte(stdout+stderr), err)
 	if err != nil {
 		return "", err
 	}
@@ -151,6 +400,56 @@ func (shell *shell) runScript(cmdLine string) (string, error) {
 	return stdout, nil
 }
 
+// query runs a script that only reads, rerunning it according to the retry policy
+func (shell *shell) query(cmdLine string) (stdout string, err error) {
+	err = shell.retry.Do(func() error {
+		stdout, err = shell.runScript(cmdLine)
+		return err
+	})
+	return stdout, err
+}
+
+// mutate runs a script that changes the host, in dry-run mode it is recorded under the calling operation and its
+// params instead
+func (shell *shell) mutate(operation string, params []string, cmdLine string) (string, error) {
+	if shell.dryRun != nil {
+		shell.dryRun.Record(dryrun.Entry{
+			Package:   "netroute",
+			Operation: operation,
+			Args:      params,
+			Command:   shell.compartmentPrefix() + cmdLine,
+		})
+		return "", nil
+	}
+	return shell.runScript(cmdLine)
+}
+
+// validateRoute fails on a route that cannot be rendered into a script: a missing or malformed destination, an
+// interface index that is not positive or a gateway of the other address family
+func validateRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	if linkIndex <= 0 {
+		return fmt.Errorf("invalid interface index %v", linkIndex)
+	}
+	if err := cmdline.CIDR(destinationSubnet); err != nil {
+		return err
+	}
+	if gatewayAddress == nil {
+		return nil
+	}
+	if err := cmdline.IP(gatewayAddress); err != nil {
+		return err
+	}
+	if _, bits := destinationSubnet.Mask.Size(); (gatewayAddress.To4() != nil) != (bits == 8*net.IPv4len) {
+		return fmt.Errorf("gateway %v is not in the address family of %v", gatewayAddress, destinationSubnet)
+	}
+	return nil
+}
+
+// routeParams renders the arguments of the route mutations for a dry-run entry
+func routeParams(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) []string {
+	return []string{strconv.Itoa(linkIndex), destinationSubnet.String(), nextHop(destinationSubnet, gatewayAddress)}
+}
+
 func IpToInt(ip net.IP) *big.Int {
 	if v := ip.To4(); v != nil {
 		return big.NewInt(0).SetBytes(v)
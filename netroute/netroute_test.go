@@ -1,9 +1,24 @@
 package netroute
 
 import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	psbe "github.com/antoninbas/go-powershell/backend"
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/rakelkar/gonetsh/observer"
+	"github.com/rakelkar/gonetsh/remote"
+	fakeremote "github.com/rakelkar/gonetsh/remote/testing"
+	"github.com/rakelkar/gonetsh/retry"
 	"github.com/stretchr/testify/assert"
+	fakeclock "k8s.io/utils/clock/testing"
 	"fmt"
 )
 
@@ -38,8 +53,7 @@ func NewFakeShell(t *testing.T) *fakeShell{
 
 func (fs *fakeShell) Execute(cmd string) (string, string, error) {
 	if val, ok := fs.RequestMap[cmd]; ok {
-		//do something here
-		return val.StdOut, val.StdErr, nil
+		return val.StdOut, val.StdErr, val.Err
 	}
 
 	if fs.DefaultResponse != nil {
@@ -67,7 +81,7 @@ func GetAllRoutesTest(t *testing.T) {
 	}
 
 	nr := &shell{
-		shellInstance: fs,
+		pool: newSingleSessionPool(fs, 0),
 	}
 
 	routes, err := nr.GetNetRoutesAll()
@@ -92,7 +106,7 @@ func GetAllRoutesEmptyTest(t *testing.T) {
 	}
 
 	nr := &shell{
-		shellInstance: fs,
+		pool: newSingleSessionPool(fs, 0),
 	}
 
 	routes, err := nr.GetNetRoutesAll()
@@ -100,3 +114,286 @@ func GetAllRoutesEmptyTest(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t,0, len(routes))
 }
+
+// fakeBackend is a psbe.Starter that emulates the powershell process protocol used by ps.Shell
+type fakeBackend struct {
+	Executable string
+	Args       []string
+	StartErr   error
+	Handler    func(cmd string) (string, string)
+
+	mu        sync.Mutex
+	Starts    int
+	processes []*fakeProcess
+}
+
+type fakeProcess struct {
+	done  chan struct{}
+	stdin *io.PipeReader
+}
+
+func (p *fakeProcess) Wait() error {
+	<-p.done
+	return nil
+}
+
+// Kill makes the most recently started process die as if it crashed
+func (fb *fakeBackend) Kill() {
+	fb.mu.Lock()
+	process := fb.processes[len(fb.processes)-1]
+	fb.mu.Unlock()
+
+	process.stdin.CloseWithError(errors.New("process has exited"))
+	<-process.done
+}
+
+func (fb *fakeBackend) StartProcess(cmd string, args ...string) (psbe.Waiter, io.Writer, io.Reader, io.Reader, error) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	fb.Executable = cmd
+	fb.Args = args
+	if fb.StartErr != nil {
+		return nil, nil, nil, nil, fb.StartErr
+	}
+	fb.Starts++
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+	process := &fakeProcess{done: make(chan struct{}), stdin: stdinReader}
+	fb.processes = append(fb.processes, process)
+	boundary := regexp.MustCompile(`^(.*); echo '(\$gorilla[^']*\$)'; \[Console\]::Error.WriteLine\('(\$gorilla[^']*\$)'\)$`)
+
+	go func() {
+		defer close(process.done)
+		defer stdoutWriter.Close()
+		defer stderrWriter.Close()
+
+		scanner := bufio.NewScanner(stdinReader)
+		for scanner.Scan() {
+			line := strings.TrimSuffix(scanner.Text(), "\r")
+			if line == "exit" {
+				return
+			}
+			match := boundary.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			stdout, stderr := "", ""
+			if fb.Handler != nil {
+				stdout, stderr = fb.Handler(match[1])
+			}
+			go stderrWriter.Write([]byte(stderr + match[3] + "\r\n"))
+			stdoutWriter.Write([]byte(stdout + match[2] + "\r\n"))
+		}
+		stdinReader.Close()
+	}()
+
+	return process, stdinWriter, stdoutReader, stderrReader, nil
+}
+
+func TestNewWithOptions(t *testing.T) {
+	fb := &fakeBackend{
+		Handler: func(cmd string) (string, string) {
+			if cmd == GetAllRoutesCommand {
+				return GetRouteStdOut, ""
+			}
+			return "", "unexpected command " + cmd
+		},
+	}
+
+	nr, err := NewWithOptions(Options{Starter: fb, Executable: "pwsh"})
+	assert.NoError(t, err)
+	defer nr.Exit()
+	assert.Equal(t, "pwsh", fb.Executable)
+	assert.Equal(t, []string{"-NoExit", "-Command", "-"}, fb.Args)
+
+	routes, err := nr.GetNetRoutesAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(routes))
+	assert.Equal(t, "192.168.10.0/24", routes[1].DestinationSubnet.String())
+	assert.Equal(t, 12, routes[1].LinkIndex)
+
+	_, err = nr.GetNetRoutes(12, routes[1].DestinationSubnet)
+	assert.Error(t, err)
+}
+
+func TestNewWithOptionsDefaultsAndErrors(t *testing.T) {
+	fb := &fakeBackend{}
+	nr, err := NewWithOptions(Options{Starter: fb})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultPowershellExecutable, fb.Executable)
+	nr.Exit()
+	nr.Exit()
+
+	_, err = nr.GetNetRoutesAll()
+	assert.Error(t, err)
+
+	_, err = NewWithOptions(Options{Starter: &fakeBackend{StartErr: errors.New("not found")}})
+	assert.Error(t, err)
+
+	fs := NewFakeShell(t)
+	fs.DefaultResponse = &fakeResponse{}
+	nr, err = NewWithOptions(Options{Shell: fs})
+	assert.NoError(t, err)
+	_, err = nr.GetNetRoutesAll()
+	assert.NoError(t, err)
+}
+
+func TestStartErrorIsReturned(t *testing.T) {
+	nr := &shell{
+		startErr: errors.New("failed to start powershell.exe"),
+	}
+
+	_, err := nr.GetNetRoutesAll()
+	assert.EqualError(t, err, "failed to start powershell.exe")
+	nr.Exit()
+}
+
+func TestDryRun(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap[GetAllRoutesCommand] = fakeResponse{GetRouteStdOut, "", nil}
+	recorder := dryrun.NewRecorder()
+
+	nr, err := NewWithOptions(Options{Shell: fs, DryRun: recorder})
+	assert.NoError(t, err)
+
+	routes, err := nr.GetNetRoutesAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(routes))
+
+	_, subnet, _ := net.ParseCIDR("10.244.1.0/24")
+	assert.NoError(t, nr.NewNetRoute(12, subnet, net.ParseIP("10.0.0.5")))
+	assert.NoError(t, nr.RemoveNetRoute(12, subnet, nil))
+	assert.NoError(t, nr.ClearDNSCache())
+	assert.NoError(t, nr.InCompartment(2).NewNetIPAddress(12, net.ParseIP("10.244.1.1"), 24))
+
+	assert.Equal(t, []dryrun.Entry{
+		{
+			Package:   "netroute",
+			Operation: "NewNetRoute",
+			Args:      []string{"12", "10.244.1.0/24", "10.0.0.5"},
+			Command:   "new-netroute -InterfaceIndex 12 -DestinationPrefix 10.244.1.0/24 -NextHop  10.0.0.5 -Verbose",
+		},
+		{
+			Package:   "netroute",
+			Operation: "RemoveNetRoute",
+			Args:      []string{"12", "10.244.1.0/24", "0.0.0.0"},
+			Command:   "remove-netroute -InterfaceIndex 12 -DestinationPrefix 10.244.1.0/24 -NextHop  0.0.0.0 -Verbose -Confirm:$false",
+		},
+		{
+			Package:   "netroute",
+			Operation: "ClearDNSCache",
+			Command:   "clear-dnsclientcache",
+		},
+	}, recorder.Entries()[:3])
+
+	entry := recorder.Entries()[3]
+	assert.Equal(t, "NewNetIPAddress", entry.Operation)
+	assert.Equal(t, []string{"12", "10.244.1.1", "24"}, entry.Args)
+	assert.True(t, strings.HasPrefix(entry.Command, "$ifs = @(get-netipinterface -IncludeAllCompartments"))
+	assert.True(t, strings.HasSuffix(entry.Command, "new-netipaddress -InterfaceIndex 12 -IPAddress 10.244.1.1 -PrefixLength 24 -Verbose"))
+}
+
+func TestObserver(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap[GetAllRoutesCommand] = fakeResponse{GetRouteStdOut, "", nil}
+	fs.RequestMap["clear-dnsclientcache"] = fakeResponse{"", "Access is denied", errors.New("Access is denied")}
+	var events []observer.Event
+
+	nr, err := NewWithOptions(Options{Shell: fs, Observer: observer.Func(func(event observer.Event) {
+		events = append(events, event)
+	})})
+	assert.NoError(t, err)
+
+	_, err = nr.GetNetRoutesAll()
+	assert.NoError(t, err)
+	assert.Error(t, nr.ClearDNSCache())
+
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "netroute", events[0].Package)
+	assert.Equal(t, "powershell", events[0].Program)
+	assert.Equal(t, []string{GetAllRoutesCommand}, events[0].Args)
+	assert.Equal(t, GetRouteStdOut, events[0].Output)
+	assert.Equal(t, 0, events[0].ExitStatus)
+
+	assert.Equal(t, []string{"clear-dnsclientcache"}, events[1].Args)
+	assert.Equal(t, "Access is denied", events[1].Output)
+	assert.Equal(t, -1, events[1].ExitStatus)
+	assert.EqualError(t, events[1].Err, "Access is denied")
+}
+
+func TestRetry(t *testing.T) {
+	calls := make(map[string]int)
+	fs := &scriptedShell{execute: func(cmd string) (string, string, error) {
+		calls[cmd]++
+		switch {
+		case cmd == GetAllRoutesCommand && calls[cmd] == 1:
+			return "", "Element not found.", errors.New("stderr non empty: Element not found.")
+		case cmd == GetAllRoutesCommand:
+			return GetRouteStdOut, "", nil
+		case strings.HasPrefix(cmd, "add-netnatstaticmapping") && calls[cmd] < 3:
+			return "", "The object is already in use.", errors.New("stderr non empty: The object is already in use.")
+		case strings.HasPrefix(cmd, "add-netnatstaticmapping"), strings.HasPrefix(cmd, "ConvertTo-Json"):
+			return "", "", nil
+		}
+		return "", "Access is denied.", errors.New("stderr non empty: Access is denied.")
+	}}
+	start := time.Now()
+	clock := fakeclock.NewFakeClock(start)
+
+	nr, err := NewWithOptions(Options{Shell: fs, Retry: &retry.Policy{MaxAttempts: 3, InitialBackoff: time.Second, Clock: clock}})
+	assert.NoError(t, err)
+
+	routes, err := nr.GetNetRoutesAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(routes))
+	assert.Equal(t, time.Second, clock.Since(start))
+
+	changed, err := nr.EnsureNetNatStaticMapping(NetNatStaticMapping{
+		NatName: "nat", Protocol: "tcp", ExternalIPAddress: net.ParseIP("0.0.0.0"), ExternalPort: 8080, InternalIPAddress: net.ParseIP("172.16.0.2"), InternalPort: 80,
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 4*time.Second, clock.Since(start))
+
+	// plain mutations and errors that are not transient are not retried
+	assert.Error(t, nr.ClearDNSCache())
+	assert.Equal(t, 1, calls["clear-dnsclientcache"])
+	assert.Equal(t, 4*time.Second, clock.Since(start))
+}
+
+func TestRemoteStarter(t *testing.T) {
+	boundary := regexp.MustCompile(`^(.*); echo '(\$gorilla[^']*\$)'; \[Console\]::Error.WriteLine\('(\$gorilla[^']*\$)'\)$`)
+	transport := fakeremote.NewFakeTransport(func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			line := strings.TrimSuffix(scanner.Text(), "\r")
+			if line == "exit" {
+				return 0
+			}
+			match := boundary.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			io.WriteString(stderr, match[3]+"\r\n")
+			if match[1] == GetAllRoutesCommand {
+				io.WriteString(stdout, GetRouteStdOut)
+			}
+			io.WriteString(stdout, match[2]+"\r\n")
+		}
+		return 0
+	})
+
+	nr, err := NewWithOptions(Options{Starter: remote.Starter(transport), Executable: "pwsh"})
+	assert.NoError(t, err)
+
+	routes, err := nr.GetNetRoutesAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(routes))
+
+	nr.Exit()
+	assert.Equal(t, []string{"pwsh -NoExit -Command -"}, transport.Commands())
+	assert.Equal(t, 0, transport.OpenSessions())
+}
@@ -0,0 +1,243 @@
+package netroute
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ps "github.com/antoninbas/go-powershell"
+)
+
+// ErrShellClosed is returned by calls made after Exit
+var ErrShellClosed = errors.New("powershell session has exited")
+
+const (
+	// healthProbeCmdLine is cheap and must succeed on any healthy session
+	healthProbeCmdLine string = "$null"
+)
+
+// session is a single powershell process, a nil shell means the process died and must be restarted
+type session struct {
+	shell ps.Shell
+}
+
+// sessionPool hands out powershell sessions one caller at a time, since ps.Shell does not support concurrent Execute.
+// Sessions that crash or hang are discarded and restarted on next use.
+type sessionPool struct {
+	// factory starts a new session, nil if sessions can not be restarted
+	factory func() (ps.Shell, error)
+	// timeout bounds a single Execute, zero waits forever
+	timeout time.Duration
+	// idle holds every session not currently executing a command
+	idle chan *session
+	size int
+
+	mu     sync.RWMutex
+	closed bool
+	// stop ends background health probing
+	stop chan struct{}
+
+	// live counts the running powershell processes
+	live int32
+	// sessionHook, if set, is told the number of live sessions whenever it changes, it is set before the pool is used
+	sessionHook func(live int)
+}
+
+// newSessionPool eagerly starts size sessions so startup errors surface right away
+func newSessionPool(size int, timeout time.Duration, factory func() (ps.Shell, error)) (*sessionPool, error) {
+	pool := &sessionPool{
+		factory: factory,
+		timeout: timeout,
+		idle:    make(chan *session, size),
+		size:    size,
+		stop:    make(chan struct{}),
+	}
+
+	for i := 0; i < size; i++ {
+		s, err := factory()
+		if err != nil {
+			for j := 0; j < i; j++ {
+				(<-pool.idle).shell.Exit()
+			}
+			return nil, err
+		}
+		pool.idle <- &session{shell: s}
+		pool.sessionsChanged(1)
+	}
+
+	return pool, nil
+}
+
+// newSingleSessionPool wraps a caller provided shell, which can not be restarted
+func newSingleSessionPool(s ps.Shell, timeout time.Duration) *sessionPool {
+	pool := &sessionPool{
+		timeout: timeout,
+		idle:    make(chan *session, 1),
+		size:    1,
+		stop:    make(chan struct{}),
+	}
+	pool.idle <- &session{shell: s}
+	pool.sessionsChanged(1)
+	return pool
+}
+
+// setSessionHook reports the current number of live sessions to hook, and every change after that
+func (pool *sessionPool) setSessionHook(hook func(live int)) {
+	pool.sessionHook = hook
+	hook(int(atomic.LoadInt32(&pool.live)))
+}
+
+// sessionsChanged adds delta to the live sessions
+func (pool *sessionPool) sessionsChanged(delta int32) {
+	live := atomic.AddInt32(&pool.live, delta)
+	if pool.sessionHook != nil {
+		pool.sessionHook(int(live))
+	}
+}
+
+// Execute runs cmdLine on the next idle session, restarting it first if it has died
+func (pool *sessionPool) Execute(cmdLine string) (string, string, error) {
+	pool.mu.RLock()
+	closed := pool.closed
+	pool.mu.RUnlock()
+	if closed {
+		return "", "", ErrShellClosed
+	}
+
+	s, ok := <-pool.idle
+	if !ok {
+		return "", "", ErrShellClosed
+	}
+	defer pool.release(s)
+
+	if s.shell == nil {
+		if pool.factory == nil {
+			return "", "", fmt.Errorf("powershell session died and can not be restarted")
+		}
+		restarted, err := pool.factory()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to restart powershell session: %v", err)
+		}
+		s.shell = restarted
+		pool.sessionsChanged(1)
+	}
+
+	stdout, stderr, err := pool.execute(s, cmdLine)
+	if err != nil && s.shell != nil && pool.factory != nil {
+		// an error may just be a failing command, only a session that fails the probe is restarted
+		if _, _, probeErr := pool.execute(s, healthProbeCmdLine); probeErr != nil {
+			pool.discard(s)
+		}
+	}
+
+	return stdout, stderr, err
+}
+
+// Probe checks every idle session and discards the ones that no longer respond, they are restarted on next use.
+func (pool *sessionPool) Probe() {
+	for i := 0; i < pool.size; i++ {
+		var s *session
+		select {
+		case s = <-pool.idle:
+		default:
+			// every remaining session is busy, and so evidently alive
+			return
+		}
+		if s == nil {
+			return
+		}
+		if s.shell != nil {
+			if _, _, err := pool.execute(s, healthProbeCmdLine); err != nil {
+				pool.discard(s)
+			}
+		}
+		pool.release(s)
+	}
+}
+
+// probeEvery runs Probe on the given interval until Exit
+func (pool *sessionPool) probeEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pool.Probe()
+		case <-pool.stop:
+			return
+		}
+	}
+}
+
+// execute runs cmdLine with the pool timeout, a session that times out is discarded
+func (pool *sessionPool) execute(s *session, cmdLine string) (string, string, error) {
+	if pool.timeout <= 0 {
+		return s.shell.Execute(cmdLine)
+	}
+
+	type result struct {
+		stdout string
+		stderr string
+		err    error
+	}
+	done := make(chan result, 1)
+	instance := s.shell
+	go func() {
+		stdout, stderr, err := instance.Execute(cmdLine)
+		done <- result{stdout, stderr, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.stdout, r.stderr, r.err
+	case <-time.After(pool.timeout):
+		// the process is stuck, exit it once the abandoned command returns, if ever
+		s.shell = nil
+		pool.sessionsChanged(-1)
+		go func() {
+			<-done
+			instance.Exit()
+		}()
+		return "", "", fmt.Errorf("powershell command timed out after %v: %v", pool.timeout, cmdLine)
+	}
+}
+
+// discard drops the session's process so it is restarted on next use
+func (pool *sessionPool) discard(s *session) {
+	instance := s.shell
+	s.shell = nil
+	if instance == nil {
+		return
+	}
+	pool.sessionsChanged(-1)
+	// a hung process may never exit, so do not wait for it
+	go instance.Exit()
+}
+
+func (pool *sessionPool) release(s *session) {
+	pool.idle <- s
+}
+
+// Exit waits for in-flight commands and stops every session, later calls return ErrShellClosed
+func (pool *sessionPool) Exit() {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		return
+	}
+	pool.closed = true
+	pool.mu.Unlock()
+	close(pool.stop)
+
+	for i := 0; i < pool.size; i++ {
+		s := <-pool.idle
+		if s.shell != nil {
+			s.shell.Exit()
+			s.shell = nil
+			pool.sessionsChanged(-1)
+		}
+	}
+	close(pool.idle)
+}
@@ -0,0 +1,215 @@
+package netroute
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ps "github.com/antoninbas/go-powershell"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exclusiveShell fails the test if Execute is ever entered concurrently
+type exclusiveShell struct {
+	t      *testing.T
+	busy   int32
+	exited int32
+}
+
+func (s *exclusiveShell) Execute(cmd string) (string, string, error) {
+	if !atomic.CompareAndSwapInt32(&s.busy, 0, 1) {
+		s.t.Errorf("concurrent Execute on one session")
+	}
+	time.Sleep(time.Millisecond)
+	atomic.StoreInt32(&s.busy, 0)
+	return cmd, "", nil
+}
+
+func (s *exclusiveShell) Exit() {
+	atomic.AddInt32(&s.exited, 1)
+}
+
+func TestPoolSerializesEachSession(t *testing.T) {
+	var mu sync.Mutex
+	var shells []*exclusiveShell
+	pool, err := newSessionPool(3, 0, func() (ps.Shell, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		s := &exclusiveShell{t: t}
+		shells = append(shells, s)
+		return s, nil
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stdout, _, err := pool.Execute("get-netroute")
+			assert.NoError(t, err)
+			assert.Equal(t, "get-netroute", stdout)
+		}()
+	}
+	wg.Wait()
+
+	pool.Exit()
+	assert.Equal(t, 3, len(shells))
+	for _, s := range shells {
+		assert.EqualValues(t, 1, s.exited)
+	}
+}
+
+func TestPoolRestartsCrashedSession(t *testing.T) {
+	fb := &fakeBackend{
+		Handler: func(cmd string) (string, string) { return "ok", "" },
+	}
+	nr, err := NewWithOptions(Options{Starter: fb})
+	require.NoError(t, err)
+	defer nr.Exit()
+	runner := nr.(*shell)
+
+	stdout, err := runner.runScript("get-netroute")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", stdout)
+
+	fb.Kill()
+	_, err = runner.runScript("get-netroute")
+	assert.Error(t, err)
+
+	stdout, err = runner.runScript("get-netroute")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", stdout)
+	assert.Equal(t, 2, fb.Starts)
+}
+
+func TestPoolKeepsSessionOnCommandError(t *testing.T) {
+	fb := &fakeBackend{
+		Handler: func(cmd string) (string, string) {
+			if cmd == "get-netroute -bad" {
+				return "", "bad parameter"
+			}
+			return "", ""
+		},
+	}
+	nr, err := NewWithOptions(Options{Starter: fb})
+	require.NoError(t, err)
+	defer nr.Exit()
+	runner := nr.(*shell)
+
+	_, err = runner.runScript("get-netroute -bad")
+	assert.Error(t, err)
+	_, err = runner.runScript("get-netroute")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fb.Starts)
+}
+
+func TestPoolRestartsHungSession(t *testing.T) {
+	unblock := make(chan struct{})
+	fb := &fakeBackend{
+		Handler: func(cmd string) (string, string) {
+			if cmd == "hang" {
+				<-unblock
+			}
+			return "ok", ""
+		},
+	}
+	nr, err := NewWithOptions(Options{Starter: fb, CommandTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	defer nr.Exit()
+	defer close(unblock)
+	runner := nr.(*shell)
+
+	_, err = runner.runScript("hang")
+	assert.Error(t, err)
+
+	stdout, err := runner.runScript("get-netroute")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", stdout)
+	assert.Equal(t, 2, fb.Starts)
+}
+
+func TestPoolProbeDiscardsDeadSessions(t *testing.T) {
+	fb := &fakeBackend{
+		Handler: func(cmd string) (string, string) { return "ok", "" },
+	}
+	nr, err := NewWithOptions(Options{Starter: fb})
+	require.NoError(t, err)
+	defer nr.Exit()
+	runner := nr.(*shell)
+
+	fb.Kill()
+	runner.pool.Probe()
+
+	stdout, err := runner.runScript("get-netroute")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", stdout)
+	assert.Equal(t, 2, fb.Starts)
+}
+
+func TestPoolExit(t *testing.T) {
+	fb := &fakeBackend{}
+	nr, err := NewWithOptions(Options{Starter: fb, PoolSize: 2, HealthCheckInterval: time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fb.Starts)
+
+	nr.Exit()
+	nr.Exit()
+
+	_, err = nr.GetNetRoutesAll()
+	assert.Equal(t, ErrShellClosed, err)
+}
+
+func TestPoolStartupFailureStopsStartedSessions(t *testing.T) {
+	started := 0
+	var shells []*exclusiveShell
+	_, err := newSessionPool(3, 0, func() (ps.Shell, error) {
+		started++
+		if started == 3 {
+			return nil, assert.AnError
+		}
+		s := &exclusiveShell{t: t}
+		shells = append(shells, s)
+		return s, nil
+	})
+	assert.Equal(t, assert.AnError, err)
+	for _, s := range shells {
+		assert.EqualValues(t, 1, s.exited)
+	}
+}
+
+func TestPoolSessionHook(t *testing.T) {
+	unblock := make(chan struct{})
+	fb := &fakeBackend{
+		Handler: func(cmd string) (string, string) {
+			if cmd == "hang" {
+				<-unblock
+			}
+			return "ok", ""
+		},
+	}
+	var mu sync.Mutex
+	var live []int
+	nr, err := NewWithOptions(Options{Starter: fb, PoolSize: 2, CommandTimeout: 50 * time.Millisecond, SessionHook: func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		live = append(live, n)
+	}})
+	require.NoError(t, err)
+	defer close(unblock)
+	runner := nr.(*shell)
+
+	_, err = runner.runScript("hang")
+	assert.Error(t, err)
+	_, err = runner.runScript("get-netroute")
+	assert.NoError(t, err)
+	_, err = runner.runScript("get-netroute")
+	assert.NoError(t, err)
+	nr.Exit()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{2, 1, 2, 1, 0}, live)
+}
@@ -0,0 +1,142 @@
+package netroute
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// RouteAction is what a RouteOp does to its route
+type RouteAction int
+
+const (
+	RouteAdd RouteAction = iota
+	RouteRemove
+)
+
+const (
+	// maxRouteBatchSize bounds the length of a single batch script, larger batches are split
+	maxRouteBatchSize int = 200
+)
+
+// RouteOp is a single change applied by ApplyRouteBatch
+type RouteOp struct {
+	Action            RouteAction
+	LinkIndex         int
+	DestinationSubnet *net.IPNet
+	// GatewayAddress is nil for an on-link route
+	GatewayAddress net.IP
+	// RouteMetric is only used by RouteAdd, zero keeps the default
+	RouteMetric int
+}
+
+// RouteOpResult reports the outcome of a RouteOp, Err is nil if it succeeded
+type RouteOpResult struct {
+	Op  RouteOp
+	Err error
+}
+
+type routeOpResultJSON struct {
+	Index int
+	Error string
+}
+
+func (action RouteAction) String() string {
+	switch action {
+	case RouteAdd:
+		return "add"
+	case RouteRemove:
+		return "remove"
+	}
+	return fmt.Sprintf("RouteAction(%d)", int(action))
+}
+
+// ApplyRouteBatch applies all ops in as few powershell round trips as possible. Each op runs in its own try/catch
+// so a failing op does not stop the ones after it. The error is only set if the batch as a whole could not run.
+func (shell *shell) ApplyRouteBatch(ops []RouteOp) ([]RouteOpResult, error) {
+	results := make([]RouteOpResult, 0, len(ops))
+	for start := 0; start < len(ops); start += maxRouteBatchSize {
+		end := start + maxRouteBatchSize
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		chunk, err := shell.applyRouteBatch(ops[start:end])
+		if err != nil {
+			return results, err
+		}
+		results = append(results, chunk...)
+	}
+
+	return results, nil
+}
+
+func (shell *shell) applyRouteBatch(ops []RouteOp) ([]RouteOpResult, error) {
+	var script strings.Builder
+	script.WriteString("$results = @(); ")
+	for i, op := range ops {
+		cmdLine, err := routeOpCmdLine(op)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&script, "try { %v | out-null; $results += @{Index=%v} } catch { $results += @{Index=%v;Error=$_.Exception.Message} }; ",
+			shell.scopeMutation(op.LinkIndex, cmdLine), i, i)
+	}
+	script.WriteString("ConvertTo-Json -Compress -InputObject @($results)")
+
+	if shell.dryRun != nil {
+		params := make([]string, len(ops))
+		for i, op := range ops {
+			params[i] = fmt.Sprintf("%v %v", op.Action, strings.Join(routeParams(op.LinkIndex, op.DestinationSubnet, op.GatewayAddress), " "))
+		}
+		if _, err := shell.mutate("ApplyRouteBatch", params, script.String()); err != nil {
+			return nil, err
+		}
+		results := make([]RouteOpResult, len(ops))
+		for i, op := range ops {
+			results[i].Op = op
+		}
+		return results, nil
+	}
+
+	stdout, err := shell.runScript(script.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []routeOpResultJSON
+	if err := decodeJSONList(stdout, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw) != len(ops) {
+		return nil, fmt.Errorf("route batch returned %v results for %v operations", len(raw), len(ops))
+	}
+
+	results := make([]RouteOpResult, len(ops))
+	for _, r := range raw {
+		if r.Index < 0 || r.Index >= len(ops) {
+			return nil, fmt.Errorf("route batch returned a result for unknown operation %v", r.Index)
+		}
+		results[r.Index].Op = ops[r.Index]
+		if r.Error != "" {
+			results[r.Index].Err = errors.New(r.Error)
+		}
+	}
+
+	return results, nil
+}
+
+func routeOpCmdLine(op RouteOp) (string, error) {
+	if op.DestinationSubnet == nil {
+		return "", fmt.Errorf("route %v without destination", op.Action)
+	}
+
+	switch op.Action {
+	case RouteAdd:
+		return fmt.Sprintf("new-netroute -InterfaceIndex %v -DestinationPrefix %v -NextHop %v%v -ErrorAction Stop", op.LinkIndex, op.DestinationSubnet.String(), nextHop(op.DestinationSubnet, op.GatewayAddress), metricArg(op.RouteMetric)), nil
+	case RouteRemove:
+		return fmt.Sprintf("remove-netroute -InterfaceIndex %v -DestinationPrefix %v -NextHop %v -Confirm:$false -ErrorAction Stop", op.LinkIndex, op.DestinationSubnet.String(), nextHop(op.DestinationSubnet, op.GatewayAddress)), nil
+	}
+	return "", fmt.Errorf("unknown route action %v", op.Action)
+}
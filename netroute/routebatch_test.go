@@ -0,0 +1,129 @@
+package netroute
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyRouteBatch(t *testing.T) {
+	_, pods, _ := net.ParseCIDR("10.244.1.0/24")
+	_, stale, _ := net.ParseCIDR("10.244.2.0/24")
+	ops := []RouteOp{
+		{Action: RouteAdd, LinkIndex: 4, DestinationSubnet: pods, GatewayAddress: net.ParseIP("10.0.0.5"), RouteMetric: 10},
+		{Action: RouteRemove, LinkIndex: 4, DestinationSubnet: stale, GatewayAddress: net.ParseIP("10.0.0.6")},
+	}
+
+	fs := NewFakeShell(t)
+	fs.RequestMap["$results = @(); "+
+		"try { new-netroute -InterfaceIndex 4 -DestinationPrefix 10.244.1.0/24 -NextHop 10.0.0.5 -RouteMetric 10 -ErrorAction Stop | out-null; $results += @{Index=0} } catch { $results += @{Index=0;Error=$_.Exception.Message} }; "+
+		"try { remove-netroute -InterfaceIndex 4 -DestinationPrefix 10.244.2.0/24 -NextHop 10.0.0.6 -Confirm:$false -ErrorAction Stop | out-null; $results += @{Index=1} } catch { $results += @{Index=1;Error=$_.Exception.Message} }; "+
+		"ConvertTo-Json -Compress -InputObject @($results)"] = fakeResponse{
+		`[{"Index":0},{"Index":1,"Error":"No matching MSFT_NetRoute objects found"}]`,
+		"",
+		nil,
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	results, err := nr.ApplyRouteBatch(ops)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, ops[0], results[0].Op)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, ops[1], results[1].Op)
+	assert.EqualError(t, results[1].Err, "No matching MSFT_NetRoute objects found")
+}
+
+func TestApplyRouteBatchSplitsLargeBatches(t *testing.T) {
+	var ops []RouteOp
+	for i := 0; i < maxRouteBatchSize+1; i++ {
+		_, subnet, _ := net.ParseCIDR(fmt.Sprintf("10.%v.%v.0/24", i/256, i%256))
+		ops = append(ops, RouteOp{Action: RouteAdd, LinkIndex: 4, DestinationSubnet: subnet})
+	}
+
+	scripts := 0
+	fs := &scriptedShell{
+		execute: func(cmd string) (string, string, error) {
+			scripts++
+			count := strings.Count(cmd, "try {")
+			var results []string
+			for i := 0; i < count; i++ {
+				results = append(results, fmt.Sprintf(`{"Index":%v}`, i))
+			}
+			return "[" + strings.Join(results, ",") + "]", "", nil
+		},
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	results, err := nr.ApplyRouteBatch(ops)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, scripts)
+	assert.Equal(t, len(ops), len(results))
+	assert.Equal(t, ops[maxRouteBatchSize], results[maxRouteBatchSize].Op)
+}
+
+func TestApplyRouteBatchErrors(t *testing.T) {
+	fs := &scriptedShell{
+		execute: func(cmd string) (string, string, error) { return `[{"Index":0}]`, "", nil },
+	}
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+
+	results, err := nr.ApplyRouteBatch(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	_, err = nr.ApplyRouteBatch([]RouteOp{{Action: RouteAdd}})
+	assert.Error(t, err)
+
+	_, err = nr.ApplyRouteBatch([]RouteOp{{Action: RouteAdd, DestinationSubnet: subnet}, {Action: RouteRemove, DestinationSubnet: subnet}})
+	assert.Error(t, err)
+}
+
+// scriptedShell answers every command through execute
+type scriptedShell struct {
+	execute func(cmd string) (string, string, error)
+}
+
+func (s *scriptedShell) Execute(cmd string) (string, string, error) {
+	return s.execute(cmd)
+}
+
+func (s *scriptedShell) Exit() {
+}
+
+func TestApplyRouteBatchDryRun(t *testing.T) {
+	_, pods, _ := net.ParseCIDR("10.244.1.0/24")
+	ops := []RouteOp{
+		{Action: RouteAdd, LinkIndex: 4, DestinationSubnet: pods, GatewayAddress: net.ParseIP("10.0.0.5"), RouteMetric: 10},
+		{Action: RouteRemove, LinkIndex: 4, DestinationSubnet: pods},
+	}
+
+	recorder := dryrun.NewRecorder()
+	nr := &shell{
+		pool:   newSingleSessionPool(NewFakeShell(t), 0),
+		dryRun: recorder,
+	}
+
+	results, err := nr.ApplyRouteBatch(ops)
+	assert.NoError(t, err)
+	assert.Equal(t, []RouteOpResult{{Op: ops[0]}, {Op: ops[1]}}, results)
+
+	entries := recorder.Entries()
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "ApplyRouteBatch", entries[0].Operation)
+	assert.Equal(t, []string{"add 4 10.244.1.0/24 10.0.0.5", "remove 4 10.244.1.0/24 0.0.0.0"}, entries[0].Args)
+	assert.Contains(t, entries[0].Command, "new-netroute -InterfaceIndex 4 -DestinationPrefix 10.244.1.0/24 -NextHop 10.0.0.5 -RouteMetric 10 -ErrorAction Stop")
+}
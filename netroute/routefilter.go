@@ -0,0 +1,171 @@
+package netroute
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/rakelkar/gonetsh/cmdline"
+)
+
+// RouteFilter narrows get-netroute results, zero values match everything
+type RouteFilter struct {
+	InterfaceIndex    int
+	InterfaceAlias    string
+	DestinationPrefix *net.IPNet
+	NextHop           net.IP
+	// AddressFamily is either IPv4 or IPv6
+	AddressFamily string
+	// Protocol is the origin of the route, e.g. NetMgmt, Local or Dhcp
+	Protocol string
+	// MinMetric and MaxMetric bound the route metric, they are applied client side
+	MinMetric int
+	MaxMetric int
+	// PolicyStore is either ActiveStore or PersistentStore
+	PolicyStore string
+}
+
+const routeProperties = "ifIndex,InterfaceAlias,DestinationPrefix,NextHop,RouteMetric,InterfaceMetric," +
+	"@{n='AddressFamily';e={$_.AddressFamily.ToString()}}," +
+	"@{n='Protocol';e={$_.Protocol.ToString()}}," +
+	"@{n='PolicyStore';e={$_.Store.ToString()}}"
+
+type routeJSON struct {
+	IfIndex           int `json:"ifIndex"`
+	InterfaceAlias    string
+	DestinationPrefix string
+	NextHop           string
+	RouteMetric       int
+	InterfaceMetric   int
+	AddressFamily     string
+	Protocol          string
+	PolicyStore       string
+}
+
+// GetNetRoutesFiltered returns the routes matching filter, passing every criterion get-netroute supports to it
+func (shell *shell) GetNetRoutesFiltered(filter RouteFilter) ([]Route, error) {
+	args, err := filter.args()
+	if err != nil {
+		return nil, err
+	}
+	getRouteCmdLine := "get-netroute" + args + " -erroraction Ignore"
+	var raw []routeJSON
+	if err := shell.runJSON(shell.scopeQuery(getRouteCmdLine), routeProperties, &raw); err != nil {
+		return nil, err
+	}
+
+	var routes []Route
+	for _, r := range raw {
+		_, destinationSubnet, err := net.ParseCIDR(r.DestinationPrefix)
+		if err != nil {
+			continue
+		}
+		gatewayAddress := net.ParseIP(r.NextHop)
+		if gatewayAddress == nil {
+			continue
+		}
+		if gatewayAddress.IsUnspecified() {
+			gatewayAddress = nil
+		}
+
+		route := Route{
+			LinkIndex:         r.IfIndex,
+			DestinationSubnet: destinationSubnet,
+			GatewayAddress:    gatewayAddress,
+			RouteMetric:       r.RouteMetric,
+			IfMetric:          r.InterfaceMetric,
+			InterfaceAlias:    r.InterfaceAlias,
+			AddressFamily:     r.AddressFamily,
+			Protocol:          r.Protocol,
+			PolicyStore:       r.PolicyStore,
+		}
+		if filter.Matches(route) {
+			routes = append(routes, route)
+		}
+	}
+
+	return routes, nil
+}
+
+// Matches returns true if route satisfies every criterion of filter
+func (filter RouteFilter) Matches(route Route) bool {
+	if filter.InterfaceIndex != 0 && route.LinkIndex != filter.InterfaceIndex {
+		return false
+	}
+	if filter.InterfaceAlias != "" && !strings.EqualFold(route.InterfaceAlias, filter.InterfaceAlias) {
+		return false
+	}
+	if filter.DestinationPrefix != nil {
+		if route.DestinationSubnet == nil || route.DestinationSubnet.String() != filter.DestinationPrefix.String() {
+			return false
+		}
+	}
+	if filter.NextHop != nil {
+		if filter.NextHop.IsUnspecified() != route.IsOnLink() {
+			return false
+		}
+		if !route.IsOnLink() && !filter.NextHop.Equal(route.GatewayAddress) {
+			return false
+		}
+	}
+	if filter.AddressFamily != "" && !strings.EqualFold(route.AddressFamily, filter.AddressFamily) {
+		return false
+	}
+	if filter.Protocol != "" && !strings.EqualFold(route.Protocol, filter.Protocol) {
+		return false
+	}
+	if filter.PolicyStore != "" && !strings.EqualFold(route.PolicyStore, filter.PolicyStore) {
+		return false
+	}
+	if filter.MinMetric != 0 && route.RouteMetric < filter.MinMetric {
+		return false
+	}
+	if filter.MaxMetric != 0 && route.RouteMetric > filter.MaxMetric {
+		return false
+	}
+	return true
+}
+
+// args renders the filter as get-netroute parameters, failing on values that are not safe to pass to powershell
+func (filter RouteFilter) args() (string, error) {
+	for _, keyword := range []string{filter.AddressFamily, filter.Protocol, filter.PolicyStore} {
+		if keyword != "" && cmdline.Word(keyword) != nil {
+			return "", fmt.Errorf("invalid route filter %q", keyword)
+		}
+	}
+	if filter.DestinationPrefix != nil {
+		if err := cmdline.CIDR(filter.DestinationPrefix); err != nil {
+			return "", fmt.Errorf("invalid route filter: %v", err)
+		}
+	}
+
+	var args []string
+	if filter.InterfaceIndex != 0 {
+		args = append(args, fmt.Sprintf("-InterfaceIndex %v", filter.InterfaceIndex))
+	}
+	if filter.InterfaceAlias != "" {
+		if err := cmdline.Name(filter.InterfaceAlias); err != nil {
+			return "", fmt.Errorf("invalid route filter: %v", err)
+		}
+		args = append(args, "-InterfaceAlias "+cmdline.PowerShellQuote(filter.InterfaceAlias))
+	}
+	if filter.DestinationPrefix != nil {
+		args = append(args, fmt.Sprintf("-DestinationPrefix %v", filter.DestinationPrefix.String()))
+	}
+	if filter.NextHop != nil {
+		args = append(args, fmt.Sprintf("-NextHop %v", filter.NextHop.String()))
+	}
+	if filter.AddressFamily != "" {
+		args = append(args, fmt.Sprintf("-AddressFamily %v", filter.AddressFamily))
+	}
+	if filter.Protocol != "" {
+		args = append(args, fmt.Sprintf("-Protocol %v", filter.Protocol))
+	}
+	if filter.PolicyStore != "" {
+		args = append(args, fmt.Sprintf("-PolicyStore %v", filter.PolicyStore))
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+	return " " + strings.Join(args, " "), nil
+}
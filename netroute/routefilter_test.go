@@ -0,0 +1,95 @@
+package netroute
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	GetRoutesJSONStdOut = `[{"ifIndex":12,"InterfaceAlias":"vEthernet (nat)","DestinationPrefix":"10.244.0.0/16","NextHop":"10.0.0.1","RouteMetric":256,"InterfaceMetric":15,"AddressFamily":"IPv4","Protocol":"NetMgmt","PolicyStore":"ActiveStore"},` +
+		`{"ifIndex":12,"InterfaceAlias":"vEthernet (nat)","DestinationPrefix":"10.245.0.0/16","NextHop":"10.0.0.1","RouteMetric":10,"InterfaceMetric":15,"AddressFamily":"IPv4","Protocol":"NetMgmt","PolicyStore":"ActiveStore"},` +
+		`{"ifIndex":12,"InterfaceAlias":"vEthernet (nat)","DestinationPrefix":"bogus","NextHop":"10.0.0.1","RouteMetric":10,"InterfaceMetric":15,"AddressFamily":"IPv4","Protocol":"NetMgmt","PolicyStore":"ActiveStore"}]`
+)
+
+func TestGetNetRoutesFiltered(t *testing.T) {
+	fs := NewFakeShell(t)
+	fs.RequestMap["ConvertTo-Json -Compress -InputObject @(get-netroute -InterfaceAlias 'vEthernet (nat)' -NextHop 10.0.0.1 -AddressFamily IPv4 -Protocol NetMgmt -PolicyStore ActiveStore -erroraction Ignore | select-object "+routeProperties+")"] = fakeResponse{
+		GetRoutesJSONStdOut,
+		"",
+		nil,
+	}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	routes, err := nr.GetNetRoutesFiltered(RouteFilter{
+		InterfaceAlias: "vEthernet (nat)",
+		NextHop:        net.ParseIP("10.0.0.1"),
+		AddressFamily:  "IPv4",
+		Protocol:       "NetMgmt",
+		PolicyStore:    "ActiveStore",
+		MinMetric:      100,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(routes))
+	_, subnet, _ := net.ParseCIDR("10.244.0.0/16")
+	assert.Equal(t, Route{
+		LinkIndex:         12,
+		DestinationSubnet: subnet,
+		GatewayAddress:    net.ParseIP("10.0.0.1"),
+		RouteMetric:       256,
+		IfMetric:          15,
+		InterfaceAlias:    "vEthernet (nat)",
+		AddressFamily:     "IPv4",
+		Protocol:          "NetMgmt",
+		PolicyStore:       "ActiveStore",
+	}, routes[0])
+}
+
+func TestRouteFilterMatches(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.244.0.0/16")
+	_, other, _ := net.ParseCIDR("10.244.0.0/24")
+	route := Route{
+		LinkIndex:         12,
+		DestinationSubnet: subnet,
+		GatewayAddress:    net.ParseIP("10.0.0.1"),
+		RouteMetric:       256,
+		InterfaceAlias:    "Ethernet",
+		AddressFamily:     "IPv4",
+		Protocol:          "NetMgmt",
+		PolicyStore:       "ActiveStore",
+	}
+
+	assert.True(t, RouteFilter{}.Matches(route))
+	assert.True(t, RouteFilter{InterfaceIndex: 12, DestinationPrefix: subnet, InterfaceAlias: "ethernet", MaxMetric: 256}.Matches(route))
+	assert.False(t, RouteFilter{InterfaceIndex: 13}.Matches(route))
+	assert.False(t, RouteFilter{DestinationPrefix: other}.Matches(route))
+	assert.False(t, RouteFilter{NextHop: net.ParseIP("10.0.0.2")}.Matches(route))
+	assert.False(t, RouteFilter{AddressFamily: "IPv6"}.Matches(route))
+	assert.False(t, RouteFilter{Protocol: "Local"}.Matches(route))
+	assert.False(t, RouteFilter{PolicyStore: "PersistentStore"}.Matches(route))
+	assert.False(t, RouteFilter{MaxMetric: 255}.Matches(route))
+	assert.False(t, RouteFilter{MinMetric: 257}.Matches(route))
+}
+
+func TestRouteFilterArgs(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.244.0.0/16")
+	args, err := RouteFilter{MinMetric: 1, MaxMetric: 2}.args()
+	assert.NoError(t, err)
+	assert.Equal(t, "", args)
+	args, err = RouteFilter{InterfaceIndex: 4, DestinationPrefix: subnet}.args()
+	assert.NoError(t, err)
+	assert.Equal(t, " -InterfaceIndex 4 -DestinationPrefix 10.244.0.0/16", args)
+	args, err = RouteFilter{InterfaceAlias: "O'Brien's NIC"}.args()
+	assert.NoError(t, err)
+	assert.Equal(t, " -InterfaceAlias 'O''Brien''s NIC'", args)
+
+	_, err = RouteFilter{Protocol: "NetMgmt; Remove-NetRoute"}.args()
+	assert.Error(t, err)
+	_, err = RouteFilter{InterfaceAlias: "Ethernet\nRemove-NetRoute"}.args()
+	assert.Error(t, err)
+}
@@ -0,0 +1,208 @@
+package netroute
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// loopbackCache holds the ifIndex of the loopback interface once it was looked up. It is not assumed to be 1, every
+// compartment has a loopback interface of its own.
+type loopbackCache struct {
+	mu    sync.Mutex
+	index int
+}
+
+// NextHop is one path of a MultipathRoute
+type NextHop struct {
+	LinkIndex int
+	// GatewayAddress is nil for an on-link next hop
+	GatewayAddress net.IP
+	RouteMetric    int
+}
+
+// MultipathRoute groups the routes to one destination, several next hops with the lowest metric make it ECMP
+type MultipathRoute struct {
+	DestinationSubnet *net.IPNet
+	NextHops          []NextHop
+}
+
+// IsOnLink returns true if the destination is reached directly on the link rather than through a gateway
+func (r *Route) IsOnLink() bool {
+	return r.GatewayAddress == nil || r.GatewayAddress.IsUnspecified()
+}
+
+// IsBlackhole returns true for an on-link route on the loopback interface to a destination that is not itself
+// loopback, multicast or broadcast. Windows drops the traffic of such routes. loopbackIndex is the ifIndex of the
+// loopback interface, as returned by GetLoopbackInterfaceIndex.
+func (r *Route) IsBlackhole(loopbackIndex int) bool {
+	if r.LinkIndex != loopbackIndex || !r.IsOnLink() || r.DestinationSubnet == nil {
+		return false
+	}
+	ip := r.DestinationSubnet.IP
+	return !ip.IsLoopback() && !ip.IsMulticast() && !ip.Equal(net.IPv4bcast)
+}
+
+// IsECMP returns true if traffic to the destination is spread over more than one equal cost next hop
+func (m *MultipathRoute) IsECMP() bool {
+	return len(m.EqualCostNextHops()) > 1
+}
+
+// EqualCostNextHops returns the next hops that share the lowest metric
+func (m *MultipathRoute) EqualCostNextHops() []NextHop {
+	var best []NextHop
+	for _, hop := range m.NextHops {
+		if len(best) == 0 || hop.RouteMetric < best[0].RouteMetric {
+			best = []NextHop{hop}
+		} else if hop.RouteMetric == best[0].RouteMetric {
+			best = append(best, hop)
+		}
+	}
+	return best
+}
+
+// GroupMultipath groups routes by destination, keeping the order in which destinations first appear
+func GroupMultipath(routes []Route) []MultipathRoute {
+	var grouped []MultipathRoute
+	byDestination := map[string]int{}
+	for _, route := range routes {
+		if route.DestinationSubnet == nil {
+			continue
+		}
+		key := route.DestinationSubnet.String()
+		i, ok := byDestination[key]
+		if !ok {
+			i = len(grouped)
+			byDestination[key] = i
+			grouped = append(grouped, MultipathRoute{DestinationSubnet: route.DestinationSubnet})
+		}
+		grouped[i].NextHops = append(grouped[i].NextHops, NextHop{
+			LinkIndex:      route.LinkIndex,
+			GatewayAddress: route.GatewayAddress,
+			RouteMetric:    route.RouteMetric,
+		})
+	}
+
+	for _, m := range grouped {
+		sort.SliceStable(m.NextHops, func(i, j int) bool { return m.NextHops[i].RouteMetric < m.NextHops[j].RouteMetric })
+	}
+	return grouped
+}
+
+// NewNetRouteMultipath creates one route per next hop. If a hop cannot be added the routes of the hops added before
+// it are removed again, so the route is either fully added or left as it was.
+func (shell *shell) NewNetRouteMultipath(route MultipathRoute) error {
+	if len(route.NextHops) == 0 {
+		return fmt.Errorf("no next hops given for %v", route.DestinationSubnet)
+	}
+
+	for _, hop := range route.NextHops {
+		if err := validateRoute(hop.LinkIndex, route.DestinationSubnet, hop.GatewayAddress); err != nil {
+			return err
+		}
+		if hop.RouteMetric < 0 {
+			return fmt.Errorf("invalid route metric %v", hop.RouteMetric)
+		}
+	}
+
+	for i, hop := range route.NextHops {
+		newRouteCmdLine := fmt.Sprintf("new-netroute -InterfaceIndex %v -DestinationPrefix %v -NextHop  %v%v -Verbose", hop.LinkIndex, route.DestinationSubnet.String(), nextHop(route.DestinationSubnet, hop.GatewayAddress), metricArg(hop.RouteMetric))
+		params := append(routeParams(hop.LinkIndex, route.DestinationSubnet, hop.GatewayAddress), strconv.Itoa(hop.RouteMetric))
+		if _, err := shell.mutate("NewNetRouteMultipath", params, shell.scopeMutation(hop.LinkIndex, newRouteCmdLine)); err != nil {
+			err = fmt.Errorf("failed to add next hop %v of %v: %v", nextHop(route.DestinationSubnet, hop.GatewayAddress), route.DestinationSubnet, err)
+			if undoErr := shell.removeNextHops("NewNetRouteMultipath", route.DestinationSubnet, route.NextHops[:i]); undoErr != nil {
+				return fmt.Errorf("%v, and %v", err, undoErr)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveNetRouteMultipath removes the route of every next hop, attempting all of them and reporting the failures
+func (shell *shell) RemoveNetRouteMultipath(route MultipathRoute) error {
+	return shell.removeNextHops("RemoveNetRouteMultipath", route.DestinationSubnet, route.NextHops)
+}
+
+// removeNextHops removes the routes of hops in reverse order on behalf of operation, attempting all of them
+func (shell *shell) removeNextHops(operation string, destinationSubnet *net.IPNet, hops []NextHop) error {
+	var failures []string
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := hops[i]
+		if err := shell.removeNetRoute(operation, hop.LinkIndex, destinationSubnet, hop.GatewayAddress); err != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", nextHop(destinationSubnet, hop.GatewayAddress), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to remove next hops of %v: %v", destinationSubnet, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// NewBlackholeRoute drops all traffic to destinationSubnet by routing it on-link to the loopback interface
+func (shell *shell) NewBlackholeRoute(destinationSubnet *net.IPNet) error {
+	loopbackIndex, err := shell.GetLoopbackInterfaceIndex()
+	if err != nil {
+		return err
+	}
+	return shell.NewNetRoute(loopbackIndex, destinationSubnet, nil)
+}
+
+// RemoveBlackholeRoute removes a route created by NewBlackholeRoute
+func (shell *shell) RemoveBlackholeRoute(destinationSubnet *net.IPNet) error {
+	loopbackIndex, err := shell.GetLoopbackInterfaceIndex()
+	if err != nil {
+		return err
+	}
+	return shell.RemoveNetRoute(loopbackIndex, destinationSubnet, nil)
+}
+
+// GetLoopbackInterfaceIndex looks up the ifIndex of the loopback interface of the compartment, it is cached once found
+func (shell *shell) GetLoopbackInterfaceIndex() (int, error) {
+	if shell.loopback != nil {
+		shell.loopback.mu.Lock()
+		defer shell.loopback.mu.Unlock()
+		if shell.loopback.index != 0 {
+			return shell.loopback.index, nil
+		}
+	}
+
+	getInterfaceCmdLine := "get-netipinterface -InterfaceAlias 'Loopback*' -AddressFamily IPv4 -erroraction Ignore"
+	var raw []struct {
+		IfIndex int `json:"ifIndex"`
+	}
+	if err := shell.runJSON(shell.scopeQuery(getInterfaceCmdLine), "ifIndex", &raw); err != nil {
+		return 0, err
+	}
+	if len(raw) == 0 || raw[0].IfIndex <= 0 {
+		return 0, fmt.Errorf("no loopback interface found")
+	}
+
+	if shell.loopback != nil {
+		shell.loopback.index = raw[0].IfIndex
+	}
+	return raw[0].IfIndex, nil
+}
+
+// nextHop renders the next hop of a route, the unspecified address of the destination's family for on-link routes
+func nextHop(destinationSubnet *net.IPNet, gatewayAddress net.IP) string {
+	if gatewayAddress != nil && !gatewayAddress.IsUnspecified() {
+		return gatewayAddress.String()
+	}
+	if destinationSubnet != nil && destinationSubnet.IP.To4() == nil {
+		return net.IPv6unspecified.String()
+	}
+	return net.IPv4zero.String()
+}
+
+func metricArg(metric int) string {
+	if metric == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" -RouteMetric %v", metric)
+}
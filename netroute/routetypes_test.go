@@ -0,0 +1,251 @@
+package netroute
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/rakelkar/gonetsh/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOnLinkRoutes(t *testing.T) {
+	routes := parseRoutesList(GetRouteStdOut)
+
+	assert.Equal(t, 3, len(routes))
+	assert.Nil(t, routes[0].GatewayAddress)
+	assert.True(t, routes[0].IsOnLink())
+	assert.False(t, routes[1].IsOnLink())
+	assert.Equal(t, "10.244.0.1", routes[1].GatewayAddress.String())
+	assert.Equal(t, 256, routes[1].RouteMetric)
+	assert.Equal(t, 35, routes[1].IfMetric)
+	assert.Equal(t, 65, routes[2].IfMetric)
+}
+
+func TestRouteEqualComparesFullKey(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/8")
+	route := Route{LinkIndex: 4, DestinationSubnet: subnet, GatewayAddress: net.ParseIP("10.244.0.1")}
+
+	assert.True(t, route.Equal(Route{LinkIndex: 4, DestinationSubnet: subnet, GatewayAddress: net.ParseIP("10.244.0.1")}))
+	assert.False(t, route.Equal(Route{LinkIndex: 5, DestinationSubnet: subnet, GatewayAddress: net.ParseIP("10.244.0.1")}))
+	assert.False(t, route.Equal(Route{LinkIndex: 4, DestinationSubnet: subnet, GatewayAddress: net.ParseIP("10.244.0.2")}))
+	assert.False(t, route.Equal(Route{LinkIndex: 4, DestinationSubnet: subnet}))
+
+	onLink := Route{LinkIndex: 4, DestinationSubnet: subnet}
+	assert.True(t, onLink.Equal(Route{LinkIndex: 4, DestinationSubnet: subnet, GatewayAddress: net.IPv4zero}))
+}
+
+func TestOnLinkNextHop(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("10.0.0.0/8")
+	_, v6, _ := net.ParseCIDR("fd00::/64")
+
+	fs := NewFakeShell(t)
+	fs.RequestMap["new-netroute -InterfaceIndex 4 -DestinationPrefix 10.0.0.0/8 -NextHop  0.0.0.0 -Verbose"] = fakeResponse{}
+	fs.RequestMap["new-netroute -InterfaceIndex 4 -DestinationPrefix fd00::/64 -NextHop  :: -Verbose"] = fakeResponse{}
+	fs.RequestMap["remove-netroute -InterfaceIndex 4 -DestinationPrefix fd00::/64 -NextHop  :: -Verbose -Confirm:$false"] = fakeResponse{}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	assert.NoError(t, nr.NewNetRoute(4, v4, nil))
+	assert.NoError(t, nr.NewNetRoute(4, v6, nil))
+	assert.NoError(t, nr.RemoveNetRoute(4, v6, net.IPv6unspecified))
+}
+
+func TestNetRouteValidation(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("10.0.0.0/8")
+	_, v6, _ := net.ParseCIDR("fd00::/64")
+
+	nr := &shell{
+		pool: newSingleSessionPool(NewFakeShell(t), 0),
+	}
+
+	for name, tc := range map[string]struct {
+		linkIndex         int
+		destinationSubnet *net.IPNet
+		gatewayAddress    net.IP
+	}{
+		"nil destination":       {4, nil, nil},
+		"zero interface index":  {0, v4, nil},
+		"negative index":        {-1, v4, nil},
+		"non canonical mask":    {4, &net.IPNet{IP: net.IP{10, 0, 0, 0}, Mask: net.IPMask{255, 0, 255, 0}}, nil},
+		"mask of other family":  {4, &net.IPNet{IP: net.ParseIP("fd00::"), Mask: net.CIDRMask(8, 32)}, nil},
+		"malformed gateway":     {4, v4, net.IP{10, 0, 1}},
+		"ipv6 gateway for ipv4": {4, v4, net.ParseIP("fd00::1")},
+		"ipv4 gateway for ipv6": {4, v6, net.ParseIP("10.0.0.1")},
+		"ipv4 on-link for ipv6": {4, v6, net.IPv4zero},
+	} {
+		assert.Error(t, nr.NewNetRoute(tc.linkIndex, tc.destinationSubnet, tc.gatewayAddress), name)
+		assert.Error(t, nr.RemoveNetRoute(tc.linkIndex, tc.destinationSubnet, tc.gatewayAddress), name)
+		assert.Error(t, nr.NewNetRouteMultipath(MultipathRoute{
+			DestinationSubnet: tc.destinationSubnet,
+			NextHops:          []NextHop{{LinkIndex: tc.linkIndex, GatewayAddress: tc.gatewayAddress}},
+		}), name)
+	}
+
+	// a bad hop fails the route before any hop is added
+	assert.Error(t, nr.NewNetRouteMultipath(MultipathRoute{
+		DestinationSubnet: v4,
+		NextHops:          []NextHop{{LinkIndex: 4, GatewayAddress: net.ParseIP("10.0.0.1")}, {LinkIndex: 5, RouteMetric: -1}},
+	}))
+}
+
+func TestBlackholeRoutes(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("192.0.2.0/24")
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	getLoopback := "ConvertTo-Json -Compress -InputObject @(get-netipinterface -InterfaceAlias 'Loopback*' -AddressFamily IPv4 -erroraction Ignore | select-object ifIndex)"
+
+	fs := NewFakeShell(t)
+	fs.RequestMap[getLoopback] = fakeResponse{`{"ifIndex":1}`, "", nil}
+	fs.RequestMap["new-netroute -InterfaceIndex 1 -DestinationPrefix 192.0.2.0/24 -NextHop  0.0.0.0 -Verbose"] = fakeResponse{}
+	fs.RequestMap["remove-netroute -InterfaceIndex 1 -DestinationPrefix 192.0.2.0/24 -NextHop  0.0.0.0 -Verbose -Confirm:$false"] = fakeResponse{}
+
+	nr := &shell{
+		pool:     newSingleSessionPool(fs, 0),
+		loopback: &loopbackCache{},
+	}
+
+	assert.NoError(t, nr.NewBlackholeRoute(subnet))
+	// the index is looked up once
+	delete(fs.RequestMap, getLoopback)
+	assert.NoError(t, nr.RemoveBlackholeRoute(subnet))
+
+	blackhole := Route{LinkIndex: 1, DestinationSubnet: subnet}
+	assert.True(t, blackhole.IsBlackhole(1))
+	assert.False(t, blackhole.IsBlackhole(41))
+	assert.False(t, (&Route{LinkIndex: 1, DestinationSubnet: loopback}).IsBlackhole(1))
+	assert.False(t, (&Route{LinkIndex: 4, DestinationSubnet: subnet}).IsBlackhole(1))
+	assert.False(t, (&Route{LinkIndex: 1, DestinationSubnet: subnet, GatewayAddress: net.ParseIP("10.0.0.1")}).IsBlackhole(1))
+}
+
+func TestBlackholeRoutesInCompartment(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("192.0.2.0/24")
+
+	fs := NewFakeShell(t)
+	fs.RequestMap["$ifs = @(get-netipinterface -IncludeAllCompartments -erroraction Ignore | where-object CompartmentId -eq 2 | foreach-object ifIndex); "+
+		"ConvertTo-Json -Compress -InputObject @(get-netipinterface -IncludeAllCompartments -InterfaceAlias 'Loopback*' -AddressFamily IPv4 -erroraction Ignore | where-object { $ifs -contains $_.InterfaceIndex } | select-object ifIndex)"] = fakeResponse{`[{"ifIndex":41}]`, "", nil}
+	fs.RequestMap["$ifs = @(get-netipinterface -IncludeAllCompartments -erroraction Ignore | where-object CompartmentId -eq 2 | foreach-object ifIndex); "+
+		"if ($ifs -notcontains 41) { throw 'interface 41 is not in compartment 2' }; new-netroute -InterfaceIndex 41 -DestinationPrefix 192.0.2.0/24 -NextHop  0.0.0.0 -Verbose"] = fakeResponse{}
+
+	nr := &shell{
+		pool:     newSingleSessionPool(fs, 0),
+		loopback: &loopbackCache{},
+	}
+
+	assert.NoError(t, nr.InCompartment(2).NewBlackholeRoute(subnet))
+
+	fs.DefaultResponse = &fakeResponse{"[]", "", nil}
+	assert.Error(t, nr.NewBlackholeRoute(subnet))
+}
+
+func TestGroupMultipath(t *testing.T) {
+	_, pods, _ := net.ParseCIDR("10.244.0.0/16")
+	_, other, _ := net.ParseCIDR("10.96.0.0/12")
+	routes := []Route{
+		{LinkIndex: 4, DestinationSubnet: pods, GatewayAddress: net.ParseIP("10.0.0.1"), RouteMetric: 256},
+		{LinkIndex: 5, DestinationSubnet: other, GatewayAddress: net.ParseIP("10.0.1.1"), RouteMetric: 256},
+		{LinkIndex: 5, DestinationSubnet: pods, GatewayAddress: net.ParseIP("10.0.1.1"), RouteMetric: 10},
+		{LinkIndex: 6, DestinationSubnet: pods, GatewayAddress: net.ParseIP("10.0.2.1"), RouteMetric: 10},
+	}
+
+	grouped := GroupMultipath(routes)
+
+	assert.Equal(t, 2, len(grouped))
+	assert.Equal(t, "10.244.0.0/16", grouped[0].DestinationSubnet.String())
+	assert.Equal(t, 3, len(grouped[0].NextHops))
+	assert.True(t, grouped[0].IsECMP())
+	assert.Equal(t, []NextHop{
+		{LinkIndex: 5, GatewayAddress: net.ParseIP("10.0.1.1"), RouteMetric: 10},
+		{LinkIndex: 6, GatewayAddress: net.ParseIP("10.0.2.1"), RouteMetric: 10},
+	}, grouped[0].EqualCostNextHops())
+	assert.False(t, grouped[1].IsECMP())
+}
+
+func TestNewAndRemoveNetRouteMultipath(t *testing.T) {
+	_, pods, _ := net.ParseCIDR("10.244.0.0/16")
+	route := MultipathRoute{
+		DestinationSubnet: pods,
+		NextHops: []NextHop{
+			{LinkIndex: 5, GatewayAddress: net.ParseIP("10.0.1.1"), RouteMetric: 10},
+			{LinkIndex: 6, GatewayAddress: net.ParseIP("10.0.2.1")},
+		},
+	}
+
+	fs := NewFakeShell(t)
+	fs.RequestMap["new-netroute -InterfaceIndex 5 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.1.1 -RouteMetric 10 -Verbose"] = fakeResponse{}
+	fs.RequestMap["new-netroute -InterfaceIndex 6 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.2.1 -Verbose"] = fakeResponse{}
+	fs.RequestMap["remove-netroute -InterfaceIndex 5 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.1.1 -Verbose -Confirm:$false"] = fakeResponse{Err: errors.New("not found")}
+	fs.RequestMap["remove-netroute -InterfaceIndex 6 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.2.1 -Verbose -Confirm:$false"] = fakeResponse{}
+
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+	}
+
+	assert.NoError(t, nr.NewNetRouteMultipath(route))
+	err := nr.RemoveNetRouteMultipath(route)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "10.0.1.1")
+	assert.NotContains(t, err.Error(), "10.0.2.1")
+
+	assert.Error(t, nr.NewNetRouteMultipath(MultipathRoute{DestinationSubnet: pods}))
+}
+
+func TestNewNetRouteMultipathRemovesAddedHopsOnFailure(t *testing.T) {
+	_, pods, _ := net.ParseCIDR("10.244.0.0/16")
+	route := MultipathRoute{
+		DestinationSubnet: pods,
+		NextHops: []NextHop{
+			{LinkIndex: 5, GatewayAddress: net.ParseIP("10.0.1.1")},
+			{LinkIndex: 6, GatewayAddress: net.ParseIP("10.0.2.1")},
+			{LinkIndex: 7, GatewayAddress: net.ParseIP("10.0.3.1")},
+		},
+	}
+
+	fs := NewFakeShell(t)
+	fs.RequestMap["new-netroute -InterfaceIndex 5 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.1.1 -Verbose"] = fakeResponse{}
+	fs.RequestMap["new-netroute -InterfaceIndex 6 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.2.1 -Verbose"] = fakeResponse{}
+	fs.RequestMap["new-netroute -InterfaceIndex 7 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.3.1 -Verbose"] = fakeResponse{Err: errors.New("element not found")}
+	fs.RequestMap["remove-netroute -InterfaceIndex 6 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.2.1 -Verbose -Confirm:$false"] = fakeResponse{}
+	fs.RequestMap["remove-netroute -InterfaceIndex 5 -DestinationPrefix 10.244.0.0/16 -NextHop  10.0.1.1 -Verbose -Confirm:$false"] = fakeResponse{Err: errors.New("access denied")}
+
+	var scripts []string
+	nr := &shell{
+		pool: newSingleSessionPool(fs, 0),
+		observer: observer.Func(func(event observer.Event) {
+			scripts = append(scripts, event.Args[0])
+		}),
+	}
+
+	err := nr.NewNetRouteMultipath(route)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to add next hop 10.0.3.1")
+	assert.Contains(t, err.Error(), "10.0.1.1: access denied")
+	assert.Equal(t, 5, len(scripts))
+	assert.True(t, strings.HasPrefix(scripts[3], "remove-netroute -InterfaceIndex 6"))
+	assert.True(t, strings.HasPrefix(scripts[4], "remove-netroute -InterfaceIndex 5"))
+}
+
+func TestMultipathDryRunOperations(t *testing.T) {
+	_, pods, _ := net.ParseCIDR("10.244.0.0/16")
+	route := MultipathRoute{
+		DestinationSubnet: pods,
+		NextHops:          []NextHop{{LinkIndex: 5, GatewayAddress: net.ParseIP("10.0.1.1"), RouteMetric: 10}},
+	}
+	recorder := dryrun.NewRecorder()
+	nr := &shell{
+		pool:   newSingleSessionPool(NewFakeShell(t), 0),
+		dryRun: recorder,
+	}
+
+	assert.NoError(t, nr.NewNetRouteMultipath(route))
+	assert.NoError(t, nr.RemoveNetRouteMultipath(route))
+
+	entries := recorder.Entries()
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "NewNetRouteMultipath", entries[0].Operation)
+	assert.Equal(t, "RemoveNetRouteMultipath", entries[1].Operation)
+	assert.Equal(t, []string{"5", "10.244.0.0/16", "10.0.1.1"}, entries[1].Args)
+}
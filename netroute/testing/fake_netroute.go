@@ -0,0 +1,155 @@
+package testing
+
+import (
+	"net"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+)
+
+// no-op implementation of netroute Interface
+type FakeNetroute struct {
+}
+
+func NewFake() *FakeNetroute {
+	return &FakeNetroute{}
+}
+
+// Get all net routes on the host
+func (*FakeNetroute) GetNetRoutesAll() ([]netroute.Route, error) {
+	return nil, nil
+}
+
+// Get net routes by link and destination subnet
+func (*FakeNetroute) GetNetRoutes(linkIndex int, destinationSubnet *net.IPNet) ([]netroute.Route, error) {
+	return nil, nil
+}
+
+// Get net routes matching the filter
+func (*FakeNetroute) GetNetRoutesFiltered(filter netroute.RouteFilter) ([]netroute.Route, error) {
+	return nil, nil
+}
+
+// Create a new route
+func (*FakeNetroute) NewNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	return nil
+}
+
+// Remove an existing route
+func (*FakeNetroute) RemoveNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	return nil
+}
+
+// Apply route additions and removals in bulk, returning one result per op
+func (*FakeNetroute) ApplyRouteBatch(ops []netroute.RouteOp) ([]netroute.RouteOpResult, error) {
+	results := make([]netroute.RouteOpResult, 0, len(ops))
+	for _, op := range ops {
+		results = append(results, netroute.RouteOpResult{Op: op})
+	}
+	return results, nil
+}
+
+// Create one route per next hop of a multipath route
+func (*FakeNetroute) NewNetRouteMultipath(route netroute.MultipathRoute) error {
+	return nil
+}
+
+// Remove the routes of every next hop of a multipath route
+func (*FakeNetroute) RemoveNetRouteMultipath(route netroute.MultipathRoute) error {
+	return nil
+}
+
+// Create a route that drops all traffic to the destination
+func (*FakeNetroute) NewBlackholeRoute(destinationSubnet *net.IPNet) error {
+	return nil
+}
+
+// Remove a route created by NewBlackholeRoute
+func (*FakeNetroute) RemoveBlackholeRoute(destinationSubnet *net.IPNet) error {
+	return nil
+}
+
+// Get the ifIndex of the loopback interface
+func (*FakeNetroute) GetLoopbackInterfaceIndex() (int, error) {
+	return 1, nil
+}
+
+// Get ip addresses matching the filter
+func (*FakeNetroute) GetNetIPAddresses(filter netroute.IPAddressFilter) ([]netroute.IPAddress, error) {
+	return nil, nil
+}
+
+// Assign a new ip address to an interface
+func (*FakeNetroute) NewNetIPAddress(linkIndex int, address net.IP, prefixLength int) error {
+	return nil
+}
+
+// Remove an ip address from an interface
+func (*FakeNetroute) RemoveNetIPAddress(linkIndex int, address net.IP) error {
+	return nil
+}
+
+// Get the network adapters on the host, including hidden ones
+func (*FakeNetroute) GetNetAdapters() ([]netroute.NetAdapter, error) {
+	return nil, nil
+}
+
+// Get the WinNAT instances on the host
+func (*FakeNetroute) GetNetNats() ([]netroute.NetNat, error) {
+	return nil, nil
+}
+
+// Create a WinNAT instance for an internal prefix
+func (*FakeNetroute) NewNetNat(name string, internalPrefix *net.IPNet) error {
+	return nil
+}
+
+// Remove a WinNAT instance
+func (*FakeNetroute) RemoveNetNat(name string) error {
+	return nil
+}
+
+// Get the static mappings of a WinNAT instance, or of all instances if natName is empty
+func (*FakeNetroute) GetNetNatStaticMappings(natName string) ([]netroute.NetNatStaticMapping, error) {
+	return nil, nil
+}
+
+// Create a static mapping unless an identical one exists
+func (*FakeNetroute) EnsureNetNatStaticMapping(mapping netroute.NetNatStaticMapping) (bool, error) {
+	return true, nil
+}
+
+// Remove a static mapping
+func (*FakeNetroute) RemoveNetNatStaticMapping(natName string, staticMappingID int) error {
+	return nil
+}
+
+// Get the entries of the DNS client cache
+func (*FakeNetroute) GetDNSCache() ([]netroute.DNSCacheEntry, error) {
+	return nil, nil
+}
+
+// Flush the DNS client cache
+func (*FakeNetroute) ClearDNSCache() error {
+	return nil
+}
+
+// Query DNS for a name, bypassing the client cache
+func (*FakeNetroute) ResolveName(name string, recordType string, server net.IP) ([]netroute.DNSRecord, error) {
+	return nil, nil
+}
+
+// Get the network compartments on the host
+func (*FakeNetroute) GetNetCompartments() ([]netroute.Compartment, error) {
+	return nil, nil
+}
+
+// Get an Interface limited to the interfaces of a network compartment
+func (f *FakeNetroute) InCompartment(id int) netroute.Interface {
+	return f
+}
+
+// exit the shell
+func (*FakeNetroute) Exit() {
+}
+
+var _ = netroute.Interface(&FakeNetroute{})
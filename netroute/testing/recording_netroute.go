@@ -0,0 +1,110 @@
+package testing
+
+import (
+	"fmt"
+	"net"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+)
+
+// RecordingNetroute keeps the routes of a host in memory. Every mutation is logged to Calls in order, a mutation
+// whose log entry is a key of Fail returns that error and leaves the routes unchanged.
+type RecordingNetroute struct {
+	*FakeNetroute
+	// Calls is the log of mutations, share it with a fakenetsh.RecordingNetsh to interleave their calls
+	Calls  *[]string
+	Fail   map[string]error
+	Routes []netroute.Route
+}
+
+// NewRecording returns a host without routes that logs its mutations to calls, a nil calls gets a log of its own
+func NewRecording(calls *[]string) *RecordingNetroute {
+	if calls == nil {
+		calls = &[]string{}
+	}
+	return &RecordingNetroute{
+		FakeNetroute: NewFake(),
+		Calls:        calls,
+		Fail:         map[string]error{},
+	}
+}
+
+// call logs a mutation and returns the error it is set to fail with
+func (f *RecordingNetroute) call(format string, args ...interface{}) error {
+	call := fmt.Sprintf(format, args...)
+	*f.Calls = append(*f.Calls, call)
+	return f.Fail[call]
+}
+
+func (f *RecordingNetroute) GetNetRoutesAll() ([]netroute.Route, error) {
+	return f.Routes, nil
+}
+
+func (f *RecordingNetroute) GetNetRoutes(linkIndex int, destinationSubnet *net.IPNet) ([]netroute.Route, error) {
+	return f.GetNetRoutesFiltered(netroute.RouteFilter{InterfaceIndex: linkIndex, DestinationPrefix: destinationSubnet})
+}
+
+func (f *RecordingNetroute) GetNetRoutesFiltered(filter netroute.RouteFilter) ([]netroute.Route, error) {
+	var routes []netroute.Route
+	for _, route := range f.Routes {
+		if filter.Matches(route) {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+func (f *RecordingNetroute) NewNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	if err := f.call("add route %v %v %v", linkIndex, destinationSubnet, gatewayAddress); err != nil {
+		return err
+	}
+	f.Routes = append(f.Routes, netroute.Route{LinkIndex: linkIndex, DestinationSubnet: destinationSubnet, GatewayAddress: gatewayAddress})
+	return nil
+}
+
+func (f *RecordingNetroute) RemoveNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	if err := f.call("remove route %v %v %v", linkIndex, destinationSubnet, gatewayAddress); err != nil {
+		return err
+	}
+	removed := netroute.Route{LinkIndex: linkIndex, DestinationSubnet: destinationSubnet, GatewayAddress: gatewayAddress}
+	var kept []netroute.Route
+	for _, route := range f.Routes {
+		if !route.Equal(removed) {
+			kept = append(kept, route)
+		}
+	}
+	f.Routes = kept
+	return nil
+}
+
+// NewNetRouteMultipath adds the route of each next hop in turn, stopping at the first failure
+func (f *RecordingNetroute) NewNetRouteMultipath(route netroute.MultipathRoute) error {
+	for _, hop := range route.NextHops {
+		if err := f.call("add route %v %v %v metric %v", hop.LinkIndex, route.DestinationSubnet, hop.GatewayAddress, hop.RouteMetric); err != nil {
+			return err
+		}
+		f.Routes = append(f.Routes, netroute.Route{
+			LinkIndex:         hop.LinkIndex,
+			DestinationSubnet: route.DestinationSubnet,
+			GatewayAddress:    hop.GatewayAddress,
+			RouteMetric:       hop.RouteMetric,
+		})
+	}
+	return nil
+}
+
+// RemoveNetRouteMultipath removes the route of each next hop, stopping at the first failure
+func (f *RecordingNetroute) RemoveNetRouteMultipath(route netroute.MultipathRoute) error {
+	for _, hop := range route.NextHops {
+		if err := f.RemoveNetRoute(hop.LinkIndex, route.DestinationSubnet, hop.GatewayAddress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *RecordingNetroute) InCompartment(id int) netroute.Interface {
+	return f
+}
+
+var _ = netroute.Interface(&RecordingNetroute{})
@@ -0,0 +1,125 @@
+package netsh
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GetIPAddresses lists every IPv4 address of an interface, unlike GetInterfaces which keeps only the last one
+func (runner *runner) GetIPAddresses(iface string) ([]*net.IPNet, error) {
+	if err := checkInterface(iface); err != nil {
+		return nil, err
+	}
+
+	args := runner.ipv4Args(
+		"interface", "ipv4", "show", "addresses", iface,
+	)
+	cmd := strings.Join(args, " ")
+	output, err := runner.queryNetsh(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses of [%v], error: %v. cmd: %v. stdout: %v", iface, err.Error(), cmd, string(output))
+	}
+
+	return parseIPAddresses(string(output)), nil
+}
+
+// AddIPAddress adds a static IPv4 address to an interface
+func (runner *runner) AddIPAddress(iface string, address *net.IPNet) error {
+	defer runner.lock(interfaceResource(iface))()
+
+	args, err := runner.addAddressArgs(iface, address)
+	if err != nil {
+		return err
+	}
+
+	cmd := strings.Join(args, " ")
+	if stdout, err := runner.mutateNetsh("AddIPAddress", []string{iface, address.String()}, args...); err != nil {
+		return fmt.Errorf("failed to add address %v to [%v], error: %v. cmd: %v. stdout: %v", address, iface, err.Error(), cmd, string(stdout))
+	}
+
+	return nil
+}
+
+// RemoveIPAddress removes a static IPv4 address from an interface
+func (runner *runner) RemoveIPAddress(iface string, ip net.IP) error {
+	defer runner.lock(interfaceResource(iface))()
+
+	args, err := runner.deleteAddressArgs(iface, ip)
+	if err != nil {
+		return err
+	}
+
+	cmd := strings.Join(args, " ")
+	if stdout, err := runner.mutateNetsh("RemoveIPAddress", []string{iface, ip.String()}, args...); err != nil {
+		return fmt.Errorf("failed to remove address %v from [%v], error: %v. cmd: %v. stdout: %v", ip, iface, err.Error(), cmd, string(stdout))
+	}
+
+	return nil
+}
+
+func (runner *runner) addAddressArgs(iface string, address *net.IPNet) ([]string, error) {
+	if err := checkInterface(iface); err != nil {
+		return nil, err
+	}
+	if address == nil || address.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid ipv4 address %v for [%v]", address, iface)
+	}
+	ones, bits := address.Mask.Size()
+	if bits != 32 {
+		return nil, fmt.Errorf("invalid mask for address %v of [%v]", address, iface)
+	}
+	mask := net.IP(net.CIDRMask(ones, 32)).String()
+
+	return runner.ipv4Args(
+		"interface", "ipv4", "add", "address", "name="+iface, "address="+address.IP.To4().String(), "mask="+mask,
+	), nil
+}
+
+func (runner *runner) deleteAddressArgs(iface string, ip net.IP) ([]string, error) {
+	if err := checkInterface(iface); err != nil {
+		return nil, err
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("invalid ipv4 address %v for [%v]", ip, iface)
+	}
+
+	return runner.ipv4Args(
+		"interface", "ipv4", "delete", "address", "name="+iface, "address="+ip.To4().String(),
+	), nil
+}
+
+// parseIPAddresses pairs each "IP Address" line of show addresses with the "Subnet Prefix" line that follows it
+func parseIPAddresses(output string) []*net.IPNet {
+	prefixPattern := regexp.MustCompile(`/(\d+)`)
+
+	var addresses []*net.IPNet
+	var current net.IP
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		if strings.HasPrefix(key, "IP Address") {
+			current = net.ParseIP(value).To4()
+		} else if strings.HasPrefix(key, "Subnet Prefix") && current != nil {
+			match := prefixPattern.FindStringSubmatch(value)
+			if match == nil {
+				continue
+			}
+			ones, err := strconv.Atoi(match[1])
+			if err != nil || ones > 32 {
+				continue
+			}
+			addresses = append(addresses, &net.IPNet{IP: current, Mask: net.CIDRMask(ones, 32)})
+			current = nil
+		}
+	}
+
+	return addresses
+}
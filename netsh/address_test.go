@@ -0,0 +1,76 @@
+package netsh
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestGetIPAddresses(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte(`
+
+Configuration for interface "vEthernet (nat)"
+    DHCP enabled:                         No
+    IP Address:                           172.23.0.1
+    Subnet Prefix:                        172.23.0.0/20 (mask 255.255.240.0)
+    IP Address:                           172.23.16.5
+    Subnet Prefix:                        172.23.16.0/24 (mask 255.255.255.0)
+    InterfaceMetric:                      15
+
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("Element not found."), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	addresses, err := runner.GetIPAddresses("vEthernet (nat)")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"netsh", "interface", "ipv4", "show", "addresses", "vEthernet (nat)"}, fakeCmd.CombinedOutputLog[0])
+	assert.Equal(t, 2, len(addresses))
+	assert.Equal(t, "172.23.0.1/20", addresses[0].String())
+	assert.Equal(t, "172.23.16.5/24", addresses[1].String())
+
+	addresses, err = runner.GetIPAddresses("missing")
+	assert.Error(t, err)
+	assert.Nil(t, addresses)
+}
+
+func TestAddAndRemoveIPAddress(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	ip, address, _ := net.ParseCIDR("10.0.0.5/24")
+	address.IP = ip
+	assert.NoError(t, runner.AddIPAddress("Ethernet", address))
+	assert.NoError(t, runner.RemoveIPAddress("Ethernet", ip))
+	assert.EqualValues(t, strings.Split(`netsh interface ipv4 add address name=Ethernet address=10.0.0.5 mask=255.255.255.0`, " "), fakeCmd.CombinedOutputLog[0])
+	assert.EqualValues(t, strings.Split(`netsh interface ipv4 delete address name=Ethernet address=10.0.0.5`, " "), fakeCmd.CombinedOutputLog[1])
+
+	assert.Error(t, runner.AddIPAddress("Ethernet", nil))
+	assert.Error(t, runner.RemoveIPAddress("Ethernet", net.ParseIP("fd00::1")))
+	assert.Equal(t, 2, fakeCmd.CombinedOutputCalls)
+}
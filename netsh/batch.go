@@ -0,0 +1,384 @@
+package netsh
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rakelkar/gonetsh/cmdline"
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/rakelkar/gonetsh/observer"
+	utilexec "k8s.io/utils/exec"
+)
+
+// BatchOpKind is what a BatchOp changes
+type BatchOpKind int
+
+const (
+	BatchAddPortProxyRule BatchOpKind = iota
+	BatchDeletePortProxyRule
+	BatchAddIPAddress
+	BatchDeleteIPAddress
+	BatchSetForwarding
+)
+
+// ErrNotApplied is reported for a batch operation whose change is missing from the host after the batch ran
+var ErrNotApplied = errors.New("operation did not take effect")
+
+// BatchOp is a single change queued on a Batch
+type BatchOp struct {
+	Kind BatchOpKind
+	// PortProxyRule is the rule added or deleted by the port proxy ops, an empty Protocol means v4tov4
+	PortProxyRule PortProxyRule
+	// Interface is the name of the interface changed by the address and forwarding ops
+	Interface string
+	// Address is added by BatchAddIPAddress, only its IP is used by BatchDeleteIPAddress
+	Address *net.IPNet
+	// Forwarding is the state set by BatchSetForwarding
+	Forwarding bool
+}
+
+// BatchOpResult reports the outcome of a BatchOp, Err is nil if its change is in effect
+type BatchOpResult struct {
+	Op  BatchOp
+	Err error
+}
+
+// Batch queues netsh changes and runs them as one `netsh -f` script
+type Batch struct {
+	nsh Interface
+	// runner scopes the interface commands to its compartment, it is nil for batches of other Interfaces
+	runner *runner
+	ops    []BatchOp
+	// dryRun skips the verification, the script was only recorded
+	dryRun bool
+}
+
+// NewBatch returns an empty Batch run by nsh, it skips the verification if nsh reports being in dry-run mode
+func NewBatch(nsh Interface) *Batch {
+	batch := &Batch{
+		nsh:    nsh,
+		dryRun: nsh.DryRun(),
+	}
+	if runner, ok := nsh.(*runner); ok {
+		batch.runner = runner
+	}
+	return batch
+}
+
+// NewBatch is part of Interface.
+func (runner *runner) NewBatch() *Batch {
+	return NewBatch(runner)
+}
+
+// DryRun is part of Interface.
+func (runner *runner) DryRun() bool {
+	return runner.dryRun != nil
+}
+
+// RunScript writes script to a temporary file and runs it with `netsh -f`. The script always runs in its own
+// netsh process, even in interactive mode. When netsh runs on another machine the script is piped to it instead.
+// In dry-run mode the script is recorded instead.
+func (runner *runner) RunScript(script string) ([]byte, error) {
+	defer runner.lock("")()
+
+	if runner.dryRun != nil {
+		runner.dryRun.Record(dryrun.Entry{
+			Package:   "netsh",
+			Operation: "RunScript",
+			Command:   script,
+		})
+		return nil, nil
+	}
+
+	if runner.isRemoteExec() {
+		return runner.pipeScript(script)
+	}
+
+	file, err := ioutil.TempFile("", "gonetsh-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create netsh script: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(script); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write netsh script: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write netsh script: %v", err)
+	}
+
+	args := runner.netshArgs("-f", file.Name())
+	runner.concurrency.spawn()
+	command := observer.Start(runner.observer, observer.Event{Package: "netsh", Program: cmdNetsh, Args: args, Input: script})
+	output, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput()
+	command.Done(output, err)
+	return output, err
+}
+
+// pipeScript runs script by piping it to netsh, for machines where the temporary file cannot be read
+func (runner *runner) pipeScript(script string) ([]byte, error) {
+	args := runner.netshArgs()
+	runner.concurrency.spawn()
+	command := observer.Start(runner.observer, observer.Event{Package: "netsh", Program: cmdNetsh, Args: args, Input: script})
+	cmd := runner.exec.Command(cmdNetsh, args...)
+	cmd.SetStdin(strings.NewReader(script + "\r\nexit\r\n"))
+	output, err := cmd.CombinedOutput()
+	command.Done(output, err)
+	return output, err
+}
+
+// AddPortProxyRule queues adding rule
+func (b *Batch) AddPortProxyRule(rule PortProxyRule) *Batch {
+	b.ops = append(b.ops, BatchOp{Kind: BatchAddPortProxyRule, PortProxyRule: rule})
+	return b
+}
+
+// DeletePortProxyRule queues deleting the rule listening on rule's protocol, address and port
+func (b *Batch) DeletePortProxyRule(rule PortProxyRule) *Batch {
+	b.ops = append(b.ops, BatchOp{Kind: BatchDeletePortProxyRule, PortProxyRule: rule})
+	return b
+}
+
+// AddIPAddress queues adding a static IPv4 address to an interface
+func (b *Batch) AddIPAddress(iface string, address *net.IPNet) *Batch {
+	b.ops = append(b.ops, BatchOp{Kind: BatchAddIPAddress, Interface: iface, Address: address})
+	return b
+}
+
+// DeleteIPAddress queues removing a static IPv4 address from an interface
+func (b *Batch) DeleteIPAddress(iface string, ip net.IP) *Batch {
+	b.ops = append(b.ops, BatchOp{Kind: BatchDeleteIPAddress, Interface: iface, Address: &net.IPNet{IP: ip}})
+	return b
+}
+
+// SetForwarding queues enabling or disabling forwarding on an interface
+func (b *Batch) SetForwarding(iface string, enabled bool) *Batch {
+	b.ops = append(b.ops, BatchOp{Kind: BatchSetForwarding, Interface: iface, Forwarding: enabled})
+	return b
+}
+
+// Ops returns the queued operations in order
+func (b *Batch) Ops() []BatchOp {
+	return append([]BatchOp(nil), b.ops...)
+}
+
+// Script renders the queued operations, one netsh command per line
+func (b *Batch) Script() (string, error) {
+	scope := b.runner
+	if scope == nil {
+		scope = &runner{}
+	}
+
+	var script strings.Builder
+	for _, op := range b.ops {
+		args, err := op.args(scope)
+		if err != nil {
+			return "", err
+		}
+		line, err := cmdline.NetshLine(args)
+		if err != nil {
+			return "", err
+		}
+		script.WriteString(line)
+		script.WriteString("\r\n")
+	}
+	return script.String(), nil
+}
+
+// Run runs the batch as one script, then reads the host state back and reports every op whose change is missing
+// with ErrNotApplied. Only the final state is checked, so an op undone by a later op of the same batch is reported
+// as not applied. The error is only set if the script could not run at all. A batch of a dry-run runner reports
+// every op as applied.
+func (b *Batch) Run() ([]BatchOpResult, error) {
+	if len(b.ops) == 0 {
+		return nil, nil
+	}
+
+	script, err := b.Script()
+	if err != nil {
+		return nil, err
+	}
+
+	if out, err := b.nsh.RunScript(script); err != nil {
+		// netsh -f carries on past failing commands, a non-zero exit status is sorted out by the verification
+		if _, ok := err.(utilexec.ExitError); !ok {
+			return nil, fmt.Errorf("failed to run netsh script: %v: %s", err, out)
+		}
+	}
+
+	if b.dryRun {
+		results := make([]BatchOpResult, len(b.ops))
+		for i, op := range b.ops {
+			results[i].Op = op
+		}
+		return results, nil
+	}
+
+	return b.verify(), nil
+}
+
+// verify checks each op against the host state, reading each piece of state at most once
+func (b *Batch) verify() []BatchOpResult {
+	var rules []PortProxyRule
+	var rulesErr error
+	rulesRead := false
+	addresses := map[string][]*net.IPNet{}
+	addressErrs := map[string]error{}
+	forwarding := map[string]bool{}
+	forwardingErrs := map[string]error{}
+
+	results := make([]BatchOpResult, len(b.ops))
+	for i, op := range b.ops {
+		results[i].Op = op
+
+		var applied bool
+		var err error
+		switch op.Kind {
+		case BatchAddPortProxyRule, BatchDeletePortProxyRule:
+			if !rulesRead {
+				rules, rulesErr = b.nsh.GetPortProxyRules()
+				rulesRead = true
+			}
+			err = rulesErr
+			present := hasPortProxyRule(rules, op.PortProxyRule, op.Kind == BatchAddPortProxyRule)
+			applied = present == (op.Kind == BatchAddPortProxyRule)
+		case BatchAddIPAddress, BatchDeleteIPAddress:
+			if _, ok := addresses[op.Interface]; !ok {
+				addresses[op.Interface], addressErrs[op.Interface] = b.nsh.GetIPAddresses(op.Interface)
+			}
+			err = addressErrs[op.Interface]
+			present := false
+			for _, address := range addresses[op.Interface] {
+				if address.IP.Equal(op.Address.IP) {
+					present = true
+				}
+			}
+			applied = present == (op.Kind == BatchAddIPAddress)
+		case BatchSetForwarding:
+			if _, ok := forwarding[op.Interface]; !ok {
+				forwarding[op.Interface], forwardingErrs[op.Interface] = b.nsh.GetForwarding(op.Interface)
+			}
+			err = forwardingErrs[op.Interface]
+			applied = forwarding[op.Interface] == op.Forwarding
+		}
+
+		if err != nil {
+			results[i].Err = fmt.Errorf("failed to verify %v: %v", op, err)
+		} else if !applied {
+			results[i].Err = ErrNotApplied
+		}
+	}
+
+	return results
+}
+
+// hasPortProxyRule looks for a rule listening where rule does, also matching where it connects to if exact is set
+func hasPortProxyRule(rules []PortProxyRule, rule PortProxyRule, exact bool) bool {
+	for _, r := range rules {
+		if r.Protocol != portProxyProtocol(rule) || r.ListenPort != rule.ListenPort || r.ListenAddress != portProxyListenAddress(rule) {
+			continue
+		}
+		if !exact || r.ConnectAddress == rule.ConnectAddress && r.ConnectPort == rule.ConnectPort {
+			return true
+		}
+	}
+	return false
+}
+
+func portProxyProtocol(rule PortProxyRule) string {
+	if rule.Protocol == "" {
+		return "v4tov4"
+	}
+	return rule.Protocol
+}
+
+// portProxyListenAddress is the address show all prints for a rule, * when the rule listens on every address
+func portProxyListenAddress(rule PortProxyRule) string {
+	if rule.ListenAddress == "" {
+		return "*"
+	}
+	return rule.ListenAddress
+}
+
+func (kind BatchOpKind) String() string {
+	switch kind {
+	case BatchAddPortProxyRule:
+		return "add portproxy rule"
+	case BatchDeletePortProxyRule:
+		return "delete portproxy rule"
+	case BatchAddIPAddress:
+		return "add address"
+	case BatchDeleteIPAddress:
+		return "delete address"
+	case BatchSetForwarding:
+		return "set forwarding"
+	}
+	return fmt.Sprintf("BatchOpKind(%d)", int(kind))
+}
+
+func (op BatchOp) String() string {
+	switch op.Kind {
+	case BatchAddPortProxyRule, BatchDeletePortProxyRule:
+		return fmt.Sprintf("%v %v %v:%v", op.Kind, portProxyProtocol(op.PortProxyRule), portProxyListenAddress(op.PortProxyRule), op.PortProxyRule.ListenPort)
+	case BatchAddIPAddress, BatchDeleteIPAddress:
+		return fmt.Sprintf("%v %v on [%v]", op.Kind, op.Address, op.Interface)
+	}
+	return fmt.Sprintf("%v %v on [%v]", op.Kind, op.Forwarding, op.Interface)
+}
+
+// args renders op as a netsh command, scoping interface commands to the compartment of runner
+func (op BatchOp) args(runner *runner) ([]string, error) {
+	switch op.Kind {
+	case BatchAddPortProxyRule, BatchDeletePortProxyRule:
+		rule := op.PortProxyRule
+		if rule.ListenPort <= 0 || rule.ListenPort > 65535 {
+			return nil, fmt.Errorf("invalid listen port %v in %v", rule.ListenPort, op)
+		}
+		switch portProxyProtocol(rule) {
+		case "v4tov4", "v4tov6", "v6tov4", "v6tov6":
+		default:
+			return nil, fmt.Errorf("invalid portproxy protocol %q in %v", rule.Protocol, op)
+		}
+		action := "add"
+		if op.Kind == BatchDeletePortProxyRule {
+			action = "delete"
+		}
+		args := []string{"interface", "portproxy", action, portProxyProtocol(rule), "listenport=" + strconv.Itoa(rule.ListenPort)}
+		if rule.ListenAddress != "" {
+			if err := cmdline.Address(rule.ListenAddress); err != nil {
+				return nil, fmt.Errorf("invalid listen address in %v: %v", op, err)
+			}
+			args = append(args, "listenaddress="+rule.ListenAddress)
+		}
+		if op.Kind == BatchDeletePortProxyRule {
+			return args, nil
+		}
+		if rule.ConnectPort <= 0 || rule.ConnectPort > 65535 || cmdline.Address(rule.ConnectAddress) != nil {
+			return nil, fmt.Errorf("invalid connect address %v:%v in %v", rule.ConnectAddress, rule.ConnectPort, op)
+		}
+		return append(args, "connectport="+strconv.Itoa(rule.ConnectPort), "connectaddress="+rule.ConnectAddress), nil
+	case BatchAddIPAddress:
+		return runner.addAddressArgs(op.Interface, op.Address)
+	case BatchDeleteIPAddress:
+		if op.Address == nil {
+			return nil, fmt.Errorf("no address given to delete from [%v]", op.Interface)
+		}
+		return runner.deleteAddressArgs(op.Interface, op.Address.IP)
+	case BatchSetForwarding:
+		if err := checkInterface(op.Interface); err != nil {
+			return nil, err
+		}
+		state := "disabled"
+		if op.Forwarding {
+			state = "enabled"
+		}
+		return runner.ipv4Args("interface", "ipv4", "set", "interface", op.Interface, "forwarding="+state), nil
+	}
+	return nil, fmt.Errorf("unknown batch operation %v", op.Kind)
+}
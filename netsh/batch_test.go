@@ -0,0 +1,194 @@
+package netsh
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/stretchr/testify/assert"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestBatchRun(t *testing.T) {
+	var script string
+	var fakeCmd fakeexec.FakeCmd
+	fakeCmd = fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				contents, err := ioutil.ReadFile(fakeCmd.Argv[2])
+				assert.NoError(t, err)
+				script = string(contents)
+				return []byte("The object already exists."), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+			func() ([]byte, []byte, error) {
+				return []byte(`
+Listen on ipv4:             Connect to ipv4:
+
+Address         Port        Address         Port
+--------------- ----------  --------------- ----------
+*               8080        10.0.0.5        80
+10.0.0.4        9090        10.0.0.9        90
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) {
+				return []byte(`
+Configuration for interface "Ethernet"
+    IP Address:                           10.0.0.4
+    Subnet Prefix:                        10.0.0.0/24 (mask 255.255.255.0)
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("Forwarding                         : enabled\r\n"), nil, nil
+			},
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := &runner{
+		exec: &fakeExec,
+	}
+
+	ip, address, _ := net.ParseCIDR("10.0.0.5/24")
+	address.IP = ip
+	results, err := runner.NewBatch().
+		AddPortProxyRule(PortProxyRule{ListenPort: 8080, ConnectAddress: "10.0.0.5", ConnectPort: 80}).
+		DeletePortProxyRule(PortProxyRule{ListenAddress: "10.0.0.4", ListenPort: 9090}).
+		AddIPAddress("Ethernet", address).
+		DeleteIPAddress("Ethernet", net.ParseIP("10.0.0.6")).
+		SetForwarding("Ethernet", true).
+		Run()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "netsh", fakeCmd.CombinedOutputLog[0][0])
+	assert.Equal(t, "-f", fakeCmd.CombinedOutputLog[0][1])
+	assert.Equal(t, "interface portproxy add v4tov4 listenport=8080 connectport=80 connectaddress=10.0.0.5\r\n"+
+		"interface portproxy delete v4tov4 listenport=9090 listenaddress=10.0.0.4\r\n"+
+		"interface ipv4 add address name=Ethernet address=10.0.0.5 mask=255.255.255.0\r\n"+
+		"interface ipv4 delete address name=Ethernet address=10.0.0.6\r\n"+
+		"interface ipv4 set interface Ethernet forwarding=enabled\r\n", script)
+
+	// the state is read once per kind and interface
+	assert.Equal(t, 4, fakeCmd.CombinedOutputCalls)
+	assert.Equal(t, 5, len(results))
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, ErrNotApplied, results[1].Err)
+	assert.Equal(t, ErrNotApplied, results[2].Err)
+	assert.NoError(t, results[3].Err)
+	assert.NoError(t, results[4].Err)
+	assert.Equal(t, BatchDeletePortProxyRule, results[1].Op.Kind)
+}
+
+func TestBatchRunFailures(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, errors.New("netsh not found") },
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return []byte("Element not found."), nil, &fakeexec.FakeExitError{Status: 1} },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := &runner{
+		exec: &fakeExec,
+	}
+
+	results, err := runner.NewBatch().SetForwarding("Ethernet", false).Run()
+	assert.Error(t, err)
+	assert.Nil(t, results)
+
+	results, err = runner.NewBatch().SetForwarding("missing", false).Run()
+	assert.NoError(t, err)
+	assert.Error(t, results[0].Err)
+	assert.NotEqual(t, ErrNotApplied, results[0].Err)
+
+	_, err = runner.NewBatch().AddPortProxyRule(PortProxyRule{ListenPort: 80}).Run()
+	assert.Error(t, err)
+	_, err = runner.NewBatch().AddIPAddress("Ethernet", nil).Run()
+	assert.Error(t, err)
+	_, err = runner.NewBatch().AddPortProxyRule(PortProxyRule{Protocol: "v4tv4", ListenPort: 80, ConnectAddress: "10.0.0.5", ConnectPort: 80}).Run()
+	assert.Error(t, err)
+	_, err = runner.NewBatch().DeletePortProxyRule(PortProxyRule{Protocol: "tcp", ListenPort: 80}).Run()
+	assert.Error(t, err)
+	assert.Equal(t, 3, fakeCmd.CombinedOutputCalls)
+
+	results, err = runner.NewBatch().Run()
+	assert.NoError(t, err)
+	assert.Nil(t, results)
+}
+
+func TestBatchDryRun(t *testing.T) {
+	fakeExec := fakeexec.FakeExec{}
+	recorder := dryrun.NewRecorder()
+	runner := &runner{
+		exec:   &fakeExec,
+		dryRun: recorder,
+	}
+
+	results, err := runner.NewBatch().
+		AddPortProxyRule(PortProxyRule{ListenPort: 8080, ConnectAddress: "10.0.0.5", ConnectPort: 80}).
+		SetForwarding("Ethernet", true).
+		Run()
+
+	// neither the script nor the verification ran
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fakeExec.CommandCalls)
+	assert.Equal(t, 2, len(results))
+	assert.NoError(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, []dryrun.Entry{{
+		Package:   "netsh",
+		Operation: "RunScript",
+		Command: "interface portproxy add v4tov4 listenport=8080 connectport=80 connectaddress=10.0.0.5\r\n" +
+			"interface ipv4 set interface Ethernet forwarding=enabled\r\n",
+	}}, recorder.Entries())
+}
+
+// wrappedNetsh stands for a decorator such as the metrics or tracing ones, it embeds the Interface it wraps
+type wrappedNetsh struct {
+	Interface
+}
+
+func TestBatchDryRunOfWrappedInterface(t *testing.T) {
+	fakeExec := fakeexec.FakeExec{}
+	recorder := dryrun.NewRecorder()
+	nsh := wrappedNetsh{&runner{
+		exec:   &fakeExec,
+		dryRun: recorder,
+	}}
+
+	results, err := NewBatch(nsh).SetForwarding("Ethernet", true).Run()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fakeExec.CommandCalls)
+	assert.Equal(t, 1, len(results))
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, 1, len(recorder.Entries()))
+}
+
+func TestBatchInCompartment(t *testing.T) {
+	host := &runner{}
+	ip, address, _ := net.ParseCIDR("10.0.0.5/24")
+	address.IP = ip
+
+	script, err := host.InCompartment(3).NewBatch().
+		AddPortProxyRule(PortProxyRule{ListenPort: 8080, ConnectAddress: "10.0.0.5", ConnectPort: 80}).
+		AddIPAddress("vEthernet (Ethernet)", address).
+		DeleteIPAddress("vEthernet (Ethernet)", net.ParseIP("10.0.0.6")).
+		SetForwarding("vEthernet (Ethernet)", true).
+		Script()
+
+	// portproxy is not scoped to compartments
+	assert.NoError(t, err)
+	assert.Equal(t, "interface portproxy add v4tov4 listenport=8080 connectport=80 connectaddress=10.0.0.5\r\n"+
+		"interface ipv4 add address name=\"vEthernet (Ethernet)\" address=10.0.0.5 mask=255.255.255.0 compartment=3\r\n"+
+		"interface ipv4 delete address name=\"vEthernet (Ethernet)\" address=10.0.0.6 compartment=3\r\n"+
+		"interface ipv4 set interface \"vEthernet (Ethernet)\" forwarding=enabled compartment=3\r\n", script)
+
+	script, err = NewBatch(host.InCompartment(3)).SetForwarding("Ethernet", true).Script()
+	assert.NoError(t, err)
+	assert.Equal(t, "interface ipv4 set interface Ethernet forwarding=enabled compartment=3\r\n", script)
+}
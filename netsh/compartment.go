@@ -0,0 +1,79 @@
+package netsh
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Compartment models a network compartment from: netsh interface ipv4 show compartments
+type Compartment struct {
+	ID          int
+	Description string
+}
+
+// DefaultCompartmentID is the host compartment, containers get compartments of their own
+const DefaultCompartmentID int = 1
+
+// GetCompartments lists the network compartments on the host
+func (runner *runner) GetCompartments() ([]Compartment, error) {
+	args := []string{
+		"interface", "ipv4", "show", "compartments",
+	}
+
+	output, err := runner.queryNetsh(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list compartments, error: %v. stdout: %v", err, string(output))
+	}
+
+	return parseCompartments(string(output))
+}
+
+// InCompartment returns an Interface whose interface commands target the given compartment
+func (runner *runner) InCompartment(id int) Interface {
+	scoped := *runner
+	scoped.compartmentID = id
+	return &scoped
+}
+
+// ipv4Args appends the compartment to an interface ipv4 command if the runner is scoped to one
+func (runner *runner) ipv4Args(args ...string) []string {
+	if runner.compartmentID == 0 {
+		return args
+	}
+	return append(args, "compartment="+strconv.Itoa(runner.compartmentID))
+}
+
+func parseCompartments(output string) ([]Compartment, error) {
+	output = strings.TrimSpace(output)
+	reg := regexp.MustCompile(`\s{2,}`)
+
+	var compartments []Compartment
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "---") {
+			continue
+		}
+
+		// Split the line by two or more whitespace characters, the header line has no numeric id
+		splitLine := reg.Split(line, 2)
+		id, err := strconv.Atoi(splitLine[0])
+		if err != nil {
+			continue
+		}
+
+		compartment := Compartment{ID: id}
+		if len(splitLine) > 1 {
+			compartment.Description = splitLine[1]
+		}
+		compartments = append(compartments, compartment)
+	}
+
+	if len(compartments) == 0 {
+		return nil, errors.New("no compartments found in netsh output:\n" + output)
+	}
+
+	return compartments, nil
+}
@@ -0,0 +1,67 @@
+package netsh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestGetCompartments(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte(`
+
+Compartment Id  Description
+--------------  ---------------------------------------------
+             1  Default Compartment
+             3  Compartment for container 1b5c0ec9e1b0
+
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) { return []byte("junk"), nil, nil },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	compartments, err := runner.GetCompartments()
+	assert.NoError(t, err)
+	assert.EqualValues(t, strings.Split("netsh interface ipv4 show compartments", " "), fakeCmd.CombinedOutputLog[0])
+	assert.EqualValues(t, []Compartment{
+		{ID: 1, Description: "Default Compartment"},
+		{ID: 3, Description: "Compartment for container 1b5c0ec9e1b0"},
+	}, compartments)
+
+	compartments, err = runner.GetCompartments()
+	assert.Error(t, err)
+	assert.Nil(t, compartments)
+}
+
+func TestInCompartment(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	host := &runner{
+		exec: &fakeExec,
+	}
+	container := host.InCompartment(3)
+
+	assert.NoError(t, container.EnableForwarding("vEthernet (Ethernet)"))
+	assert.NoError(t, host.EnableForwarding("vEthernet (Ethernet)"))
+
+	assert.EqualValues(t, []string{"netsh", "int", "ipv4", "set", "int", "vEthernet (Ethernet)", "for=en", "compartment=3"}, fakeCmd.CombinedOutputLog[0])
+	assert.EqualValues(t, []string{"netsh", "int", "ipv4", "set", "int", "vEthernet (Ethernet)", "for=en"}, fakeCmd.CombinedOutputLog[1])
+}
@@ -0,0 +1,221 @@
+package netsh
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// Locking selects how a ConcurrencyController serializes mutations
+type Locking int
+
+const (
+	// LockNone lets mutations run concurrently
+	LockNone Locking = iota
+	// LockGlobal runs one mutation at a time
+	LockGlobal
+	// LockPerResource runs one mutation at a time per interface, portproxy listen port or firewall rule. Scripts
+	// and mutations whose resource is unknown still wait for every other mutation. Interfaces are locked by alias,
+	// a mutation of an interface given by its index waits for every other mutation since its alias is not known.
+	LockPerResource
+)
+
+// Queues reported to ConcurrencyOptions.WaitHook
+const (
+	// QueueLock is the wait of a mutation for its lock
+	QueueLock = "lock"
+	// QueueSpawn is the wait of a netsh or powershell process for the spawn rate limit
+	QueueSpawn = "spawn"
+)
+
+// ConcurrencyOptions configures a ConcurrencyController
+type ConcurrencyOptions struct {
+	// Locking serializes mutations, reads always run concurrently
+	Locking Locking
+	// SpawnRate limits the netsh and powershell processes started per second, zero does not limit them. Commands
+	// of an interactive session do not start a process.
+	SpawnRate float64
+	// SpawnBurst is the number of processes that may start at once before SpawnRate applies, defaults to 1
+	SpawnBurst int
+	// WaitHook, if set, is called with the queue and the time spent waiting in it, e.g. to feed a histogram
+	WaitHook func(queue string, wait time.Duration)
+	// Clock times the waits and refills the spawn tokens, defaults to the real clock
+	Clock clock.Clock
+}
+
+// ConcurrencyController serializes the mutations and rate limits the processes of the netsh runners it is passed
+// to. Share one between every runner of a process so controllers running side by side do not race.
+type ConcurrencyController struct {
+	locking  Locking
+	rate     float64
+	burst    float64
+	waitHook func(queue string, wait time.Duration)
+	clock    clock.Clock
+
+	// global is held for reading by per resource mutations and for writing by the others
+	global sync.RWMutex
+
+	mu sync.Mutex
+	// resources are the locks of the resources in use, with the number of mutations holding or waiting for them
+	resources map[string]*resourceLock
+	// tokens left in the spawn bucket at last, negative when spawns are queued
+	tokens float64
+	last   time.Time
+}
+
+type resourceLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewConcurrencyController returns a ConcurrencyController configured by opts
+func NewConcurrencyController(opts ConcurrencyOptions) *ConcurrencyController {
+	clk := opts.Clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	burst := opts.SpawnBurst
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &ConcurrencyController{
+		locking:   opts.Locking,
+		rate:      opts.SpawnRate,
+		burst:     float64(burst),
+		waitHook:  opts.WaitHook,
+		clock:     clk,
+		resources: make(map[string]*resourceLock),
+		tokens:    float64(burst),
+		last:      clk.Now(),
+	}
+}
+
+// lock waits for the lock of resource and returns the function releasing it. An empty resource waits for every
+// other mutation. A nil controller does not lock.
+func (c *ConcurrencyController) lock(resource string) func() {
+	if c == nil || c.locking == LockNone {
+		return func() {}
+	}
+
+	start := c.clock.Now()
+	var unlock func()
+	if c.locking == LockGlobal || resource == "" {
+		c.global.Lock()
+		unlock = c.global.Unlock
+	} else {
+		c.global.RLock()
+		l := c.acquire(resource)
+		l.Lock()
+		unlock = func() {
+			l.Unlock()
+			c.release(resource)
+			c.global.RUnlock()
+		}
+	}
+	c.waited(QueueLock, c.clock.Since(start))
+
+	return unlock
+}
+
+func (c *ConcurrencyController) acquire(resource string) *resourceLock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.resources[resource]
+	if !ok {
+		l = &resourceLock{}
+		c.resources[resource] = l
+	}
+	l.refs++
+	return l
+}
+
+func (c *ConcurrencyController) release(resource string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := c.resources[resource]
+	l.refs--
+	if l.refs == 0 {
+		delete(c.resources, resource)
+	}
+}
+
+// spawn waits until a process may be started. A nil controller does not wait.
+func (c *ConcurrencyController) spawn() {
+	if c == nil || c.rate <= 0 {
+		return
+	}
+
+	wait := c.reserve()
+	if wait > 0 {
+		c.clock.Sleep(wait)
+	}
+	c.waited(QueueSpawn, wait)
+}
+
+// reserve takes a token from the bucket and returns how long to wait until it is actually available
+func (c *ConcurrencyController) reserve() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	c.tokens += now.Sub(c.last).Seconds() * c.rate
+	if c.tokens > c.burst {
+		c.tokens = c.burst
+	}
+	c.last = now
+
+	c.tokens--
+	if c.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-c.tokens / c.rate * float64(time.Second))
+}
+
+func (c *ConcurrencyController) waited(queue string, wait time.Duration) {
+	if c.waitHook != nil {
+		c.waitHook(queue, wait)
+	}
+}
+
+// lock waits for the lock of resource in the runner's controller, if any
+func (runner *runner) lock(resource string) func() {
+	return runner.concurrency.lock(resource)
+}
+
+// interfaceResource is the lock of the mutations of an interface. An index may name the same interface as an
+// alias, so it maps to the global lock rather than to a lock of its own.
+func interfaceResource(iface string) string {
+	if _, err := strconv.Atoi(iface); err == nil {
+		return ""
+	}
+	return "interface/" + strings.ToLower(iface)
+}
+
+// firewallResource is the lock of the mutations of the firewall rules called name
+func firewallResource(name string) string {
+	return "firewall/" + strings.ToLower(name)
+}
+
+// argsResource finds the lock of a raw netsh command line from its listenport= or name= argument, it is empty
+// when there is neither
+func argsResource(args []string) string {
+	for _, arg := range args {
+		lower := strings.ToLower(arg)
+		if strings.HasPrefix(lower, "listenport=") {
+			return "portproxy/" + strings.TrimPrefix(lower, "listenport=")
+		}
+	}
+	for _, arg := range args {
+		lower := strings.ToLower(arg)
+		if strings.HasPrefix(lower, "name=") {
+			return interfaceResource(strings.Trim(arg[len("name="):], `"`))
+		}
+	}
+	return ""
+}
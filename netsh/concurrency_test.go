@@ -0,0 +1,170 @@
+package netsh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	fakeclock "k8s.io/utils/clock/testing"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+// lockAsync takes the lock of resource in the background, the returned channel yields its unlock function
+func lockAsync(c *ConcurrencyController, resource string) <-chan func() {
+	locked := make(chan func(), 1)
+	go func() {
+		locked <- c.lock(resource)
+	}()
+	return locked
+}
+
+func assertBlocked(t *testing.T, locked <-chan func()) {
+	select {
+	case <-locked:
+		assert.Fail(t, "lock was not blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func assertLocked(t *testing.T, locked <-chan func()) func() {
+	select {
+	case unlock := <-locked:
+		return unlock
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "lock was not acquired")
+		return func() {}
+	}
+}
+
+func TestLockPerResource(t *testing.T) {
+	c := NewConcurrencyController(ConcurrencyOptions{Locking: LockPerResource})
+
+	unlockEthernet := c.lock(interfaceResource("Ethernet"))
+	// another resource is independent
+	assertLocked(t, lockAsync(c, "portproxy/8080"))()
+
+	// the same resource, whatever its case, waits
+	same := lockAsync(c, interfaceResource("ETHERNET"))
+	assertBlocked(t, same)
+	unlockEthernet()
+	unlockSame := assertLocked(t, same)
+
+	// a script waits for every resource
+	script := lockAsync(c, "")
+	assertBlocked(t, script)
+	unlockSame()
+	unlockScript := assertLocked(t, script)
+
+	// and blocks every resource while it runs
+	other := lockAsync(c, "portproxy/8080")
+	assertBlocked(t, other)
+	unlockScript()
+	assertLocked(t, other)()
+
+	assert.Empty(t, c.resources)
+}
+
+func TestLockGlobal(t *testing.T) {
+	c := NewConcurrencyController(ConcurrencyOptions{Locking: LockGlobal})
+
+	unlock := c.lock(interfaceResource("Ethernet"))
+	other := lockAsync(c, "portproxy/8080")
+	assertBlocked(t, other)
+	unlock()
+	assertLocked(t, other)()
+}
+
+func TestLockNone(t *testing.T) {
+	var nilController *ConcurrencyController
+	nilController.lock("")()
+	nilController.spawn()
+
+	c := NewConcurrencyController(ConcurrencyOptions{})
+	unlock := c.lock("")
+	assertLocked(t, lockAsync(c, ""))()
+	unlock()
+}
+
+func TestSpawnRate(t *testing.T) {
+	start := time.Now()
+	clock := fakeclock.NewFakeClock(start)
+	var waits []time.Duration
+	c := NewConcurrencyController(ConcurrencyOptions{
+		SpawnRate:  2,
+		SpawnBurst: 2,
+		Clock:      clock,
+		WaitHook: func(queue string, wait time.Duration) {
+			assert.Equal(t, QueueSpawn, queue)
+			waits = append(waits, wait)
+		},
+	})
+
+	for i := 0; i < 4; i++ {
+		c.spawn()
+	}
+	assert.Equal(t, []time.Duration{0, 0, 500 * time.Millisecond, 500 * time.Millisecond}, waits)
+	assert.Equal(t, time.Second, clock.Since(start))
+
+	// the bucket refills while idle, up to the burst
+	clock.Step(10 * time.Second)
+	c.spawn()
+	c.spawn()
+	c.spawn()
+	assert.Equal(t, []time.Duration{0, 0, 500 * time.Millisecond}, waits[4:])
+}
+
+func TestConcurrencyController(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+		},
+	}
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+	queues := make(map[string]int)
+	c := NewConcurrencyController(ConcurrencyOptions{
+		Locking:   LockPerResource,
+		SpawnRate: 10,
+		WaitHook: func(queue string, wait time.Duration) {
+			queues[queue]++
+		},
+	})
+	nsh, err := NewWithOptions(Options{Exec: &fakeExec, Concurrency: c})
+	assert.NoError(t, err)
+
+	unlock := c.lock(interfaceResource("Ethernet"))
+	done := make(chan error)
+	go func() {
+		done <- nsh.InCompartment(2).EnableForwarding("Ethernet")
+	}()
+	select {
+	case <-done:
+		assert.Fail(t, "EnableForwarding did not wait for the interface")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	assert.NoError(t, <-done)
+
+	assert.Equal(t, map[string]int{QueueLock: 2, QueueSpawn: 1}, queues)
+}
+
+func TestArgsResource(t *testing.T) {
+	assert.Equal(t, "portproxy/8080", argsResource([]string{"interface", "portproxy", "add", "v4tov4", "listenPort=8080", "connectaddress=10.0.0.5"}))
+	assert.Equal(t, "interface/ethernet", argsResource([]string{"interface", "ipv4", "delete", "address", "name=\"Ethernet\"", "addr=10.0.0.5"}))
+	assert.Equal(t, "", argsResource([]string{"interface", "ipv4", "delete", "address", "Ethernet", "10.0.0.5"}))
+}
+
+func TestLockInterfaceByIndex(t *testing.T) {
+	c := NewConcurrencyController(ConcurrencyOptions{Locking: LockPerResource})
+
+	// the index may be the same interface as the alias, so it waits for it
+	unlockAlias := c.lock(interfaceResource("Ethernet"))
+	index := lockAsync(c, interfaceResource("4"))
+	assertBlocked(t, index)
+	unlockAlias()
+	unlockIndex := assertLocked(t, index)
+
+	alias := lockAsync(c, interfaceResource("Ethernet"))
+	assertBlocked(t, alias)
+	unlockIndex()
+	assertLocked(t, alias)()
+}
@@ -0,0 +1,276 @@
+package netsh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rakelkar/gonetsh/cmdline"
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/rakelkar/gonetsh/observer"
+)
+
+const (
+	// cmdPowershell runs the DnsClient cmdlets that have no netsh equivalent
+	cmdPowershell string = "powershell.exe"
+)
+
+// DNSClientConfig models the DNS client settings of an interface
+type DNSClientConfig struct {
+	Interface string
+	// Dhcp is true if the servers were handed out by DHCP rather than statically configured
+	Dhcp bool
+	// Servers in the order they are queried
+	Servers []net.IP
+	// RegisterWith is the suffix used for dynamic DNS registration, e.g. "Primary only" or "None"
+	RegisterWith     string
+	ConnectionSuffix string
+}
+
+// GetDNSClientConfig returns the DNS servers, their source and the suffix settings of an interface
+func (runner *runner) GetDNSClientConfig(iface string) (DNSClientConfig, error) {
+	if err := checkInterface(iface); err != nil {
+		return DNSClientConfig{}, err
+	}
+
+	args := runner.ipv4Args(
+		"interface", "ipv4", "show", "dnsservers", iface,
+	)
+	cmd := strings.Join(args, " ")
+	output, err := runner.queryNetsh(args...)
+	if err != nil {
+		return DNSClientConfig{}, fmt.Errorf("failed to get dns servers of [%v], error: %v. cmd: %v. stdout: %v", iface, err.Error(), cmd, string(output))
+	}
+
+	config, err := parseDNSServers(iface, string(output))
+	if err != nil {
+		return DNSClientConfig{}, err
+	}
+
+	script := fmt.Sprintf("(Get-DnsClient -InterfaceAlias %v -ErrorAction Stop).ConnectionSpecificSuffix", cmdline.PowerShellQuote(iface))
+	suffix, err := runner.queryPowershell(script)
+	if err != nil {
+		return DNSClientConfig{}, fmt.Errorf("failed to get dns suffix of [%v], error: %v", iface, err)
+	}
+	config.ConnectionSuffix = strings.TrimSpace(suffix)
+
+	return config, nil
+}
+
+// SetDNSServers replaces the DNS servers of an interface, the first server becomes the primary
+func (runner *runner) SetDNSServers(iface string, servers []net.IP) error {
+	if err := checkInterface(iface); err != nil {
+		return err
+	}
+	defer runner.lock(interfaceResource(iface))()
+
+	if len(servers) == 0 {
+		return fmt.Errorf("no dns servers given for [%v], use ResetDNSToDHCP to clear them", iface)
+	}
+	seen := map[string]bool{}
+	for _, server := range servers {
+		if server == nil || server.To4() == nil {
+			return fmt.Errorf("invalid ipv4 dns server %v for [%v]", server, iface)
+		}
+		if seen[server.String()] {
+			return fmt.Errorf("duplicate dns server %v for [%v]", server, iface)
+		}
+		seen[server.String()] = true
+	}
+
+	args := runner.ipv4Args(
+		"int", "ipv4", "set", "dns", iface, "static", servers[0].String(), "primary",
+	)
+	params := []string{iface}
+	for _, server := range servers {
+		params = append(params, server.String())
+	}
+	if err := runner.runDNSCommand("SetDNSServers", params, iface, args); err != nil {
+		return err
+	}
+
+	for i, server := range servers[1:] {
+		args := runner.ipv4Args(
+			"int", "ipv4", "add", "dns", iface, server.String(), "index="+strconv.Itoa(i+2),
+		)
+		if err := runner.runDNSCommand("SetDNSServers", params, iface, args); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResetDNSToDHCP drops the statically configured DNS servers of an interface in favor of the DHCP provided ones
+func (runner *runner) ResetDNSToDHCP(iface string) error {
+	if err := checkInterface(iface); err != nil {
+		return err
+	}
+	defer runner.lock(interfaceResource(iface))()
+
+	args := runner.ipv4Args(
+		"int", "ipv4", "set", "dns", iface, "dhcp",
+	)
+	return runner.runDNSCommand("ResetDNSToDHCP", []string{iface}, iface, args)
+}
+
+// SetDNSSuffix sets the connection-specific DNS suffix of an interface, an empty suffix clears it
+func (runner *runner) SetDNSSuffix(iface string, suffix string) error {
+	if err := checkInterface(iface); err != nil {
+		return err
+	}
+	defer runner.lock(interfaceResource(iface))()
+
+	if suffix != "" && cmdline.Host(suffix) != nil {
+		return fmt.Errorf("invalid dns suffix %q for [%v]", suffix, iface)
+	}
+
+	script := fmt.Sprintf("Set-DnsClient -InterfaceAlias %v -ConnectionSpecificSuffix %v -ErrorAction Stop", cmdline.PowerShellQuote(iface), cmdline.PowerShellQuote(suffix))
+	if _, err := runner.mutatePowershell("SetDNSSuffix", []string{iface, suffix}, script); err != nil {
+		return fmt.Errorf("failed to set dns suffix on [%v], error: %v", iface, err)
+	}
+
+	return nil
+}
+
+// SetSuffixSearchList replaces the global DNS suffix search list, an empty list clears it
+func (runner *runner) SetSuffixSearchList(suffixes []string) error {
+	defer runner.lock("dns/searchlist")()
+
+	quoted := make([]string, 0, len(suffixes))
+	for _, suffix := range suffixes {
+		if cmdline.Host(suffix) != nil {
+			return fmt.Errorf("invalid dns suffix %q in search list", suffix)
+		}
+		quoted = append(quoted, cmdline.PowerShellQuote(suffix))
+	}
+
+	script := fmt.Sprintf("Set-DnsClientGlobalSetting -SuffixSearchList @(%v) -ErrorAction Stop", strings.Join(quoted, ","))
+	if _, err := runner.mutatePowershell("SetSuffixSearchList", suffixes, script); err != nil {
+		return fmt.Errorf("failed to set dns suffix search list, error: %v", err)
+	}
+
+	return nil
+}
+
+// SetDNSRegistration controls whether an interface registers its address in DNS, and whether it uses its connection-specific suffix to do so
+func (runner *runner) SetDNSRegistration(iface string, register bool, useSuffix bool) error {
+	if err := checkInterface(iface); err != nil {
+		return err
+	}
+	defer runner.lock(interfaceResource(iface))()
+
+	script := fmt.Sprintf("Set-DnsClient -InterfaceAlias %v -RegisterThisConnectionsAddress %v -UseSuffixWhenRegistering %v -ErrorAction Stop",
+		cmdline.PowerShellQuote(iface), powershellBool(register), powershellBool(useSuffix))
+	if _, err := runner.mutatePowershell("SetDNSRegistration", []string{iface, strconv.FormatBool(register), strconv.FormatBool(useSuffix)}, script); err != nil {
+		return fmt.Errorf("failed to set dns registration on [%v], error: %v", iface, err)
+	}
+
+	return nil
+}
+
+func (runner *runner) runDNSCommand(operation string, params []string, iface string, args []string) error {
+	cmd := strings.Join(args, " ")
+	if stdout, err := runner.mutateNetsh(operation, params, args...); err != nil {
+		return fmt.Errorf("failed to set dns on [%v], error: %v. cmd: %v. stdout: %v", iface, err.Error(), cmd, string(stdout))
+	}
+
+	return nil
+}
+
+// runPowershell runs a one-off powershell script and returns its output, on the remote host if there is one
+func (runner *runner) runPowershell(script string) (string, error) {
+	if runner.remoteHost != "" {
+		script = fmt.Sprintf("Invoke-Command -ComputerName %v -ErrorAction Stop -ScriptBlock { %v }", cmdline.PowerShellQuote(runner.remoteHost), script)
+	}
+	args := []string{"-NoProfile", "-NonInteractive", "-Command", script}
+	runner.concurrency.spawn()
+	command := observer.Start(runner.observer, observer.Event{Package: "netsh", Program: cmdPowershell, Args: args})
+	output, err := runner.exec.Command(cmdPowershell, args...).CombinedOutput()
+	command.Done(output, err)
+	if err != nil {
+		return "", fmt.Errorf("%v: %v", err, string(output))
+	}
+
+	return string(output), nil
+}
+
+// queryPowershell runs a powershell script that only reads, rerunning it according to the retry policy
+func (runner *runner) queryPowershell(script string) (output string, err error) {
+	err = runner.retry.Do(func() error {
+		output, err = runner.runPowershell(script)
+		return err
+	})
+	return output, err
+}
+
+// mutatePowershell runs a powershell script that changes the host, in dry-run mode it is recorded instead
+func (runner *runner) mutatePowershell(operation string, params []string, script string) (string, error) {
+	if runner.dryRun != nil {
+		runner.dryRun.Record(dryrun.Entry{
+			Package:   "netsh",
+			Operation: operation,
+			Args:      params,
+			Command:   script,
+		})
+		return "", nil
+	}
+	return runner.runPowershell(script)
+}
+
+func parseDNSServers(iface string, output string) (DNSClientConfig, error) {
+	config := DNSClientConfig{
+		Interface: iface,
+	}
+
+	found := false
+	inServers := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.Contains(line, "Configuration for interface") {
+			found = true
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		// continuation lines hold a single address, ipv6 addresses contain colons but never ": "
+		if len(parts) != 2 || !strings.Contains(line, ": ") {
+			if inServers {
+				if ip := net.ParseIP(line); ip != nil {
+					config.Servers = append(config.Servers, ip)
+				}
+			}
+			continue
+		}
+
+		inServers = false
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if strings.HasPrefix(key, "DNS servers configured through DHCP") || strings.HasPrefix(key, "Statically Configured DNS Servers") {
+			config.Dhcp = strings.HasPrefix(key, "DNS servers configured through DHCP")
+			inServers = true
+			if ip := net.ParseIP(value); ip != nil {
+				config.Servers = append(config.Servers, ip)
+			}
+		} else if strings.HasPrefix(key, "Register with which suffix") {
+			config.RegisterWith = value
+		}
+	}
+
+	if !found {
+		return DNSClientConfig{}, fmt.Errorf("no dns configuration found for [%v] in netsh output: %v", iface, output)
+	}
+
+	return config, nil
+}
+
+func powershellBool(b bool) string {
+	if b {
+		return "$true"
+	}
+	return "$false"
+}
@@ -0,0 +1,133 @@
+package netsh
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestGetDNSClientConfig(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte(`
+
+Configuration for interface "Ethernet"
+    Statically Configured DNS Servers:    10.96.0.10
+                                          8.8.8.8
+    Register with which suffix:           Primary only
+
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) { return []byte("svc.cluster.local\r\n"), nil, nil },
+			func() ([]byte, []byte, error) {
+				return []byte(`
+
+Configuration for interface "Wi-Fi"
+    DNS servers configured through DHCP:  192.168.1.1
+    Register with which suffix:           None
+
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) { return []byte("\r\n"), nil, nil },
+			func() ([]byte, []byte, error) { return []byte("The filename, directory name, or volume label syntax is incorrect."), nil, nil },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	config, err := runner.GetDNSClientConfig("Ethernet")
+	assert.NoError(t, err)
+	assert.EqualValues(t, DNSClientConfig{
+		Interface:        "Ethernet",
+		Dhcp:             false,
+		Servers:          []net.IP{net.ParseIP("10.96.0.10"), net.ParseIP("8.8.8.8")},
+		RegisterWith:     "Primary only",
+		ConnectionSuffix: "svc.cluster.local",
+	}, config)
+	assert.EqualValues(t, []string{"netsh", "interface", "ipv4", "show", "dnsservers", "Ethernet"}, fakeCmd.CombinedOutputLog[0])
+	assert.EqualValues(t, []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "(Get-DnsClient -InterfaceAlias 'Ethernet' -ErrorAction Stop).ConnectionSpecificSuffix"}, fakeCmd.CombinedOutputLog[1])
+
+	config, err = runner.GetDNSClientConfig("Wi-Fi")
+	assert.NoError(t, err)
+	assert.True(t, config.Dhcp)
+	assert.Equal(t, []net.IP{net.ParseIP("192.168.1.1")}, config.Servers)
+	assert.Equal(t, "None", config.RegisterWith)
+	assert.Equal(t, "", config.ConnectionSuffix)
+
+	_, err = runner.GetDNSClientConfig("Missing")
+	assert.Error(t, err)
+}
+
+func TestSetDNSServers(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	err := runner.SetDNSServers("Ethernet", []net.IP{net.ParseIP("10.96.0.10"), net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")})
+	assert.NoError(t, err)
+	assert.EqualValues(t, [][]string{
+		{"netsh", "int", "ipv4", "set", "dns", "Ethernet", "static", "10.96.0.10", "primary"},
+		{"netsh", "int", "ipv4", "add", "dns", "Ethernet", "8.8.8.8", "index=2"},
+		{"netsh", "int", "ipv4", "add", "dns", "Ethernet", "8.8.4.4", "index=3"},
+	}, fakeCmd.CombinedOutputLog)
+}
+
+func TestSetDNSServersValidates(t *testing.T) {
+	runner := runner{
+		exec: &fakeexec.FakeExec{},
+	}
+
+	assert.Error(t, runner.SetDNSServers("Ethernet", nil))
+	assert.Error(t, runner.SetDNSServers("Ethernet", []net.IP{nil}))
+	assert.Error(t, runner.SetDNSServers("Ethernet", []net.IP{net.ParseIP("fd00::10")}))
+	assert.Error(t, runner.SetDNSServers("Ethernet", []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.8.8")}))
+	assert.Error(t, runner.SetDNSSuffix("Ethernet", "bad suffix'; Remove-Item C:\\"))
+	assert.Error(t, runner.SetSuffixSearchList([]string{"good.local", "-bad"}))
+}
+
+func TestDNSSuffixAndRegistration(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return []byte("access denied"), nil, &fakeexec.FakeExitError{Status: 1} },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	assert.NoError(t, runner.ResetDNSToDHCP("Ethernet"))
+	assert.NoError(t, runner.SetDNSSuffix("Bob's NIC", "svc.cluster.local"))
+	assert.NoError(t, runner.SetSuffixSearchList([]string{"svc.cluster.local", "cluster.local"}))
+	assert.NoError(t, runner.SetDNSRegistration("Ethernet", true, false))
+	assert.Error(t, runner.SetSuffixSearchList(nil))
+
+	assert.EqualValues(t, []string{"netsh", "int", "ipv4", "set", "dns", "Ethernet", "dhcp"}, fakeCmd.CombinedOutputLog[0])
+	assert.Equal(t, "Set-DnsClient -InterfaceAlias 'Bob''s NIC' -ConnectionSpecificSuffix 'svc.cluster.local' -ErrorAction Stop", fakeCmd.CombinedOutputLog[1][4])
+	assert.Equal(t, "Set-DnsClientGlobalSetting -SuffixSearchList @('svc.cluster.local','cluster.local') -ErrorAction Stop", fakeCmd.CombinedOutputLog[2][4])
+	assert.Equal(t, "Set-DnsClient -InterfaceAlias 'Ethernet' -RegisterThisConnectionsAddress $true -UseSuffixWhenRegistering $false -ErrorAction Stop", fakeCmd.CombinedOutputLog[3][4])
+	assert.Equal(t, "Set-DnsClientGlobalSetting -SuffixSearchList @() -ErrorAction Stop", fakeCmd.CombinedOutputLog[4][4])
+}
@@ -0,0 +1,184 @@
+package netsh
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rakelkar/gonetsh/cmdline"
+)
+
+// FirewallRule models a rule from: netsh advfirewall firewall show rule
+type FirewallRule struct {
+	Name string
+	// Direction is either in or out
+	Direction string
+	// Action is allow, block or bypass
+	Action string
+	// Protocol is e.g. TCP, UDP or Any
+	Protocol string
+	// LocalPort is a port, a range or a comma separated list of them, empty means any
+	LocalPort string
+	// RemoteAddress is an address, a subnet or a comma separated list of them, empty means any
+	RemoteAddress string
+	Enabled       bool
+}
+
+// GetFirewallRules returns the firewall rules called name, or every rule if name is "all"
+func (runner *runner) GetFirewallRules(name string) ([]FirewallRule, error) {
+	if err := cmdline.Name(name); err != nil {
+		return nil, fmt.Errorf("invalid firewall rule name: %v", err)
+	}
+
+	args := []string{
+		"advfirewall", "firewall", "show", "rule", "name=" + name,
+	}
+
+	output, err := runner.queryNetsh(args...)
+	if err != nil {
+		// netsh exits with an error when nothing matches
+		if strings.Contains(string(output), "No rules match the specified criteria") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list firewall rules [%v], error: %v. stdout: %v", name, err, string(output))
+	}
+
+	return parseFirewallRules(string(output)), nil
+}
+
+// AddFirewallRule adds a firewall rule, netsh allows several rules with the same name
+func (runner *runner) AddFirewallRule(rule FirewallRule) error {
+	if err := checkFirewallRule(rule); err != nil {
+		return err
+	}
+	defer runner.lock(firewallResource(rule.Name))()
+
+	args := []string{
+		"advfirewall", "firewall", "add", "rule", "name=" + rule.Name,
+		"dir=" + strings.ToLower(rule.Direction), "action=" + strings.ToLower(rule.Action), "enable=" + yesNo(rule.Enabled),
+	}
+	if rule.Protocol != "" {
+		args = append(args, "protocol="+rule.Protocol)
+	}
+	if rule.LocalPort != "" {
+		args = append(args, "localport="+rule.LocalPort)
+	}
+	if rule.RemoteAddress != "" {
+		args = append(args, "remoteip="+rule.RemoteAddress)
+	}
+
+	cmd := strings.Join(args, " ")
+	if stdout, err := runner.mutateNetsh("AddFirewallRule", []string{rule.Name}, args...); err != nil {
+		return fmt.Errorf("failed to add firewall rule [%v], error: %v. cmd: %v. stdout: %v", rule.Name, err.Error(), cmd, string(stdout))
+	}
+
+	return nil
+}
+
+// DeleteFirewallRule deletes every firewall rule called name
+func (runner *runner) DeleteFirewallRule(name string) error {
+	if err := checkFirewallRuleName(name); err != nil {
+		return err
+	}
+	defer runner.lock(firewallResource(name))()
+
+	args := []string{
+		"advfirewall", "firewall", "delete", "rule", "name=" + name,
+	}
+	cmd := strings.Join(args, " ")
+	if stdout, err := runner.mutateNetsh("DeleteFirewallRule", []string{name}, args...); err != nil {
+		return fmt.Errorf("failed to delete firewall rule [%v], error: %v. cmd: %v. stdout: %v", name, err.Error(), cmd, string(stdout))
+	}
+
+	return nil
+}
+
+// parseFirewallRules parses the "key: value" blocks of show rule, each starting with its Rule Name
+func parseFirewallRules(output string) []FirewallRule {
+	var rules []FirewallRule
+	var current *FirewallRule
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		if key == "Rule Name" {
+			rules = append(rules, FirewallRule{Name: value})
+			current = &rules[len(rules)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch key {
+		case "Enabled":
+			current.Enabled = value == "Yes"
+		case "Direction":
+			current.Direction = strings.ToLower(value)
+		case "Action":
+			current.Action = strings.ToLower(value)
+		case "Protocol":
+			current.Protocol = value
+		case "LocalPort":
+			current.LocalPort = anyToEmpty(value)
+		case "RemoteIP":
+			current.RemoteAddress = anyToEmpty(value)
+		}
+	}
+
+	return rules
+}
+
+// checkFirewallRuleName rejects names netsh cannot take, and "all" which would match every rule
+func checkFirewallRuleName(name string) error {
+	if strings.EqualFold(name, "all") {
+		return fmt.Errorf("invalid firewall rule name %q", name)
+	}
+	if err := cmdline.Name(name); err != nil {
+		return fmt.Errorf("invalid firewall rule name: %v", err)
+	}
+	return nil
+}
+
+// checkFirewallRule validates every field of rule that ends up in the add rule command
+func checkFirewallRule(rule FirewallRule) error {
+	if err := checkFirewallRuleName(rule.Name); err != nil {
+		return err
+	}
+	if err := cmdline.Word(rule.Direction); err != nil {
+		return fmt.Errorf("invalid direction of firewall rule [%v]: %v", rule.Name, err)
+	}
+	if err := cmdline.Word(rule.Action); err != nil {
+		return fmt.Errorf("invalid action of firewall rule [%v]: %v", rule.Name, err)
+	}
+	if rule.Protocol != "" && cmdline.Word(rule.Protocol) != nil {
+		return fmt.Errorf("invalid protocol %q of firewall rule [%v]", rule.Protocol, rule.Name)
+	}
+	// besides ports, localport takes keywords such as RPC
+	if rule.LocalPort != "" && cmdline.PortList(rule.LocalPort) != nil && cmdline.Word(rule.LocalPort) != nil {
+		return fmt.Errorf("invalid local port %q of firewall rule [%v]", rule.LocalPort, rule.Name)
+	}
+	if rule.RemoteAddress != "" {
+		if err := cmdline.AddressList(rule.RemoteAddress); err != nil {
+			return fmt.Errorf("invalid remote address of firewall rule [%v]: %v", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+func anyToEmpty(value string) string {
+	if strings.EqualFold(value, "Any") {
+		return ""
+	}
+	return value
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,98 @@
+package netsh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestGetFirewallRules(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte(`
+Rule Name:                            kubelet
+----------------------------------------------------------------------
+Enabled:                              Yes
+Direction:                            In
+Profiles:                             Domain,Private,Public
+Grouping:
+LocalIP:                              Any
+RemoteIP:                             10.0.0.0/255.255.0.0
+Protocol:                             TCP
+LocalPort:                            10250
+RemotePort:                           Any
+Edge traversal:                       No
+Action:                               Allow
+
+Rule Name:                            kubelet
+----------------------------------------------------------------------
+Enabled:                              No
+Direction:                            Out
+Profiles:                             Domain,Private,Public
+Grouping:
+LocalIP:                              Any
+RemoteIP:                             Any
+Protocol:                             Any
+Edge traversal:                       No
+Action:                               Block
+Ok.
+
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("\r\nNo rules match the specified criteria.\r\n"), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("The requested operation requires elevation."), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	rules, err := runner.GetFirewallRules("kubelet")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"netsh", "advfirewall", "firewall", "show", "rule", `name=kubelet`}, fakeCmd.CombinedOutputLog[0])
+	assert.EqualValues(t, []FirewallRule{
+		{Name: "kubelet", Direction: "in", Action: "allow", Protocol: "TCP", LocalPort: "10250", RemoteAddress: "10.0.0.0/255.255.0.0", Enabled: true},
+		{Name: "kubelet", Direction: "out", Action: "block", Protocol: "Any"},
+	}, rules)
+
+	rules, err = runner.GetFirewallRules("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, rules)
+
+	_, err = runner.GetFirewallRules("kubelet")
+	assert.Error(t, err)
+}
+
+func TestAddAndDeleteFirewallRule(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return []byte("Ok."), nil, nil },
+			func() ([]byte, []byte, error) { return []byte("Ok."), nil, nil },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	assert.NoError(t, runner.AddFirewallRule(FirewallRule{Name: "kubelet", Direction: "In", Action: "Allow", Protocol: "TCP", LocalPort: "10250", Enabled: true}))
+	assert.NoError(t, runner.DeleteFirewallRule("kubelet"))
+	assert.EqualValues(t, strings.Split(`netsh advfirewall firewall add rule name=kubelet dir=in action=allow enable=yes protocol=TCP localport=10250`, " "), fakeCmd.CombinedOutputLog[0])
+	assert.EqualValues(t, strings.Split(`netsh advfirewall firewall delete rule name=kubelet`, " "), fakeCmd.CombinedOutputLog[1])
+
+	assert.Error(t, runner.AddFirewallRule(FirewallRule{Name: "all"}))
+	assert.Error(t, runner.DeleteFirewallRule(""))
+	assert.Equal(t, 2, fakeCmd.CombinedOutputCalls)
+}
@@ -0,0 +1,50 @@
+package netsh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GetForwarding returns whether IPv4 forwarding is enabled on the interface (name or index)
+func (runner *runner) GetForwarding(iface string) (bool, error) {
+	if err := checkInterface(iface); err != nil {
+		return false, err
+	}
+
+	args := runner.ipv4Args(
+		"interface", "ipv4", "show", "interface", iface,
+	)
+	cmd := strings.Join(args, " ")
+	output, err := runner.queryNetsh(args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to get forwarding of [%v], error: %v. cmd: %v. stdout: %v", iface, err.Error(), cmd, string(output))
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != "Forwarding" {
+			continue
+		}
+		return strings.TrimSpace(parts[1]) == "enabled", nil
+	}
+
+	return false, fmt.Errorf("no forwarding state found for [%v] in netsh output: %v", iface, string(output))
+}
+
+// Disable forwarding on the interface (name or index)
+func (runner *runner) DisableForwarding(iface string) error {
+	if err := checkInterface(iface); err != nil {
+		return err
+	}
+	defer runner.lock(interfaceResource(iface))()
+
+	args := runner.ipv4Args(
+		"int", "ipv4", "set", "int", iface, "for=dis",
+	)
+	cmd := strings.Join(args, " ")
+	if stdout, err := runner.mutateNetsh("DisableForwarding", []string{iface}, args...); err != nil {
+		return fmt.Errorf("failed to disable forwarding on [%v], error: %v. cmd: %v. stdout: %v", iface, err.Error(), cmd, string(stdout))
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package netsh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestGetForwarding(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte(`
+Interface Ethernet Parameters
+----------------------------------------------
+IfLuid                             : ethernet_32768
+IfIndex                            : 4
+State                              : connected
+Metric                             : 25
+Forwarding                         : enabled
+Advertising                        : disabled
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("Forwarding                         : disabled\r\n"), nil, nil
+			},
+			func() ([]byte, []byte, error) { return []byte("junk"), nil, nil },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	enabled, err := runner.GetForwarding("Ethernet")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.EqualValues(t, []string{"netsh", "interface", "ipv4", "show", "interface", "Ethernet"}, fakeCmd.CombinedOutputLog[0])
+
+	enabled, err = runner.GetForwarding("Ethernet")
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	_, err = runner.GetForwarding("Ethernet")
+	assert.Error(t, err)
+}
+
+func TestDisableForwarding(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+			func() ([]byte, []byte, error) { return []byte("Element not found."), nil, &fakeexec.FakeExitError{Status: 1} },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	assert.NoError(t, runner.DisableForwarding("Ethernet"))
+	assert.EqualValues(t, strings.Split(`netsh int ipv4 set int Ethernet for=dis`, " "), fakeCmd.CombinedOutputLog[0])
+
+	err := runner.DisableForwarding("missing")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Element not found")
+}
@@ -2,12 +2,18 @@ package netsh
 
 import (
 	"fmt"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"errors"
 
+	"github.com/rakelkar/gonetsh/cmdline"
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/rakelkar/gonetsh/observer"
+	"github.com/rakelkar/gonetsh/retry"
 	utilexec "k8s.io/utils/exec"
 )
 
@@ -17,6 +23,8 @@ type Interface interface {
 	EnsurePortProxyRule(args []string) (bool, error)
 	// DeletePortProxyRule deletes the specified portproxy rule.  If the rule did not exist, return error.
 	DeletePortProxyRule(args []string) error
+	// GetPortProxyRules lists the portproxy rules on the host
+	GetPortProxyRules() ([]PortProxyRule, error)
 	// DeleteIPAddress checks if the specified IP address is present and, if so, deletes it.
 	DeleteIPAddress(args []string) error
 	// Restore runs `netsh exec` to restore portproxy or addresses using a file.
@@ -32,8 +40,46 @@ type Interface interface {
 	GetInterfaceByIP(ipAddr string) (Ipv4Interface, error)
 	// Enable forwarding on the interface (name or index)
 	EnableForwarding(iface string) error
+	// Disable forwarding on the interface (name or index)
+	DisableForwarding(iface string) error
+	// Get whether forwarding is enabled on the interface (name or index)
+	GetForwarding(iface string) (bool, error)
+	// Get every IPv4 address of the interface
+	GetIPAddresses(iface string) ([]*net.IPNet, error)
+	// Add a static IPv4 address to the interface
+	AddIPAddress(iface string, address *net.IPNet) error
+	// Remove a static IPv4 address from the interface
+	RemoveIPAddress(iface string, ip net.IP) error
 	// Set the DNS server for interface
 	SetDNSServer(iface string, dns string) error
+	// Get the DNS client configuration of an interface
+	GetDNSClientConfig(iface string) (DNSClientConfig, error)
+	// Set the ordered list of DNS servers for interface, the first one is the primary
+	SetDNSServers(iface string, servers []net.IP) error
+	// Revert the DNS servers of interface to the ones provided by DHCP
+	ResetDNSToDHCP(iface string) error
+	// Set the connection-specific DNS suffix of interface
+	SetDNSSuffix(iface string, suffix string) error
+	// Set the global DNS suffix search list
+	SetSuffixSearchList(suffixes []string) error
+	// Set whether interface registers its address in DNS, and whether it uses its connection-specific suffix to do so
+	SetDNSRegistration(iface string, register bool, useSuffix bool) error
+	// Get the network compartments on the host
+	GetCompartments() ([]Compartment, error)
+	// Get an Interface whose interface commands target the given compartment
+	InCompartment(id int) Interface
+	// Get the firewall rules with the given name, or every rule for "all"
+	GetFirewallRules(name string) ([]FirewallRule, error)
+	// Add a firewall rule
+	AddFirewallRule(rule FirewallRule) error
+	// Delete every firewall rule with the given name
+	DeleteFirewallRule(name string) error
+	// Get a Batch that queues changes and runs them as one netsh script
+	NewBatch() *Batch
+	// Get whether changes are recorded instead of run, batches of a dry-run Interface skip their verification
+	DryRun() bool
+	// Run a netsh script with `netsh -f`
+	RunScript(script string) ([]byte, error)
 }
 
 const (
@@ -43,6 +89,48 @@ const (
 // runner implements Interface in terms of exec("netsh").
 type runner struct {
 	exec utilexec.Interface
+	// compartmentID scopes interface commands to a network compartment, zero uses the default one
+	compartmentID int
+	// session runs commands in one long lived netsh process when set
+	session *session
+	// dryRun records mutations instead of running them when set
+	dryRun *dryrun.Recorder
+	// observer is notified of every command that ran, if set
+	observer observer.Observer
+	// retry reruns reads and Ensure operations that failed transiently, nil runs them once
+	retry *retry.Policy
+	// concurrency serializes mutations and rate limits processes when set
+	concurrency *ConcurrencyController
+	// remoteHost is the machine netsh and powershell commands are sent to, empty targets the local one
+	remoteHost string
+}
+
+// Options configures the runner created by NewWithOptions
+type Options struct {
+	// Exec runs netsh, defaults to utilexec.New()
+	Exec utilexec.Interface
+	// Interactive keeps one netsh process open and pipes every command to it instead of spawning one per command.
+	// The returned Interface is then an io.Closer that stops the process, decorators do not forward Close so keep
+	// the undecorated one to close it.
+	Interactive bool
+	// CommandTimeout bounds each command of an interactive session, the process is restarted after a timeout.
+	// Zero waits forever.
+	CommandTimeout time.Duration
+	// DryRun records every command that would change the host instead of running it and reports it as successful.
+	// Read commands still run.
+	DryRun *dryrun.Recorder
+	// Observer is notified of every netsh and powershell command with its duration, exit status and output. Wrap it
+	// with observer.Redacting to mask sensitive arguments.
+	Observer observer.Observer
+	// Retry reruns the commands of reads and EnsurePortProxyRule when they fail transiently, e.g. on an adapter
+	// that was just created. Other mutations are never retried.
+	Retry *retry.Policy
+	// Concurrency serializes mutations globally or per resource and rate limits the processes started. Share one
+	// controller between the runners of a process.
+	Concurrency *ConcurrencyController
+	// RemoteHost runs every command against another Windows machine with `netsh -r`, and powershell commands with
+	// Invoke-Command. To run netsh on a remote machine instead, e.g. over SSH, pass an Exec from remote.Exec.
+	RemoteHost string
 }
 
 // Ipv4Interface models IPv4 interface output from: netsh interface ipv4 show addresses
@@ -71,6 +159,107 @@ func New(exec utilexec.Interface) Interface {
 	return runner
 }
 
+// NewWithOptions returns a new Interface configured by opts. In interactive mode netsh is started right away and
+// restarted on next use if it crashes, to stop it type assert the result to io.Closer and call Close.
+func NewWithOptions(opts Options) (Interface, error) {
+	exec := opts.Exec
+	if exec == nil {
+		exec = utilexec.New()
+	}
+
+	runner := &runner{
+		exec:        exec,
+		dryRun:      opts.DryRun,
+		observer:    opts.Observer,
+		retry:       opts.Retry,
+		concurrency: opts.Concurrency,
+		remoteHost:  opts.RemoteHost,
+	}
+	if opts.Interactive {
+		session, err := newSession(exec, opts.CommandTimeout, runner.netshArgs()...)
+		if err != nil {
+			return nil, err
+		}
+		runner.session = session
+	}
+	return runner, nil
+}
+
+// WithObserver returns a copy of the runner that also reports its commands to obs, sharing the interactive session.
+// It is not part of Interface, decorators look for it to attribute the commands of a single call to that call.
+func (runner *runner) WithObserver(obs observer.Observer) Interface {
+	scoped := *runner
+	scoped.observer = observer.Multi(runner.observer, obs)
+	return &scoped
+}
+
+// runNetsh runs one netsh command, in the interactive session if there is one
+func (runner *runner) runNetsh(args ...string) ([]byte, error) {
+	if runner.session != nil {
+		command := observer.Start(runner.observer, observer.Event{Package: "netsh", Program: cmdNetsh, Args: args})
+		output, err := runner.session.run(args)
+		command.Done(output, err)
+		return output, err
+	}
+
+	args = runner.netshArgs(args...)
+	runner.concurrency.spawn()
+	command := observer.Start(runner.observer, observer.Event{Package: "netsh", Program: cmdNetsh, Args: args})
+	output, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput()
+	command.Done(output, err)
+	return output, err
+}
+
+// netshArgs prepends the remote host to the arguments of a netsh process if the runner targets one
+func (runner *runner) netshArgs(args ...string) []string {
+	if runner.remoteHost == "" {
+		return args
+	}
+	return append([]string{"-r", runner.remoteHost}, args...)
+}
+
+// isRemoteExec reports whether the runner's exec runs netsh on another machine, where local files cannot be read
+func (runner *runner) isRemoteExec() bool {
+	_, ok := runner.exec.(interface{ Remote() })
+	return ok
+}
+
+// queryNetsh runs a netsh command that only reads, rerunning it according to the retry policy
+func (runner *runner) queryNetsh(args ...string) (output []byte, err error) {
+	runner.retry.Do(func() error {
+		output, err = runner.runNetsh(args...)
+		return withOutput(err, output)
+	})
+	return output, err
+}
+
+// withOutput adds the output of a failed command to its error, netsh reports the cause on stdout
+func withOutput(err error, output []byte) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%v: %s", err, output)
+}
+
+// mutateNetsh runs a netsh command that changes the host, in dry-run mode it is recorded under the calling
+// operation and its params instead
+func (runner *runner) mutateNetsh(operation string, params []string, args ...string) ([]byte, error) {
+	if runner.dryRun != nil {
+		command, err := cmdline.NetshLine(runner.netshArgs(args...))
+		if err != nil {
+			return nil, err
+		}
+		runner.dryRun.Record(dryrun.Entry{
+			Package:   "netsh",
+			Operation: operation,
+			Args:      params,
+			Command:   cmdNetsh + " " + command,
+		})
+		return nil, nil
+	}
+	return runner.runNetsh(args...)
+}
+
 func (runner *runner) GetInterfaces() ([]Ipv4Interface, error) {
 	interfaces, interfaceError := runner.getIpAddressConfigurations()
 
@@ -100,11 +289,11 @@ func (runner *runner) GetInterfaces() ([]Ipv4Interface, error) {
 
 // GetInterfaces uses the show addresses command and returns a formatted structure
 func (runner *runner) getIpAddressConfigurations() ([]Ipv4Interface, error) {
-	args := []string{
+	args := runner.ipv4Args(
 		"interface", "ipv4", "show", "config",
-	}
+	)
 
-	output, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput()
+	output, err := runner.queryNetsh(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -176,11 +365,11 @@ func (runner *runner) getIpAddressConfigurations() ([]Ipv4Interface, error) {
 }
 
 func (runner *runner) getNetworkInterfaceParameters() (map[string]int, error) {
-	args := []string{
+	args := runner.ipv4Args(
 		"interface", "ipv4", "show", "interfaces",
-	}
+	)
 
-	output, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput()
+	output, err := runner.queryNetsh(args...)
 
 	if err != nil {
 		return nil, err
@@ -221,11 +410,16 @@ func (runner *runner) getNetworkInterfaceParameters() (map[string]int, error) {
 
 // Enable forwarding on the interface (name or index)
 func (runner *runner) EnableForwarding(iface string) error {
-	args := []string{
-		"int", "ipv4", "set", "int", strconv.Quote(iface), "for=en",
+	if err := checkInterface(iface); err != nil {
+		return err
 	}
+	defer runner.lock(interfaceResource(iface))()
+
+	args := runner.ipv4Args(
+		"int", "ipv4", "set", "int", iface, "for=en",
+	)
 	cmd := strings.Join(args, " ")
-	if stdout, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput(); err != nil {
+	if stdout, err := runner.mutateNetsh("EnableForwarding", []string{iface}, args...); err != nil {
 		return fmt.Errorf("failed to enable forwarding on [%v], error: %v. cmd: %v. stdout: %v", iface, err.Error(), cmd, string(stdout))
 	}
 
@@ -234,7 +428,14 @@ func (runner *runner) EnableForwarding(iface string) error {
 
 // EnsurePortProxyRule checks if the specified redirect exists, if not creates it.
 func (runner *runner) EnsurePortProxyRule(args []string) (bool, error) {
-	out, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput()
+	defer runner.lock(argsResource(args))()
+
+	var out []byte
+	var err error
+	runner.retry.Do(func() error {
+		out, err = runner.mutateNetsh("EnsurePortProxyRule", args, args...)
+		return withOutput(err, out)
+	})
 
 	if err == nil {
 		return true, nil
@@ -252,7 +453,9 @@ func (runner *runner) EnsurePortProxyRule(args []string) (bool, error) {
 
 // DeletePortProxyRule deletes the specified portproxy rule.  If the rule did not exist, return error.
 func (runner *runner) DeletePortProxyRule(args []string) error {
-	out, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput()
+	defer runner.lock(argsResource(args))()
+
+	out, err := runner.mutateNetsh("DeletePortProxyRule", args, args...)
 
 	if err == nil {
 		return nil
@@ -269,7 +472,9 @@ func (runner *runner) DeletePortProxyRule(args []string) error {
 
 // DeleteIPAddress checks if the specified IP address is present and, if so, deletes it.
 func (runner *runner) DeleteIPAddress(args []string) error {
-	out, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput()
+	defer runner.lock(argsResource(args))()
+
+	out, err := runner.mutateNetsh("DeleteIPAddress", args, args...)
 
 	if err == nil {
 		return nil
@@ -333,18 +538,42 @@ func (runner *runner) GetInterfaceByIP(ipAddr string) (Ipv4Interface, error) {
 }
 
 func (runner *runner) SetDNSServer(iface string, dns string) error {
-	args := []string{
-		"int", "ipv4", "set", "dns", strconv.Quote(iface), "static", strconv.Quote(dns),
+	if err := checkInterface(iface); err != nil {
+		return err
+	}
+	if net.ParseIP(dns) == nil {
+		return fmt.Errorf("invalid dns server %q for [%v]", dns, iface)
 	}
+	defer runner.lock(interfaceResource(iface))()
+
+	args := runner.ipv4Args(
+		"int", "ipv4", "set", "dns", iface, "static", dns,
+	)
 	cmd := strings.Join(args, " ")
-	if stdout, err := runner.exec.Command(cmdNetsh, args...).CombinedOutput(); err != nil {
+	if stdout, err := runner.mutateNetsh("SetDNSServer", []string{iface, dns}, args...); err != nil {
 		return fmt.Errorf("failed to set dns on [%v], error: %v. cmd: %v. stdout: %v", iface, err.Error(), cmd, string(stdout))
 	}
 
 	return nil
 }
 
+// checkInterface validates an interface name or index before it is passed to netsh or powershell
+func checkInterface(iface string) error {
+	if err := cmdline.Name(iface); err != nil {
+		return fmt.Errorf("invalid interface: %v", err)
+	}
+	return nil
+}
+
 // Restore is part of Interface.
 func (runner *runner) Restore(args []string) error {
 	return nil
 }
+
+// Close stops the interactive netsh process, if any
+func (runner *runner) Close() error {
+	if runner.session != nil {
+		runner.session.Exit()
+	}
+	return nil
+}
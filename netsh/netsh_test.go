@@ -1,10 +1,20 @@
 package netsh
 
 import (
+	"io"
+	"io/ioutil"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/rakelkar/gonetsh/dryrun"
+	"github.com/rakelkar/gonetsh/observer"
+	"github.com/rakelkar/gonetsh/remote"
+	fakeremote "github.com/rakelkar/gonetsh/remote/testing"
+	"github.com/rakelkar/gonetsh/retry"
 	"github.com/stretchr/testify/assert"
+	fakeclock "k8s.io/utils/clock/testing"
 	"k8s.io/utils/exec"
 	fakeexec "k8s.io/utils/exec/testing"
 )
@@ -155,3 +165,205 @@ func TestGetInterfaceNameToIndexMap(t *testing.T) {
 	assert.Equal(t, 9, idxMap["Ethernet"])
 	assert.Equal(t, 14, idxMap["vEthernet (HNS Internal NIC)"])
 }
+
+func TestDryRun(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte("Forwarding                         : disabled\r\n"), nil, nil
+			},
+		},
+	}
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+	recorder := dryrun.NewRecorder()
+
+	nsh, err := NewWithOptions(Options{Exec: &fakeExec, DryRun: recorder})
+	assert.NoError(t, err)
+
+	// reads still run
+	forwarding, err := nsh.GetForwarding("Ethernet")
+	assert.NoError(t, err)
+	assert.False(t, forwarding)
+
+	assert.NoError(t, nsh.EnableForwarding("vEthernet (nat)"))
+	assert.NoError(t, nsh.InCompartment(2).SetDNSServers("Ethernet", []net.IP{net.ParseIP("10.96.0.10"), net.ParseIP("1.1.1.1")}))
+	assert.NoError(t, nsh.SetDNSSuffix("Ethernet", "cluster.local"))
+	ok, err := nsh.EnsurePortProxyRule([]string{"interface", "portproxy", "add", "v4tov4", "listenport=8080", "connectaddress=10.0.0.5", "connectport=80"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.Equal(t, 1, fakeCmd.CombinedOutputCalls)
+	assert.Equal(t, []dryrun.Entry{
+		{
+			Package:   "netsh",
+			Operation: "EnableForwarding",
+			Args:      []string{"vEthernet (nat)"},
+			Command:   `netsh int ipv4 set int "vEthernet (nat)" for=en`,
+		},
+		{
+			Package:   "netsh",
+			Operation: "SetDNSServers",
+			Args:      []string{"Ethernet", "10.96.0.10", "1.1.1.1"},
+			Command:   `netsh int ipv4 set dns Ethernet static 10.96.0.10 primary compartment=2`,
+		},
+		{
+			Package:   "netsh",
+			Operation: "SetDNSServers",
+			Args:      []string{"Ethernet", "10.96.0.10", "1.1.1.1"},
+			Command:   `netsh int ipv4 add dns Ethernet 1.1.1.1 index=2 compartment=2`,
+		},
+		{
+			Package:   "netsh",
+			Operation: "SetDNSSuffix",
+			Args:      []string{"Ethernet", "cluster.local"},
+			Command:   "Set-DnsClient -InterfaceAlias 'Ethernet' -ConnectionSpecificSuffix 'cluster.local' -ErrorAction Stop",
+		},
+		{
+			Package:   "netsh",
+			Operation: "EnsurePortProxyRule",
+			Args:      []string{"interface", "portproxy", "add", "v4tov4", "listenport=8080", "connectaddress=10.0.0.5", "connectport=80"},
+			Command:   "netsh interface portproxy add v4tov4 listenport=8080 connectaddress=10.0.0.5 connectport=80",
+		},
+	}, recorder.Entries())
+}
+
+func TestObserver(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte("Forwarding                         : enabled\r\n"), nil, nil
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("Access is denied."), nil, &fakeexec.FakeExitError{Status: 5}
+			},
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+		},
+	}
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+	var events []observer.Event
+
+	nsh, err := NewWithOptions(Options{Exec: &fakeExec, Observer: observer.Func(func(event observer.Event) {
+		events = append(events, event)
+	})})
+	assert.NoError(t, err)
+
+	_, err = nsh.InCompartment(2).GetForwarding("Ethernet")
+	assert.NoError(t, err)
+	assert.Error(t, nsh.SetDNSSuffix("Ethernet", "cluster.local"))
+	_, err = nsh.RunScript("interface ipv4 set interface \"Ethernet\" forwarding=enabled\r\n")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, "netsh", events[0].Package)
+	assert.Equal(t, "netsh", events[0].Program)
+	assert.Equal(t, []string{"interface", "ipv4", "show", "interface", "Ethernet", "compartment=2"}, events[0].Args)
+	assert.Equal(t, 0, events[0].ExitStatus)
+	assert.Equal(t, "Forwarding                         : enabled\r\n", events[0].Output)
+
+	assert.Equal(t, "powershell.exe", events[1].Program)
+	assert.Equal(t, "Set-DnsClient -InterfaceAlias 'Ethernet' -ConnectionSpecificSuffix 'cluster.local' -ErrorAction Stop", events[1].Args[3])
+	assert.Equal(t, 5, events[1].ExitStatus)
+	assert.Equal(t, "Access is denied.", events[1].Output)
+	assert.Error(t, events[1].Err)
+
+	assert.Equal(t, "-f", events[2].Args[0])
+	assert.Equal(t, "interface ipv4 set interface \"Ethernet\" forwarding=enabled\r\n", events[2].Input)
+}
+
+func TestRetry(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte("Element not found."), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("Forwarding                         : enabled\r\n"), nil, nil
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("Element not found."), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("The object is already in use."), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+		},
+	}
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+	start := time.Now()
+	clock := fakeclock.NewFakeClock(start)
+
+	nsh, err := NewWithOptions(Options{Exec: &fakeExec, Retry: &retry.Policy{MaxAttempts: 3, InitialBackoff: time.Second, Clock: clock}})
+	assert.NoError(t, err)
+
+	enabled, err := nsh.GetForwarding("Ethernet")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, time.Second, clock.Since(start))
+
+	// plain mutations are not retried
+	assert.Error(t, nsh.EnableForwarding("Ethernet"))
+	assert.Equal(t, time.Second, clock.Since(start))
+
+	exists, err := nsh.EnsurePortProxyRule([]string{"interface", "portproxy", "add", "v4tov4", "listenport=8080", "connectaddress=10.0.0.5", "connectport=80"})
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 2*time.Second, clock.Since(start))
+	assert.Equal(t, 5, fakeCmd.CombinedOutputCalls)
+}
+
+func TestRemoteHost(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte("Forwarding                         : enabled\r\n"), nil, nil
+			},
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+		},
+	}
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+	recorder := dryrun.NewRecorder()
+
+	nsh, err := NewWithOptions(Options{Exec: &fakeExec, RemoteHost: "win-node-1"})
+	assert.NoError(t, err)
+
+	_, err = nsh.GetForwarding("Ethernet")
+	assert.NoError(t, err)
+	assert.NoError(t, nsh.SetDNSSuffix("Ethernet", "cluster.local"))
+
+	assert.Equal(t, []string{"netsh", "-r", "win-node-1", "interface", "ipv4", "show", "interface", "Ethernet"}, fakeCmd.CombinedOutputLog[0])
+	assert.Equal(t, "Invoke-Command -ComputerName 'win-node-1' -ErrorAction Stop -ScriptBlock { Set-DnsClient -InterfaceAlias 'Ethernet' -ConnectionSpecificSuffix 'cluster.local' -ErrorAction Stop }", fakeCmd.CombinedOutputLog[1][4])
+
+	nsh, err = NewWithOptions(Options{DryRun: recorder, RemoteHost: "win-node-1"})
+	assert.NoError(t, err)
+	assert.NoError(t, nsh.EnableForwarding("Ethernet"))
+	assert.Equal(t, "netsh -r win-node-1 int ipv4 set int Ethernet for=en", recorder.Entries()[0].Command)
+}
+
+func TestRemoteExec(t *testing.T) {
+	transport := fakeremote.NewFakeTransport(func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+		if cmd == "netsh" {
+			script, _ := ioutil.ReadAll(stdin)
+			io.WriteString(stdout, "netsh>"+string(script))
+			return 0
+		}
+		io.WriteString(stdout, "Element not found.\r\n")
+		return 1
+	})
+
+	nsh, err := NewWithOptions(Options{Exec: remote.Exec(transport)})
+	assert.NoError(t, err)
+
+	// exit statuses of the remote netsh are reported as before
+	exists, err := nsh.EnsurePortProxyRule([]string{"interface", "portproxy", "add", "v4tov4", "listenport=8080", "connectaddress=10.0.0.5", "connectport=80"})
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// the script file would not exist on the remote host, it is piped instead
+	output, err := nsh.RunScript("interface ipv4 set interface \"Ethernet\" forwarding=enabled\r\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "netsh>interface ipv4 set interface \"Ethernet\" forwarding=enabled\r\n\r\nexit\r\n", string(output))
+	assert.Equal(t, []string{
+		"netsh interface portproxy add v4tov4 listenport=8080 connectaddress=10.0.0.5 connectport=80",
+		"netsh",
+	}, transport.Commands())
+}
@@ -0,0 +1,74 @@
+package netsh
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PortProxyRule models a rule from: netsh interface portproxy show all
+type PortProxyRule struct {
+	// Protocol is one of v4tov4, v4tov6, v6tov4 or v6tov6
+	Protocol       string
+	ListenAddress  string
+	ListenPort     int
+	ConnectAddress string
+	ConnectPort    int
+}
+
+// GetPortProxyRules lists the portproxy rules on the host
+func (runner *runner) GetPortProxyRules() ([]PortProxyRule, error) {
+	args := []string{
+		"interface", "portproxy", "show", "all",
+	}
+
+	output, err := runner.queryNetsh(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list portproxy rules, error: %v. stdout: %v", err, string(output))
+	}
+
+	return parsePortProxyRules(string(output)), nil
+}
+
+// parsePortProxyRules parses the tables of show all, each headed by e.g. "Listen on ipv4:   Connect to ipv6:"
+func parsePortProxyRules(output string) []PortProxyRule {
+	sectionPattern := regexp.MustCompile(`Listen on (ipv[46]):\s+Connect to (ipv[46]):`)
+	reg := regexp.MustCompile(`\s+`)
+
+	var rules []PortProxyRule
+	protocol := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if match := sectionPattern.FindStringSubmatch(line); match != nil {
+			protocol = "v" + strings.TrimPrefix(match[1], "ipv") + "tov" + strings.TrimPrefix(match[2], "ipv")
+			continue
+		}
+		if protocol == "" {
+			continue
+		}
+
+		fields := reg.Split(line, -1)
+		if len(fields) != 4 {
+			continue
+		}
+		listenPort, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		connectPort, err := strconv.Atoi(fields[3])
+		if err != nil {
+			continue
+		}
+
+		rules = append(rules, PortProxyRule{
+			Protocol:       protocol,
+			ListenAddress:  fields[0],
+			ListenPort:     listenPort,
+			ConnectAddress: fields[2],
+			ConnectPort:    connectPort,
+		})
+	}
+
+	return rules
+}
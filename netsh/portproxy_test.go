@@ -0,0 +1,58 @@
+package netsh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestGetPortProxyRules(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte(`
+
+Listen on ipv4:             Connect to ipv4:
+
+Address         Port        Address         Port
+--------------- ----------  --------------- ----------
+0.0.0.0         8080        10.0.0.5        80
+*               9090        10.0.0.6        90
+
+Listen on ipv6:             Connect to ipv4:
+
+Address         Port        Address         Port
+--------------- ----------  --------------- ----------
+::              443         172.16.0.2      8443
+
+`), nil, nil
+			},
+			func() ([]byte, []byte, error) { return nil, nil, &fakeexec.FakeExitError{Status: 1} },
+		},
+	}
+
+	fakeExec := getFakeExecTemplate(&fakeCmd)
+
+	runner := runner{
+		exec: &fakeExec,
+	}
+
+	rules, err := runner.GetPortProxyRules()
+	assert.NoError(t, err)
+	assert.EqualValues(t, strings.Split("netsh interface portproxy show all", " "), fakeCmd.CombinedOutputLog[0])
+	assert.EqualValues(t, []PortProxyRule{
+		{Protocol: "v4tov4", ListenAddress: "0.0.0.0", ListenPort: 8080, ConnectAddress: "10.0.0.5", ConnectPort: 80},
+		{Protocol: "v4tov4", ListenAddress: "*", ListenPort: 9090, ConnectAddress: "10.0.0.6", ConnectPort: 90},
+		{Protocol: "v6tov4", ListenAddress: "::", ListenPort: 443, ConnectAddress: "172.16.0.2", ConnectPort: 8443},
+	}, rules)
+
+	rules, err = runner.GetPortProxyRules()
+	assert.Error(t, err)
+	assert.Nil(t, rules)
+}
+
+func TestGetPortProxyRulesEmpty(t *testing.T) {
+	assert.Nil(t, parsePortProxyRules("\r\n"))
+}
@@ -0,0 +1,208 @@
+package netsh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rakelkar/gonetsh/cmdline"
+	utilexec "k8s.io/utils/exec"
+)
+
+// sessionErrorMarkers are printed by netsh for failed commands. An interactive session has no per command exit
+// status, so a command whose output contains one of these is reported as having exited with status 1.
+var sessionErrorMarkers = []string{
+	"The following command was not found",
+	"The syntax supplied for this command is not valid",
+	"The parameter is incorrect",
+	"Element not found",
+	"The system cannot find the file specified",
+	"The object already exists",
+	"The requested operation requires elevation",
+	"The filename, directory name, or volume label syntax is incorrect",
+	"Invalid interface",
+}
+
+// sessionExitError lets callers of an interactive session inspect failures like those of a netsh process
+type sessionExitError struct {
+	output string
+}
+
+func (e *sessionExitError) String() string {
+	return e.Error()
+}
+
+func (e *sessionExitError) Error() string {
+	return "netsh command failed: " + strings.TrimSpace(e.output)
+}
+
+func (e *sessionExitError) Exited() bool {
+	return true
+}
+
+func (e *sessionExitError) ExitStatus() int {
+	return 1
+}
+
+var _ = utilexec.ExitError(&sessionExitError{})
+
+// session keeps one interactive netsh process open and pipes commands to its stdin. Each command is followed by an
+// unknown sentinel command, netsh's complaint about it marks the end of the command's output.
+type session struct {
+	exec    utilexec.Interface
+	timeout time.Duration
+	// args are passed to netsh when it is started, e.g. the remote host
+	args []string
+
+	mu       sync.Mutex
+	cmd      utilexec.Cmd
+	stdin    io.WriteCloser
+	lines    chan string
+	sequence int
+}
+
+func newSession(exec utilexec.Interface, timeout time.Duration, args ...string) (*session, error) {
+	s := &session{
+		exec:    exec,
+		timeout: timeout,
+		args:    args,
+	}
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// start launches netsh, must be called with mu held
+func (s *session) start() error {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	cmd := s.exec.Command(cmdNetsh, s.args...)
+	cmd.SetStdin(stdinReader)
+	cmd.SetStdout(stdoutWriter)
+	cmd.SetStderr(stdoutWriter)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start interactive netsh: %v", err)
+	}
+
+	lines := make(chan string, 64)
+	go func() {
+		scanner := bufio.NewScanner(stdoutReader)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	go func() {
+		// unblock the reader and any pending write once the process is gone
+		cmd.Wait()
+		stdoutWriter.Close()
+		stdinReader.Close()
+	}()
+
+	s.cmd = cmd
+	s.stdin = stdinWriter
+	s.lines = lines
+	return nil
+}
+
+// stop kills netsh, must be called with mu held
+func (s *session) stop() {
+	if s.cmd == nil {
+		return
+	}
+	s.stdin.Close()
+	s.cmd.Stop()
+	s.cmd = nil
+}
+
+// run sends one command and returns its output, restarting netsh first if it has died
+func (s *session) run(args []string) ([]byte, error) {
+	command, err := cmdline.NetshLine(args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cmd == nil {
+		if err := s.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.sequence++
+	sentinel := fmt.Sprintf("gonetsh-sentinel-%d", s.sequence)
+
+	if _, err := io.WriteString(s.stdin, command+"\r\n"+sentinel+"\r\n"); err != nil {
+		// the command never reached netsh, so it is safe to retry it once on a fresh process
+		s.stop()
+		if err := s.start(); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(s.stdin, command+"\r\n"+sentinel+"\r\n"); err != nil {
+			s.stop()
+			return nil, fmt.Errorf("failed to send command to interactive netsh: %v", err)
+		}
+	}
+
+	output, err := s.read(sentinel)
+	if err != nil {
+		s.stop()
+		return nil, fmt.Errorf("interactive netsh failed running %v: %v", command, err)
+	}
+
+	for _, marker := range sessionErrorMarkers {
+		if strings.Contains(output, marker) {
+			return []byte(output), &sessionExitError{output: output}
+		}
+	}
+	return []byte(output), nil
+}
+
+// read collects output lines up to the line complaining about the sentinel
+func (s *session) read(sentinel string) (string, error) {
+	var timeout <-chan time.Time
+	if s.timeout > 0 {
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var output []string
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				return "", errors.New("netsh exited")
+			}
+			if strings.Contains(line, sentinel) {
+				return strings.Join(output, "\n"), nil
+			}
+			output = append(output, stripPrompt(line))
+		case <-timeout:
+			return "", fmt.Errorf("timed out after %v", s.timeout)
+		}
+	}
+}
+
+// Exit stops the netsh process
+func (s *session) Exit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+}
+
+// stripPrompt removes the netsh> prompt that precedes the output of each command
+func stripPrompt(line string) string {
+	for strings.HasPrefix(line, "netsh>") {
+		line = strings.TrimPrefix(line, "netsh>")
+	}
+	return line
+}
@@ -0,0 +1,217 @@
+package netsh
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+type interactiveAction int
+
+const (
+	interactiveReply interactiveAction = iota
+	interactiveCrash
+	interactiveHang
+)
+
+// fakeInteractiveNetsh emulates netsh reading commands from stdin, answering each with a prompt and its output
+type fakeInteractiveNetsh struct {
+	handler func(line string) (string, interactiveAction)
+
+	mu       sync.Mutex
+	starts   int
+	commands []string
+}
+
+func (f *fakeInteractiveNetsh) Command(cmd string, args ...string) exec.Cmd {
+	return &fakeInteractiveCmd{
+		FakeCmd: fakeexec.InitFakeCmd(&fakeexec.FakeCmd{}, cmd, args...).(*fakeexec.FakeCmd),
+		netsh:   f,
+		done:    make(chan struct{}),
+	}
+}
+
+func (f *fakeInteractiveNetsh) CommandContext(ctx context.Context, cmd string, args ...string) exec.Cmd {
+	return f.Command(cmd, args...)
+}
+
+func (f *fakeInteractiveNetsh) LookPath(file string) (string, error) {
+	return file, nil
+}
+
+type fakeInteractiveCmd struct {
+	*fakeexec.FakeCmd
+	netsh *fakeInteractiveNetsh
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (c *fakeInteractiveCmd) exit() {
+	c.once.Do(func() { close(c.done) })
+}
+
+func (c *fakeInteractiveCmd) Start() error {
+	c.netsh.mu.Lock()
+	c.netsh.starts++
+	c.netsh.mu.Unlock()
+
+	go func() {
+		defer c.exit()
+		scanner := bufio.NewScanner(c.Stdin)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "gonetsh-sentinel-") {
+				fmt.Fprintf(c.Stdout, "netsh>The following command was not found: %v.\r\n", line)
+				continue
+			}
+
+			c.netsh.mu.Lock()
+			c.netsh.commands = append(c.netsh.commands, line)
+			output, action := c.netsh.handler(line)
+			c.netsh.mu.Unlock()
+
+			switch action {
+			case interactiveCrash:
+				return
+			case interactiveHang:
+				<-c.done
+				return
+			}
+			fmt.Fprintf(c.Stdout, "netsh>%v\r\n", output)
+		}
+	}()
+	return nil
+}
+
+func (c *fakeInteractiveCmd) Wait() error {
+	<-c.done
+	return nil
+}
+
+func (c *fakeInteractiveCmd) Stop() {
+	c.exit()
+}
+
+var _ = exec.Interface(&fakeInteractiveNetsh{})
+
+func TestInteractiveSession(t *testing.T) {
+	fake := &fakeInteractiveNetsh{
+		handler: func(line string) (string, interactiveAction) {
+			switch line {
+			case "interface ipv4 show compartments":
+				return "\r\nCompartment Id  Description\r\n--------------  -----------\r\n             1  Default Compartment\r\n", interactiveReply
+			case "interface portproxy add v4tov4 listenport=80 connectport=8080":
+				return "The object already exists.", interactiveReply
+			}
+			return "Ok.", interactiveReply
+		},
+	}
+
+	nsh, err := NewWithOptions(Options{Exec: fake, Interactive: true})
+	assert.NoError(t, err)
+	defer nsh.(io.Closer).Close()
+
+	compartments, err := nsh.GetCompartments()
+	assert.NoError(t, err)
+	assert.EqualValues(t, []Compartment{{ID: 1, Description: "Default Compartment"}}, compartments)
+
+	assert.NoError(t, nsh.EnableForwarding("vEthernet (nat)"))
+
+	created, err := nsh.EnsurePortProxyRule(strings.Split("interface portproxy add v4tov4 listenport=80 connectport=8080", " "))
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	assert.Equal(t, 1, fake.starts)
+	assert.Equal(t, []string{
+		"interface ipv4 show compartments",
+		`int ipv4 set int "vEthernet (nat)" for=en`,
+		"interface portproxy add v4tov4 listenport=80 connectport=8080",
+	}, fake.commands)
+}
+
+func TestInteractiveSessionRestartsAfterCrash(t *testing.T) {
+	crashed := false
+	fake := &fakeInteractiveNetsh{
+		handler: func(line string) (string, interactiveAction) {
+			if !crashed {
+				crashed = true
+				return "", interactiveCrash
+			}
+			return "Ok.", interactiveReply
+		},
+	}
+
+	nsh, err := NewWithOptions(Options{Exec: fake, Interactive: true})
+	assert.NoError(t, err)
+	defer nsh.(io.Closer).Close()
+
+	err = nsh.EnableForwarding("Ethernet")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "netsh exited")
+
+	assert.NoError(t, nsh.EnableForwarding("Ethernet"))
+	assert.Equal(t, 2, fake.starts)
+}
+
+func TestInteractiveSessionTimeout(t *testing.T) {
+	hung := false
+	fake := &fakeInteractiveNetsh{
+		handler: func(line string) (string, interactiveAction) {
+			if !hung {
+				hung = true
+				return "", interactiveHang
+			}
+			return "Ok.", interactiveReply
+		},
+	}
+
+	nsh, err := NewWithOptions(Options{Exec: fake, Interactive: true, CommandTimeout: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	defer nsh.(io.Closer).Close()
+
+	err = nsh.EnableForwarding("Ethernet")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+
+	assert.NoError(t, nsh.EnableForwarding("Ethernet"))
+	assert.Equal(t, 2, fake.starts)
+}
+
+func TestInteractiveSessionQuoting(t *testing.T) {
+	fake := &fakeInteractiveNetsh{
+		handler: func(line string) (string, interactiveAction) {
+			return "Ok.", interactiveReply
+		},
+	}
+
+	nsh, err := NewWithOptions(Options{Exec: fake, Interactive: true})
+	assert.NoError(t, err)
+	defer nsh.(io.Closer).Close()
+
+	assert.NoError(t, nsh.AddIPAddress("vEthernet (nat)", &net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)}))
+
+	// a line break would run the rest of the name as a second command
+	err = nsh.EnableForwarding("Ethernet\r\nreset")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid interface")
+	err = nsh.EnableForwarding(`Ethernet" for=dis "`)
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{
+		`interface ipv4 add address name="vEthernet (nat)" address=10.0.0.5 mask=255.255.255.0`,
+	}, fake.commands)
+}
+
+func TestStripPrompt(t *testing.T) {
+	assert.Equal(t, "show", stripPrompt("netsh>netsh>show"))
+}
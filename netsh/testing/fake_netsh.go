@@ -17,6 +17,8 @@ limitations under the License.
 package testing
 
 import (
+	"net"
+
 	netsh "github.com/rakelkar/gonetsh/netsh"
 )
 
@@ -47,6 +49,11 @@ func (*FakeNetsh) DeletePortProxyRule(args []string) error {
 	return nil
 }
 
+// GetPortProxyRules lists the portproxy rules on the host
+func (*FakeNetsh) GetPortProxyRules() ([]netsh.PortProxyRule, error) {
+	return nil, nil
+}
+
 // DeleteIPAddress checks if the specified IP address is present and, if so, deletes it.
 func (*FakeNetsh) DeleteIPAddress(args []string) error {
 	// Do Nothing
@@ -80,9 +87,104 @@ func (*FakeNetsh) EnableForwarding(iface string) error {
 	return nil
 }
 
+// Disable forwarding on the interface (name or index)
+func (*FakeNetsh) DisableForwarding(iface string) error {
+	return nil
+}
+
+// Get whether forwarding is enabled on the interface (name or index)
+func (*FakeNetsh) GetForwarding(iface string) (bool, error) {
+	return false, nil
+}
+
+// Get every IPv4 address of the interface
+func (*FakeNetsh) GetIPAddresses(iface string) ([]*net.IPNet, error) {
+	return nil, nil
+}
+
+// Add a static IPv4 address to the interface
+func (*FakeNetsh) AddIPAddress(iface string, address *net.IPNet) error {
+	return nil
+}
+
+// Remove a static IPv4 address from the interface
+func (*FakeNetsh) RemoveIPAddress(iface string, ip net.IP) error {
+	return nil
+}
+
 // Set the DNS server for interface
 func (*FakeNetsh) SetDNSServer(iface string, dns string) error {
 	return nil
 }
 
+// Get the DNS client configuration of an interface
+func (*FakeNetsh) GetDNSClientConfig(iface string) (netsh.DNSClientConfig, error) {
+	return netsh.DNSClientConfig{Interface: iface}, nil
+}
+
+// Set the ordered list of DNS servers for interface, the first one is the primary
+func (*FakeNetsh) SetDNSServers(iface string, servers []net.IP) error {
+	return nil
+}
+
+// Revert the DNS servers of interface to the ones provided by DHCP
+func (*FakeNetsh) ResetDNSToDHCP(iface string) error {
+	return nil
+}
+
+// Set the connection-specific DNS suffix of interface
+func (*FakeNetsh) SetDNSSuffix(iface string, suffix string) error {
+	return nil
+}
+
+// Set the global DNS suffix search list
+func (*FakeNetsh) SetSuffixSearchList(suffixes []string) error {
+	return nil
+}
+
+// Set whether interface registers its address in DNS, and whether it uses its connection-specific suffix to do so
+func (*FakeNetsh) SetDNSRegistration(iface string, register bool, useSuffix bool) error {
+	return nil
+}
+
+// Get the network compartments on the host
+func (*FakeNetsh) GetCompartments() ([]netsh.Compartment, error) {
+	return []netsh.Compartment{{ID: netsh.DefaultCompartmentID, Description: "Default Compartment"}}, nil
+}
+
+// Get an Interface whose interface commands target the given compartment
+func (f *FakeNetsh) InCompartment(id int) netsh.Interface {
+	return f
+}
+
+// Get the firewall rules with the given name, or every rule for "all"
+func (*FakeNetsh) GetFirewallRules(name string) ([]netsh.FirewallRule, error) {
+	return nil, nil
+}
+
+// Add a firewall rule
+func (*FakeNetsh) AddFirewallRule(rule netsh.FirewallRule) error {
+	return nil
+}
+
+// Delete every firewall rule with the given name
+func (*FakeNetsh) DeleteFirewallRule(name string) error {
+	return nil
+}
+
+// Get a Batch that queues changes and runs them as one netsh script
+func (f *FakeNetsh) NewBatch() *netsh.Batch {
+	return netsh.NewBatch(f)
+}
+
+// Get whether changes are recorded instead of run
+func (*FakeNetsh) DryRun() bool {
+	return false
+}
+
+// Run a netsh script with `netsh -f`
+func (*FakeNetsh) RunScript(script string) ([]byte, error) {
+	return nil, nil
+}
+
 var _ = netsh.Interface(&FakeNetsh{})
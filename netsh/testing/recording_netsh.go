@@ -0,0 +1,208 @@
+package testing
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+// RecordingNetsh keeps the netsh state of a host in memory. Every mutation is logged to Calls in order, a mutation
+// whose log entry is a key of Fail returns that error and leaves the state unchanged.
+type RecordingNetsh struct {
+	*FakeNetsh
+	// Calls is the log of mutations, share it with a fakenetroute.RecordingNetroute to interleave their calls
+	Calls      *[]string
+	Fail       map[string]error
+	Interfaces []netsh.Ipv4Interface
+	Forwarding map[string]bool
+	Addresses  map[string][]*net.IPNet
+	DNS        map[string]netsh.DNSClientConfig
+	PortProxy  []netsh.PortProxyRule
+	Firewall   map[string][]netsh.FirewallRule
+}
+
+// NewRecording returns an empty host that logs its mutations to calls, a nil calls gets a log of its own
+func NewRecording(calls *[]string) *RecordingNetsh {
+	if calls == nil {
+		calls = &[]string{}
+	}
+	return &RecordingNetsh{
+		FakeNetsh:  NewFake(),
+		Calls:      calls,
+		Fail:       map[string]error{},
+		Forwarding: map[string]bool{},
+		Addresses:  map[string][]*net.IPNet{},
+		DNS:        map[string]netsh.DNSClientConfig{},
+		Firewall:   map[string][]netsh.FirewallRule{},
+	}
+}
+
+// call logs a mutation and returns the error it is set to fail with
+func (f *RecordingNetsh) call(format string, args ...interface{}) error {
+	call := fmt.Sprintf(format, args...)
+	*f.Calls = append(*f.Calls, call)
+	return f.Fail[call]
+}
+
+func (f *RecordingNetsh) GetInterfaces() ([]netsh.Ipv4Interface, error) {
+	return f.Interfaces, nil
+}
+
+func (f *RecordingNetsh) GetInterfaceByName(name string) (netsh.Ipv4Interface, error) {
+	for _, iface := range f.Interfaces {
+		if iface.Name == name {
+			return iface, nil
+		}
+	}
+	return netsh.Ipv4Interface{}, fmt.Errorf("Interface not found: %v", name)
+}
+
+func (f *RecordingNetsh) GetForwarding(iface string) (bool, error) {
+	return f.Forwarding[iface], nil
+}
+
+func (f *RecordingNetsh) EnableForwarding(iface string) error {
+	if err := f.call("enable forwarding %v", iface); err != nil {
+		return err
+	}
+	f.Forwarding[iface] = true
+	return nil
+}
+
+func (f *RecordingNetsh) DisableForwarding(iface string) error {
+	if err := f.call("disable forwarding %v", iface); err != nil {
+		return err
+	}
+	f.Forwarding[iface] = false
+	return nil
+}
+
+func (f *RecordingNetsh) GetIPAddresses(iface string) ([]*net.IPNet, error) {
+	return f.Addresses[iface], nil
+}
+
+func (f *RecordingNetsh) AddIPAddress(iface string, address *net.IPNet) error {
+	if err := f.call("add address %v %v", iface, address); err != nil {
+		return err
+	}
+	f.Addresses[iface] = append(f.Addresses[iface], address)
+	return nil
+}
+
+func (f *RecordingNetsh) RemoveIPAddress(iface string, ip net.IP) error {
+	if err := f.call("remove address %v %v", iface, ip); err != nil {
+		return err
+	}
+	var kept []*net.IPNet
+	for _, address := range f.Addresses[iface] {
+		if !address.IP.Equal(ip) {
+			kept = append(kept, address)
+		}
+	}
+	f.Addresses[iface] = kept
+	return nil
+}
+
+func (f *RecordingNetsh) GetDNSClientConfig(iface string) (netsh.DNSClientConfig, error) {
+	config := f.DNS[iface]
+	config.Interface = iface
+	return config, nil
+}
+
+func (f *RecordingNetsh) SetDNSServers(iface string, servers []net.IP) error {
+	if err := f.call("set dns %v %v", iface, servers); err != nil {
+		return err
+	}
+	f.DNS[iface] = netsh.DNSClientConfig{Interface: iface, Servers: servers}
+	return nil
+}
+
+func (f *RecordingNetsh) ResetDNSToDHCP(iface string) error {
+	if err := f.call("reset dns %v", iface); err != nil {
+		return err
+	}
+	f.DNS[iface] = netsh.DNSClientConfig{Interface: iface, Dhcp: true}
+	return nil
+}
+
+func (f *RecordingNetsh) GetPortProxyRules() ([]netsh.PortProxyRule, error) {
+	return f.PortProxy, nil
+}
+
+func (f *RecordingNetsh) InCompartment(id int) netsh.Interface {
+	return f
+}
+
+func (f *RecordingNetsh) NewBatch() *netsh.Batch {
+	return netsh.NewBatch(f)
+}
+
+// RunScript logs every line of a batch script and applies its portproxy commands. Like netsh -f it carries on
+// past a failing line, which is left unapplied.
+func (f *RecordingNetsh) RunScript(script string) ([]byte, error) {
+	for _, line := range strings.Split(strings.TrimSpace(script), "\r\n") {
+		if err := f.call("%v", line); err != nil {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "interface" || fields[1] != "portproxy" {
+			continue
+		}
+		values := map[string]string{}
+		for _, field := range fields[4:] {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) == 2 {
+				values[parts[0]] = parts[1]
+			}
+		}
+		listenAddress := values["listenaddress"]
+		if listenAddress == "" {
+			listenAddress = "*"
+		}
+		listenPort, _ := strconv.Atoi(values["listenport"])
+
+		var kept []netsh.PortProxyRule
+		for _, rule := range f.PortProxy {
+			if rule.Protocol != fields[3] || rule.ListenAddress != listenAddress || rule.ListenPort != listenPort {
+				kept = append(kept, rule)
+			}
+		}
+		f.PortProxy = kept
+		if fields[2] == "add" {
+			connectPort, _ := strconv.Atoi(values["connectport"])
+			f.PortProxy = append(f.PortProxy, netsh.PortProxyRule{
+				Protocol:       fields[3],
+				ListenAddress:  listenAddress,
+				ListenPort:     listenPort,
+				ConnectAddress: values["connectaddress"],
+				ConnectPort:    connectPort,
+			})
+		}
+	}
+	return nil, nil
+}
+
+func (f *RecordingNetsh) GetFirewallRules(name string) ([]netsh.FirewallRule, error) {
+	return f.Firewall[name], nil
+}
+
+func (f *RecordingNetsh) AddFirewallRule(rule netsh.FirewallRule) error {
+	if err := f.call("add firewall %v", rule.Name); err != nil {
+		return err
+	}
+	f.Firewall[rule.Name] = append(f.Firewall[rule.Name], rule)
+	return nil
+}
+
+func (f *RecordingNetsh) DeleteFirewallRule(name string) error {
+	if err := f.call("delete firewall %v", name); err != nil {
+		return err
+	}
+	delete(f.Firewall, name)
+	return nil
+}
+
+var _ = netsh.Interface(&RecordingNetsh{})
@@ -0,0 +1,28 @@
+package observer
+
+import (
+	"github.com/go-logr/logr"
+)
+
+// Logr returns an Observer that logs successful commands at verbosity level and failed commands as errors
+func Logr(logger logr.Logger, level int) Observer {
+	return Func(func(event Event) {
+		keysAndValues := []interface{}{
+			"package", event.Package,
+			"program", event.Program,
+			"args", event.Args,
+			"duration", event.Duration,
+			"exitStatus", event.ExitStatus,
+			"output", event.Output,
+		}
+		if event.Input != "" {
+			keysAndValues = append(keysAndValues, "input", event.Input)
+		}
+
+		if event.Err != nil {
+			logger.Error(event.Err, "command failed", keysAndValues...)
+			return
+		}
+		logger.V(level).Info("command finished", keysAndValues...)
+	})
+}
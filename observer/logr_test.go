@@ -0,0 +1,28 @@
+package observer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogr(t *testing.T) {
+	var lines []string
+	logger := funcr.NewJSON(func(obj string) { lines = append(lines, obj) }, funcr.Options{Verbosity: 2})
+
+	observer := Logr(logger, 2)
+	observer.Observe(Event{Package: "netsh", Program: "netsh", Args: []string{"interface", "ipv4", "show", "config"}, Duration: time.Millisecond, Output: "ok"})
+	observer.Observe(Event{Package: "netsh", Program: "netsh", Args: []string{"-f", "script.txt"}, Input: "bogus\r\n", ExitStatus: 1, Err: errors.New("exit status 1")})
+
+	assert.Equal(t, 2, len(lines))
+	assert.JSONEq(t, `{"logger":"","level":2,"msg":"command finished","package":"netsh","program":"netsh","args":["interface","ipv4","show","config"],"duration":"1ms","exitStatus":0,"output":"ok"}`, lines[0])
+	assert.JSONEq(t, `{"logger":"","msg":"command failed","error":"exit status 1","package":"netsh","program":"netsh","args":["-f","script.txt"],"duration":"0s","exitStatus":1,"output":"","input":"bogus\r\n"}`, lines[1])
+
+	// successful commands are dropped below the logger's verbosity
+	lines = nil
+	Logr(logger, 3).Observe(Event{Package: "netroute", Program: "powershell"})
+	assert.Empty(t, lines)
+}
@@ -0,0 +1,194 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	utilexec "k8s.io/utils/exec"
+)
+
+const (
+	// MaxOutput is the number of bytes of output kept in an Event, the rest is cut off
+	MaxOutput int = 4096
+)
+
+// Event describes one netsh or powershell command that has finished
+type Event struct {
+	// Package is the package that ran the command, netsh or netroute
+	Package string
+	// Program is the executable, or powershell for a script run in a netroute powershell session
+	Program string
+	// Args are the arguments of the program, or the script for a powershell session
+	Args []string
+	// Input is a script the command read from a file, e.g. the one run by netsh RunScript
+	Input    string
+	Start    time.Time
+	Duration time.Duration
+	// ExitStatus is zero on success, the exit status of the process if it failed with one, and -1 otherwise
+	ExitStatus int
+	// Output is the combined output of the command, truncated to MaxOutput bytes
+	Output string
+	Err    error
+}
+
+// Observer is notified of every command that ran. Implementations must be goroutine-safe and should not block.
+type Observer interface {
+	Observe(event Event)
+}
+
+// Func adapts a function to an Observer
+type Func func(event Event)
+
+// Observe is part of Observer.
+func (f Func) Observe(event Event) {
+	f(event)
+}
+
+// Multi returns an Observer that passes every event to each of observers, nil ones are skipped
+func Multi(observers ...Observer) Observer {
+	var all []Observer
+	for _, o := range observers {
+		if o != nil {
+			all = append(all, o)
+		}
+	}
+	return Func(func(event Event) {
+		for _, o := range all {
+			o.Observe(event)
+		}
+	})
+}
+
+// Command times a command for an Observer, it is nil if there is no observer
+type Command struct {
+	observer Observer
+	event    Event
+}
+
+// Start starts timing the command described by event, call Done once it finished
+func Start(observer Observer, event Event) *Command {
+	if observer == nil {
+		return nil
+	}
+	event.Start = time.Now()
+	return &Command{
+		observer: observer,
+		event:    event,
+	}
+}
+
+// Done reports the command with its output and error
+func (c *Command) Done(output []byte, err error) {
+	if c == nil {
+		return
+	}
+
+	event := c.event
+	event.Duration = time.Since(event.Start)
+	event.Output = truncate(string(output))
+	event.Err = err
+	if err != nil {
+		event.ExitStatus = -1
+		if ee, ok := err.(utilexec.ExitError); ok && ee.Exited() {
+			event.ExitStatus = ee.ExitStatus()
+		}
+	}
+	c.observer.Observe(event)
+}
+
+func truncate(output string) string {
+	if len(output) <= MaxOutput {
+		return output
+	}
+	return fmt.Sprintf("%v... (%v bytes truncated)", output[:MaxOutput], len(output)-MaxOutput)
+}
+
+// Redactor rewrites a string that may contain sensitive data
+type Redactor func(s string) string
+
+// RedactMatching returns a Redactor that replaces every match of pattern with [REDACTED]
+func RedactMatching(pattern *regexp.Regexp) Redactor {
+	return func(s string) string {
+		return pattern.ReplaceAllString(s, "[REDACTED]")
+	}
+}
+
+// Redacting returns an Observer that passes events to next after running redact over their arguments, input,
+// output and error message
+func Redacting(next Observer, redact Redactor) Observer {
+	return Func(func(event Event) {
+		args := make([]string, len(event.Args))
+		for i, arg := range event.Args {
+			args[i] = redact(arg)
+		}
+		event.Args = args
+		event.Input = redact(event.Input)
+		event.Output = redact(event.Output)
+		if event.Err != nil {
+			event.Err = errors.New(redact(event.Err.Error()))
+		}
+		next.Observe(event)
+	})
+}
+
+// Exec wraps exec so that every command run to completion through Run, Output or CombinedOutput is reported to
+// observer as part of pkg. Commands driven through Start and Wait are passed through unobserved.
+func Exec(exec utilexec.Interface, pkg string, observer Observer) utilexec.Interface {
+	return &observedExec{
+		Interface: exec,
+		pkg:       pkg,
+		observer:  observer,
+	}
+}
+
+type observedExec struct {
+	utilexec.Interface
+	pkg      string
+	observer Observer
+}
+
+func (e *observedExec) Command(cmd string, args ...string) utilexec.Cmd {
+	return e.wrap(e.Interface.Command(cmd, args...), cmd, args)
+}
+
+func (e *observedExec) CommandContext(ctx context.Context, cmd string, args ...string) utilexec.Cmd {
+	return e.wrap(e.Interface.CommandContext(ctx, cmd, args...), cmd, args)
+}
+
+func (e *observedExec) wrap(cmd utilexec.Cmd, program string, args []string) utilexec.Cmd {
+	return &observedCmd{
+		Cmd:      cmd,
+		observer: e.observer,
+		event:    Event{Package: e.pkg, Program: program, Args: args},
+	}
+}
+
+type observedCmd struct {
+	utilexec.Cmd
+	observer Observer
+	event    Event
+}
+
+func (c *observedCmd) Run() error {
+	command := Start(c.observer, c.event)
+	err := c.Cmd.Run()
+	command.Done(nil, err)
+	return err
+}
+
+func (c *observedCmd) CombinedOutput() ([]byte, error) {
+	command := Start(c.observer, c.event)
+	output, err := c.Cmd.CombinedOutput()
+	command.Done(output, err)
+	return output, err
+}
+
+func (c *observedCmd) Output() ([]byte, error) {
+	command := Start(c.observer, c.event)
+	output, err := c.Cmd.Output()
+	command.Done(output, err)
+	return output, err
+}
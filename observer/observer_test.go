@@ -0,0 +1,101 @@
+package observer
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Observe(event Event) {
+	r.events = append(r.events, event)
+}
+
+func TestCommand(t *testing.T) {
+	// a nil observer costs nothing
+	Start(nil, Event{}).Done(nil, errors.New("ignored"))
+
+	r := &recorder{}
+	Start(r, Event{Package: "netsh", Program: "netsh", Args: []string{"interface", "ipv4", "show", "config"}}).Done([]byte("ok"), nil)
+	Start(r, Event{Package: "netsh", Program: "netsh"}).Done([]byte(strings.Repeat("x", MaxOutput+10)), &fakeexec.FakeExitError{Status: 2})
+	Start(r, Event{Package: "netroute", Program: "powershell"}).Done(nil, errors.New("powershell session has exited"))
+
+	assert.Equal(t, 3, len(r.events))
+	assert.Equal(t, []string{"interface", "ipv4", "show", "config"}, r.events[0].Args)
+	assert.Equal(t, "ok", r.events[0].Output)
+	assert.Equal(t, 0, r.events[0].ExitStatus)
+	assert.False(t, r.events[0].Start.IsZero())
+	assert.True(t, r.events[0].Duration >= 0)
+
+	assert.Equal(t, 2, r.events[1].ExitStatus)
+	assert.Equal(t, strings.Repeat("x", MaxOutput)+"... (10 bytes truncated)", r.events[1].Output)
+
+	assert.Equal(t, -1, r.events[2].ExitStatus)
+	assert.EqualError(t, r.events[2].Err, "powershell session has exited")
+}
+
+func TestRedacting(t *testing.T) {
+	r := &recorder{}
+	redacting := Redacting(r, RedactMatching(regexp.MustCompile(`secret\S*`)))
+
+	redacting.Observe(Event{
+		Args:   []string{"set", "password=secret123"},
+		Input:  "add secret456\r\n",
+		Output: "rejected secret789",
+		Err:    errors.New("failed running secret123"),
+	})
+
+	assert.Equal(t, []string{"set", "password=[REDACTED]"}, r.events[0].Args)
+	assert.Equal(t, "add [REDACTED]\r\n", r.events[0].Input)
+	assert.Equal(t, "rejected [REDACTED]", r.events[0].Output)
+	assert.EqualError(t, r.events[0].Err, "failed running [REDACTED]")
+}
+
+func TestExec(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return []byte("Ok."), nil, nil },
+		},
+		RunScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, &fakeexec.FakeExitError{Status: 1} },
+		},
+	}
+	fakeExec := fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			func(cmd string, args ...string) exec.Cmd { return fakeexec.InitFakeCmd(&fakeCmd, cmd, args...) },
+			func(cmd string, args ...string) exec.Cmd { return fakeexec.InitFakeCmd(&fakeCmd, cmd, args...) },
+		},
+	}
+
+	r := &recorder{}
+	observed := Exec(&fakeExec, "netsh", r)
+
+	output, err := observed.Command("netsh", "interface", "portproxy", "show", "all").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "Ok.", string(output))
+	assert.Error(t, observed.Command("netsh", "-f", "script.txt").Run())
+
+	assert.Equal(t, 2, len(r.events))
+	assert.Equal(t, "netsh", r.events[0].Package)
+	assert.Equal(t, "netsh", r.events[0].Program)
+	assert.Equal(t, []string{"interface", "portproxy", "show", "all"}, r.events[0].Args)
+	assert.Equal(t, "Ok.", r.events[0].Output)
+	assert.Equal(t, []string{"-f", "script.txt"}, r.events[1].Args)
+	assert.Equal(t, 1, r.events[1].ExitStatus)
+}
+
+func TestMulti(t *testing.T) {
+	first, second := &recorder{}, &recorder{}
+	Multi(first, nil, second).Observe(Event{Program: "netsh"})
+
+	assert.Equal(t, []Event{{Program: "netsh"}}, first.events)
+	assert.Equal(t, []Event{{Program: "netsh"}}, second.events)
+}
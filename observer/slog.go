@@ -0,0 +1,33 @@
+//go:build go1.21
+// +build go1.21
+
+package observer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Slog returns an Observer that logs successful commands at level and failed commands at slog.LevelError
+func Slog(logger *slog.Logger, level slog.Level) Observer {
+	return Func(func(event Event) {
+		attrs := []slog.Attr{
+			slog.String("package", event.Package),
+			slog.String("program", event.Program),
+			slog.Any("args", event.Args),
+			slog.Duration("duration", event.Duration),
+			slog.Int("exitStatus", event.ExitStatus),
+			slog.String("output", event.Output),
+		}
+		if event.Input != "" {
+			attrs = append(attrs, slog.String("input", event.Input))
+		}
+
+		if event.Err != nil {
+			attrs = append(attrs, slog.String("error", event.Err.Error()))
+			logger.LogAttrs(context.Background(), slog.LevelError, "command failed", attrs...)
+			return
+		}
+		logger.LogAttrs(context.Background(), level, "command finished", attrs...)
+	})
+}
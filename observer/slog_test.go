@@ -0,0 +1,37 @@
+//go:build go1.21
+// +build go1.21
+
+package observer
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlog(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	observer := Slog(logger, slog.LevelDebug)
+	observer.Observe(Event{Package: "netroute", Program: "powershell", Args: []string{"get-netroute -erroraction Ignore"}, Duration: time.Millisecond, Output: "ok"})
+	observer.Observe(Event{Package: "netroute", Program: "powershell", Args: []string{"clear-dnsclientcache"}, ExitStatus: -1, Err: errors.New("powershell session has exited")})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.JSONEq(t, `{"level":"DEBUG","msg":"command finished","package":"netroute","program":"powershell","args":["get-netroute -erroraction Ignore"],"duration":1000000,"exitStatus":0,"output":"ok"}`, lines[0])
+	assert.JSONEq(t, `{"level":"ERROR","msg":"command failed","package":"netroute","program":"powershell","args":["clear-dnsclientcache"],"duration":0,"exitStatus":-1,"output":"","error":"powershell session has exited"}`, lines[1])
+}
@@ -0,0 +1,260 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	utilexec "k8s.io/utils/exec"
+)
+
+// Exec returns a utilexec.Interface that runs every command on the remote host through transport, e.g. to pass as
+// netsh's Options.Exec. The commands run in the home directory and environment of the remote login, SetDir and
+// SetEnv are ignored.
+func Exec(transport Transport) utilexec.Interface {
+	return &executor{transport: transport}
+}
+
+type executor struct {
+	transport Transport
+}
+
+// Remote marks commands that cannot read local files, netsh pipes scripts to them instead
+func (e *executor) Remote() {}
+
+func (e *executor) Command(cmd string, args ...string) utilexec.Cmd {
+	return e.CommandContext(context.Background(), cmd, args...)
+}
+
+func (e *executor) CommandContext(ctx context.Context, cmd string, args ...string) utilexec.Cmd {
+	return &command{
+		transport: e.transport,
+		ctx:       ctx,
+		line:      CommandLine(cmd, args...),
+	}
+}
+
+// LookPath returns file as is, it is resolved by the remote shell
+func (e *executor) LookPath(file string) (string, error) {
+	return file, nil
+}
+
+// command runs a command line in its own Session
+type command struct {
+	transport Transport
+	ctx       context.Context
+	line      string
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// pipes were returned by StdoutPipe and StderrPipe, they are closed once the output has been copied to them
+	pipes []*io.PipeWriter
+
+	mu      sync.Mutex
+	session Session
+	copies  sync.WaitGroup
+	done    chan struct{}
+}
+
+var _ utilexec.Cmd = &command{}
+
+func (c *command) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+func (c *command) CombinedOutput() ([]byte, error) {
+	var output lockedBuffer
+	c.stdout = &output
+	c.stderr = &output
+	err := c.Run()
+	return output.Bytes(), err
+}
+
+func (c *command) Output() ([]byte, error) {
+	var output bytes.Buffer
+	c.stdout = &output
+	err := c.Run()
+	return output.Bytes(), err
+}
+
+func (c *command) SetDir(dir string) {}
+
+func (c *command) SetStdin(in io.Reader) {
+	c.stdin = in
+}
+
+func (c *command) SetStdout(out io.Writer) {
+	c.stdout = out
+}
+
+func (c *command) SetStderr(out io.Writer) {
+	c.stderr = out
+}
+
+func (c *command) SetEnv(env []string) {}
+
+func (c *command) StdoutPipe() (io.ReadCloser, error) {
+	reader, writer := io.Pipe()
+	c.stdout = writer
+	c.pipes = append(c.pipes, writer)
+	return reader, nil
+}
+
+func (c *command) StderrPipe() (io.ReadCloser, error) {
+	reader, writer := io.Pipe()
+	c.stderr = writer
+	c.pipes = append(c.pipes, writer)
+	return reader, nil
+}
+
+func (c *command) Start() error {
+	session, err := c.transport.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open remote session: %v", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return fmt.Errorf("failed to open remote stdin: %v", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return fmt.Errorf("failed to open remote stdout: %v", err)
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		session.Close()
+		return fmt.Errorf("failed to open remote stderr: %v", err)
+	}
+	if err := session.Start(c.line); err != nil {
+		session.Close()
+		return fmt.Errorf("failed to start %v remotely: %v", c.line, err)
+	}
+
+	c.mu.Lock()
+	c.session = session
+	c.done = make(chan struct{})
+	c.mu.Unlock()
+
+	go func() {
+		if c.stdin != nil {
+			io.Copy(stdin, c.stdin)
+		}
+		stdin.Close()
+	}()
+	c.copy(c.stdout, stdout)
+	c.copy(c.stderr, stderr)
+
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			session.Close()
+		case <-c.done:
+		}
+	}()
+	return nil
+}
+
+// copy drains the remote stream into w, discarding it if w is nil
+func (c *command) copy(w io.Writer, r io.Reader) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	c.copies.Add(1)
+	go func() {
+		defer c.copies.Done()
+		io.Copy(w, r)
+		// like the pipes of a local process, ours reach EOF when the command exits
+		for _, pipe := range c.pipes {
+			if w == pipe {
+				pipe.Close()
+			}
+		}
+	}()
+}
+
+func (c *command) Wait() error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return fmt.Errorf("remote command %v was not started", c.line)
+	}
+
+	err := session.Wait()
+	c.copies.Wait()
+	close(c.done)
+	session.Close()
+
+	if err == nil {
+		return nil
+	}
+	if c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	if status, ok := err.(interface{ ExitStatus() int }); ok {
+		return &exitError{err: err, status: status.ExitStatus()}
+	}
+	return err
+}
+
+// Stop closes the session, the remote side terminates the command
+func (c *command) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session != nil {
+		c.session.Close()
+	}
+}
+
+// exitError reports the exit status of a remote command like a local one
+type exitError struct {
+	err    error
+	status int
+}
+
+var _ utilexec.ExitError = &exitError{}
+
+func (e *exitError) String() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Exited() bool {
+	return true
+}
+
+func (e *exitError) ExitStatus() int {
+	return e.status
+}
+
+// lockedBuffer is written by the stdout and stderr copies at once
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
@@ -0,0 +1,82 @@
+package remote_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rakelkar/gonetsh/remote"
+	fakeremote "github.com/rakelkar/gonetsh/remote/testing"
+	"github.com/stretchr/testify/assert"
+	utilexec "k8s.io/utils/exec"
+)
+
+func TestExecCombinedOutput(t *testing.T) {
+	transport := fakeremote.NewFakeTransport(func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+		io.WriteString(stdout, "Forwarding : enabled\r\n")
+		return 0
+	})
+
+	output, err := remote.Exec(transport).Command("netsh", "interface", "ipv4", "show", "interface", "vEthernet (nat)").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "Forwarding : enabled\r\n", string(output))
+	assert.Equal(t, []string{`netsh interface ipv4 show interface ^"vEthernet ^(nat^)^"`}, transport.Commands())
+	assert.Equal(t, 0, transport.OpenSessions())
+}
+
+func TestExecExitStatus(t *testing.T) {
+	transport := fakeremote.NewFakeTransport(func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+		io.WriteString(stdout, "Element not found.\r\n")
+		io.WriteString(stderr, "oops\r\n")
+		return 1
+	})
+
+	output, err := remote.Exec(transport).Command("netsh", "interface", "ipv4", "delete", "address").Output()
+	assert.Equal(t, "Element not found.\r\n", string(output))
+	exitErr, ok := err.(utilexec.ExitError)
+	assert.True(t, ok)
+	assert.True(t, exitErr.Exited())
+	assert.Equal(t, 1, exitErr.ExitStatus())
+}
+
+func TestExecStdin(t *testing.T) {
+	transport := fakeremote.NewFakeTransport(func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+		io.Copy(stdout, stdin)
+		return 0
+	})
+
+	cmd := remote.Exec(transport).Command("netsh")
+	cmd.SetStdin(strings.NewReader("interface ipv4 show interfaces\r\n"))
+	stdout, err := cmd.StdoutPipe()
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Start())
+	output, err := ioutil.ReadAll(stdout)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Wait())
+	assert.Equal(t, "interface ipv4 show interfaces\r\n", string(output))
+}
+
+func TestExecContext(t *testing.T) {
+	transport := fakeremote.NewFakeTransport(func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+		// hangs until the session is closed
+		ioutil.ReadAll(stdin)
+		return 1
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	cmd := remote.Exec(transport).CommandContext(ctx, "netsh")
+	cmd.SetStdin(blockingReader{})
+	assert.Equal(t, context.DeadlineExceeded, cmd.Run())
+	assert.Equal(t, 0, transport.OpenSessions())
+}
+
+// blockingReader never returns, like an idle interactive stdin
+type blockingReader struct{}
+
+func (blockingReader) Read(p []byte) (int, error) {
+	select {}
+}
@@ -0,0 +1,95 @@
+package remote
+
+import (
+	"io"
+	"strings"
+)
+
+// Session runs one command on the remote host. *ssh.Session of golang.org/x/crypto/ssh implements it, clients of
+// other transports such as WinRM can be adapted to it.
+type Session interface {
+	StdinPipe() (io.WriteCloser, error)
+	StdoutPipe() (io.Reader, error)
+	StderrPipe() (io.Reader, error)
+	// Start starts the command line on the remote host
+	Start(cmd string) error
+	Wait() error
+	Close() error
+}
+
+// Transport opens a new Session on the remote host for every command
+type Transport interface {
+	NewSession() (Session, error)
+}
+
+// TransportFunc adapts a function to Transport, e.g. one returning (*ssh.Client).NewSession()
+type TransportFunc func() (Session, error)
+
+// NewSession calls f
+func (f TransportFunc) NewSession() (Session, error) {
+	return f()
+}
+
+// cmdMetacharacters are interpreted by cmd.exe, the default shell of OpenSSH on Windows, before the program sees
+// its command line
+const cmdMetacharacters = `()%!^"<>&|`
+
+// CommandLine renders cmd and args as a command line for cmd.exe. Every argument is quoted so CommandLineToArgvW
+// splits it back into the same args, then every cmd.exe metacharacter, quotes included, is escaped with ^ so that
+// cmd.exe neither runs a second command nor expands %VAR% references. Hosts whose SSH default shell is not cmd.exe
+// would see the carets.
+func CommandLine(cmd string, args ...string) string {
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, QuoteArg(cmd))
+	for _, arg := range args {
+		parts = append(parts, QuoteArg(arg))
+	}
+	return escapeCmd(strings.Join(parts, " "))
+}
+
+// escapeCmd prefixes every cmd.exe metacharacter of line with ^. The escaped quotes keep cmd.exe out of quoted
+// mode, where carets would be taken literally, and a caret within %VAR% makes it name no variable, so it is left
+// as is.
+func escapeCmd(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if strings.IndexByte(cmdMetacharacters, line[i]) >= 0 {
+			b.WriteByte('^')
+		}
+		b.WriteByte(line[i])
+	}
+	return b.String()
+}
+
+// QuoteArg quotes arg for a Windows command line the way os/exec does on Windows
+func QuoteArg(arg string) string {
+	if arg == "" {
+		return `""`
+	}
+	if !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	backslashes := 0
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; c {
+		case '\\':
+			backslashes++
+		case '"':
+			// backslashes before a quote are escaped, and so is the quote
+			b.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+			b.WriteByte(c)
+			backslashes = 0
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+			b.WriteByte(c)
+			backslashes = 0
+		}
+	}
+	// backslashes before the closing quote are escaped
+	b.WriteString(strings.Repeat(`\`, 2*backslashes))
+	b.WriteByte('"')
+	return b.String()
+}
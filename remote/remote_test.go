@@ -0,0 +1,93 @@
+package remote_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rakelkar/gonetsh/remote"
+	"github.com/stretchr/testify/assert"
+)
+
+// remote's tests are in package remote_test because the fake transport of remote/testing imports remote
+
+func TestQuoteArg(t *testing.T) {
+	for arg, quoted := range map[string]string{
+		``:                 `""`,
+		`interface`:        `interface`,
+		`C:\scripts\a.txt`: `C:\scripts\a.txt`,
+		`Local Area`:       `"Local Area"`,
+		`"Ethernet"`:       `"\"Ethernet\""`,
+		`a\"b`:             `"a\\\"b"`,
+		`C:\my dir\`:       `"C:\my dir\\"`,
+	} {
+		assert.Equal(t, quoted, remote.QuoteArg(arg), arg)
+	}
+}
+
+// parseCmd reads line the way cmd.exe runs a command, failing on any metacharacter that is not escaped with ^
+func parseCmd(t *testing.T, line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '^' && i+1 < len(line):
+			i++
+			b.WriteByte(line[i])
+		case strings.IndexByte(`()%!^"<>&|`, c) >= 0:
+			t.Fatalf("cmd.exe interprets %q at %v of %v", c, i, line)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// splitArgs splits a command line like CommandLineToArgvW
+func splitArgs(line string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted, backslashes := false, false, 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\':
+			backslashes++
+			inArg = true
+			continue
+		case c == '"':
+			arg.WriteString(strings.Repeat(`\`, backslashes/2))
+			if backslashes%2 == 1 {
+				arg.WriteByte('"')
+			} else {
+				quoted = !quoted
+			}
+			inArg = true
+		case (c == ' ' || c == '\t') && !quoted:
+			arg.WriteString(strings.Repeat(`\`, backslashes))
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+			inArg = false
+		default:
+			arg.WriteString(strings.Repeat(`\`, backslashes))
+			arg.WriteByte(c)
+			inArg = true
+		}
+		backslashes = 0
+	}
+	arg.WriteString(strings.Repeat(`\`, backslashes))
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+func TestCommandLine(t *testing.T) {
+	assert.Equal(t, `netsh interface ipv4 show interface ^"vEthernet ^(nat^)^"`, remote.CommandLine("netsh", "interface", "ipv4", "show", "interface", "vEthernet (nat)"))
+	assert.Equal(t, `netsh interface ipv4 show interface a^&calc`, remote.CommandLine("netsh", "interface", "ipv4", "show", "interface", "a&calc"))
+
+	for _, alias := range []string{"a&calc", "a | calc", "a>C:\\out", "%PATH%", "a^b", "x\" & calc & \"", `C:\my dir\`, "(!x!)"} {
+		args := []string{"netsh", "interface", "ipv4", "show", "interface", alias}
+		assert.Equal(t, args, splitArgs(parseCmd(t, remote.CommandLine(args[0], args[1:]...))), alias)
+	}
+}
@@ -0,0 +1,61 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+
+	psbe "github.com/antoninbas/go-powershell/backend"
+)
+
+// Starter returns a powershell backend that starts every powershell process in a new Session of transport, pass
+// it as netroute's Options.Starter. Unlike psbe.SSH, which can start a single process, netroute can restart the
+// sessions of its pool.
+func Starter(transport Transport) psbe.Starter {
+	return &starter{transport: transport}
+}
+
+type starter struct {
+	transport Transport
+}
+
+func (s *starter) StartProcess(cmd string, args ...string) (psbe.Waiter, io.Writer, io.Reader, io.Reader, error) {
+	session, err := s.transport.NewSession()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to open remote session: %v", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, nil, nil, nil, fmt.Errorf("failed to open remote stdin: %v", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, nil, nil, nil, fmt.Errorf("failed to open remote stdout: %v", err)
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		session.Close()
+		return nil, nil, nil, nil, fmt.Errorf("failed to open remote stderr: %v", err)
+	}
+
+	line := CommandLine(cmd, args...)
+	if err := session.Start(line); err != nil {
+		session.Close()
+		return nil, nil, nil, nil, fmt.Errorf("failed to start %v remotely: %v", line, err)
+	}
+
+	return &waiter{session: session}, stdin, stdout, stderr, nil
+}
+
+// waiter closes the session once powershell has exited
+type waiter struct {
+	session Session
+}
+
+func (w *waiter) Wait() error {
+	err := w.session.Wait()
+	w.session.Close()
+	return err
+}
@@ -0,0 +1,39 @@
+package remote_test
+
+import (
+	"bufio"
+	"io"
+	"testing"
+
+	"github.com/rakelkar/gonetsh/remote"
+	fakeremote "github.com/rakelkar/gonetsh/remote/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStarter(t *testing.T) {
+	transport := fakeremote.NewFakeTransport(func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+		// echoes every line until stdin is closed, like powershell reading commands
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			io.WriteString(stdout, scanner.Text()+"\n")
+		}
+		return 0
+	})
+	starter := remote.Starter(transport)
+
+	for i := 0; i < 2; i++ {
+		waiter, stdin, stdout, _, err := starter.StartProcess("pwsh", "-NoExit", "-Command", "-")
+		assert.NoError(t, err)
+
+		io.WriteString(stdin, "get-netroute\n")
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "get-netroute\n", line)
+
+		stdin.(io.Closer).Close()
+		assert.NoError(t, waiter.Wait())
+	}
+
+	assert.Equal(t, []string{"pwsh -NoExit -Command -", "pwsh -NoExit -Command -"}, transport.Commands())
+	assert.Equal(t, 0, transport.OpenSessions())
+}
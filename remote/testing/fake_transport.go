@@ -0,0 +1,137 @@
+package testing
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rakelkar/gonetsh/remote"
+)
+
+// Handler plays the remote host, it runs the command line with the session's streams and returns its exit status
+type Handler func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// FakeTransport runs every command in process with Handler
+type FakeTransport struct {
+	Handler Handler
+
+	mu       sync.Mutex
+	commands []string
+	open     int
+}
+
+// NewFakeTransport returns a FakeTransport that runs commands with handler
+func NewFakeTransport(handler Handler) *FakeTransport {
+	return &FakeTransport{Handler: handler}
+}
+
+// Commands returns the command lines started so far
+func (f *FakeTransport) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.commands...)
+}
+
+// OpenSessions returns the number of sessions that were not closed yet
+func (f *FakeTransport) OpenSessions() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.open
+}
+
+// NewSession is part of remote.Transport
+func (f *FakeTransport) NewSession() (remote.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.open++
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+	return &fakeSession{
+		transport:    f,
+		stdinReader:  stdinReader,
+		stdinWriter:  stdinWriter,
+		stdoutReader: stdoutReader,
+		stdoutWriter: stdoutWriter,
+		stderrReader: stderrReader,
+		stderrWriter: stderrWriter,
+		done:         make(chan int, 1),
+	}, nil
+}
+
+// ExitError is returned by Wait for a non zero exit status, like ssh.ExitError
+type ExitError struct {
+	Status int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("Process exited with status %v", e.Status)
+}
+
+// ExitStatus returns the exit status of the command
+func (e *ExitError) ExitStatus() int {
+	return e.Status
+}
+
+type fakeSession struct {
+	transport    *FakeTransport
+	stdinReader  *io.PipeReader
+	stdinWriter  *io.PipeWriter
+	stdoutReader *io.PipeReader
+	stdoutWriter *io.PipeWriter
+	stderrReader *io.PipeReader
+	stderrWriter *io.PipeWriter
+	done         chan int
+
+	closeOnce sync.Once
+}
+
+func (s *fakeSession) StdinPipe() (io.WriteCloser, error) {
+	return s.stdinWriter, nil
+}
+
+func (s *fakeSession) StdoutPipe() (io.Reader, error) {
+	return s.stdoutReader, nil
+}
+
+func (s *fakeSession) StderrPipe() (io.Reader, error) {
+	return s.stderrReader, nil
+}
+
+func (s *fakeSession) Start(cmd string) error {
+	s.transport.mu.Lock()
+	s.transport.commands = append(s.transport.commands, cmd)
+	s.transport.mu.Unlock()
+
+	go func() {
+		status := s.transport.Handler(cmd, s.stdinReader, s.stdoutWriter, s.stderrWriter)
+		s.stdoutWriter.Close()
+		s.stderrWriter.Close()
+		s.done <- status
+	}()
+	return nil
+}
+
+func (s *fakeSession) Wait() error {
+	if status := <-s.done; status != 0 {
+		return &ExitError{Status: status}
+	}
+	return nil
+}
+
+// Close ends the command like a dropped connection, its streams fail from then on
+func (s *fakeSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.stdinReader.Close()
+		s.stdoutWriter.Close()
+		s.stderrWriter.Close()
+
+		s.transport.mu.Lock()
+		s.transport.open--
+		s.transport.mu.Unlock()
+	})
+	return nil
+}
@@ -0,0 +1,103 @@
+package retry
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+const (
+	// DefaultInitialBackoff is the wait before the second attempt when the Policy does not set one
+	DefaultInitialBackoff = 200 * time.Millisecond
+	// DefaultMaxBackoff caps the wait between attempts when the Policy does not set one
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// Classifier reports whether an operation that failed with err may succeed if it is run again
+type Classifier func(err error) bool
+
+// Policy retries operations that fail transiently, e.g. right after an adapter was created netsh and powershell
+// report "Element not found." or "The object is in use" for a second or so. A nil Policy runs every operation once.
+type Policy struct {
+	// MaxAttempts is the number of times an operation runs at most, values below 2 disable retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt, it doubles on every further attempt. Defaults to
+	// DefaultInitialBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts, defaults to DefaultMaxBackoff
+	MaxBackoff time.Duration
+	// Jitter randomizes every wait by up to this fraction of it in either direction, 0.2 waits between 80% and 120%
+	// of the backoff. Zero waits exactly the backoff.
+	Jitter float64
+	// Retryable decides which errors are retried, defaults to Transient
+	Retryable Classifier
+	// Clock sleeps between attempts, defaults to the real clock
+	Clock clock.Clock
+}
+
+// transientMessages are the error messages Windows reports while a new adapter or address is still settling
+var transientMessages = []string{
+	"element not found",
+	"object is in use",
+	"object is already in use",
+}
+
+// Transient reports whether err is one of the errors netsh and powershell report while the network configuration
+// is still settling
+func Transient(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, transient := range transientMessages {
+		if strings.Contains(message, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// Do runs operation until it succeeds, fails with an error that is not retryable or has run MaxAttempts times.
+// It returns the error of the last attempt.
+func (p *Policy) Do(operation func() error) error {
+	err := operation()
+	if p == nil {
+		return err
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = Transient
+	}
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+
+	for attempt := 1; attempt < p.MaxAttempts && err != nil && retryable(err); attempt++ {
+		clk.Sleep(p.backoff(attempt, rand.Float64()))
+		err = operation()
+	}
+	return err
+}
+
+// backoff returns the wait after the given failed attempt, r in [0, 1) picks the jitter
+func (p *Policy) backoff(attempt int, r float64) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = DefaultInitialBackoff
+	}
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	backoff := initial
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+
+	return time.Duration(float64(backoff) * (1 + p.Jitter*(2*r-1)))
+}
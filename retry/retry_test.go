@@ -0,0 +1,106 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	fakeclock "k8s.io/utils/clock/testing"
+)
+
+func TestDo(t *testing.T) {
+	start := time.Now()
+	clock := fakeclock.NewFakeClock(start)
+	policy := &Policy{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, Clock: clock}
+
+	attempts := 0
+	err := policy.Do(func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("exit status 1: Element not found.")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 3*time.Second, clock.Since(start))
+}
+
+func TestDoGivesUp(t *testing.T) {
+	start := time.Now()
+	clock := fakeclock.NewFakeClock(start)
+	policy := &Policy{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, Clock: clock}
+
+	attempts := 0
+	err := policy.Do(func() error {
+		attempts++
+		return errors.New("The object is in use")
+	})
+
+	assert.EqualError(t, err, "The object is in use")
+	assert.Equal(t, 4, attempts)
+	// 1s, 2s, then capped at 3s
+	assert.Equal(t, 6*time.Second, clock.Since(start))
+}
+
+func TestDoNotRetryable(t *testing.T) {
+	start := time.Now()
+	clock := fakeclock.NewFakeClock(start)
+	policy := &Policy{MaxAttempts: 4, Clock: clock}
+
+	attempts := 0
+	err := policy.Do(func() error {
+		attempts++
+		return errors.New("Access is denied.")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, time.Duration(0), clock.Since(start))
+}
+
+func TestDoClassifier(t *testing.T) {
+	clock := fakeclock.NewFakeClock(time.Now())
+	denied := errors.New("Access is denied.")
+	policy := &Policy{MaxAttempts: 2, Clock: clock, Retryable: func(err error) bool { return err == denied }}
+
+	attempts := 0
+	assert.NoError(t, policy.Do(func() error {
+		attempts++
+		if attempts == 1 {
+			return denied
+		}
+		return nil
+	}))
+	assert.Equal(t, 2, attempts)
+}
+
+func TestDoNilPolicy(t *testing.T) {
+	var policy *Policy
+
+	attempts := 0
+	err := policy.Do(func() error {
+		attempts++
+		return errors.New("Element not found.")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := &Policy{InitialBackoff: time.Second, Jitter: 0.5}
+
+	assert.Equal(t, 500*time.Millisecond, policy.backoff(1, 0))
+	assert.Equal(t, time.Second, policy.backoff(1, 0.5))
+	assert.Equal(t, 3*time.Second, policy.backoff(2, 1))
+	assert.Equal(t, 2500*time.Millisecond, policy.backoff(10, 0))
+}
+
+func TestTransient(t *testing.T) {
+	assert.True(t, Transient(errors.New("exit status 1: Element not found.")))
+	assert.True(t, Transient(errors.New("stderr non empty: The object is already in use.")))
+	assert.False(t, Transient(errors.New("The object already exists.")))
+}
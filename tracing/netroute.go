@@ -0,0 +1,220 @@
+package tracing
+
+import (
+	"context"
+	"net"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	"github.com/rakelkar/gonetsh/observer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// observableNetroute is implemented by the netroute shell, it reports the scripts of a single call
+type observableNetroute interface {
+	WithObserver(obs observer.Observer) netroute.Interface
+}
+
+// tracedNetroute creates a span for every call to the wrapped Interface
+type tracedNetroute struct {
+	netroute.Interface
+	tracer *Tracer
+	ctx    context.Context
+}
+
+// Netroute returns nr traced by t, its spans are children of the span in ctx. ctx is fixed for the life of the
+// returned Interface, rebind a long lived one to the span of each request with NetrouteWithContext. The script
+// spans need nr to be the Interface returned by netroute.New or netroute.NewWithOptions, other decorators must wrap
+// the traced Interface.
+func (t *Tracer) Netroute(ctx context.Context, nr netroute.Interface) netroute.Interface {
+	return &tracedNetroute{
+		Interface: nr,
+		tracer:    t,
+		ctx:       ctx,
+	}
+}
+
+// NetrouteWithContext returns nr with its spans made children of the span in ctx. nr must be the Interface
+// returned by Tracer.Netroute, anything else is returned as is.
+func NetrouteWithContext(ctx context.Context, nr netroute.Interface) netroute.Interface {
+	if traced, ok := nr.(*tracedNetroute); ok {
+		return traced.WithContext(ctx)
+	}
+	return nr
+}
+
+// WithContext returns the wrapped Interface traced as a child of the span in ctx
+func (n *tracedNetroute) WithContext(ctx context.Context) netroute.Interface {
+	return n.tracer.Netroute(ctx, n.Interface)
+}
+
+// start starts the span of operation and returns the Interface that runs the call
+func (n *tracedNetroute) start(operation string, attrs ...attribute.KeyValue) (netroute.Interface, func(err *error)) {
+	ctx, end := n.tracer.start(n.ctx, "netroute."+operation, attrs)
+	if observable, ok := n.Interface.(observableNetroute); ok {
+		return observable.WithObserver(n.tracer.commands(ctx)), end
+	}
+	return n.Interface, end
+}
+
+func (n *tracedNetroute) GetNetRoutesAll() (routes []netroute.Route, err error) {
+	nr, end := n.start("GetNetRoutesAll")
+	defer end(&err)
+	return nr.GetNetRoutesAll()
+}
+
+func (n *tracedNetroute) GetNetRoutes(linkIndex int, destinationSubnet *net.IPNet) (routes []netroute.Route, err error) {
+	nr, end := n.start("GetNetRoutes", attrLinkIndex.Int(linkIndex), attrPrefix.String(destinationSubnet.String()))
+	defer end(&err)
+	return nr.GetNetRoutes(linkIndex, destinationSubnet)
+}
+
+func (n *tracedNetroute) GetNetRoutesFiltered(filter netroute.RouteFilter) (routes []netroute.Route, err error) {
+	nr, end := n.start("GetNetRoutesFiltered")
+	defer end(&err)
+	return nr.GetNetRoutesFiltered(filter)
+}
+
+func (n *tracedNetroute) NewNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) (err error) {
+	nr, end := n.start("NewNetRoute", attrLinkIndex.Int(linkIndex), attrPrefix.String(destinationSubnet.String()), nextHop(gatewayAddress))
+	defer end(&err)
+	return nr.NewNetRoute(linkIndex, destinationSubnet, gatewayAddress)
+}
+
+func (n *tracedNetroute) RemoveNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) (err error) {
+	nr, end := n.start("RemoveNetRoute", attrLinkIndex.Int(linkIndex), attrPrefix.String(destinationSubnet.String()), nextHop(gatewayAddress))
+	defer end(&err)
+	return nr.RemoveNetRoute(linkIndex, destinationSubnet, gatewayAddress)
+}
+
+func (n *tracedNetroute) ApplyRouteBatch(ops []netroute.RouteOp) (results []netroute.RouteOpResult, err error) {
+	nr, end := n.start("ApplyRouteBatch", attrOps.Int(len(ops)))
+	defer end(&err)
+	return nr.ApplyRouteBatch(ops)
+}
+
+func (n *tracedNetroute) NewNetRouteMultipath(route netroute.MultipathRoute) (err error) {
+	nr, end := n.start("NewNetRouteMultipath", multipathAttributes(route)...)
+	defer end(&err)
+	return nr.NewNetRouteMultipath(route)
+}
+
+func (n *tracedNetroute) RemoveNetRouteMultipath(route netroute.MultipathRoute) (err error) {
+	nr, end := n.start("RemoveNetRouteMultipath", multipathAttributes(route)...)
+	defer end(&err)
+	return nr.RemoveNetRouteMultipath(route)
+}
+
+func (n *tracedNetroute) NewBlackholeRoute(destinationSubnet *net.IPNet) (err error) {
+	nr, end := n.start("NewBlackholeRoute", attrPrefix.String(destinationSubnet.String()))
+	defer end(&err)
+	return nr.NewBlackholeRoute(destinationSubnet)
+}
+
+func (n *tracedNetroute) RemoveBlackholeRoute(destinationSubnet *net.IPNet) (err error) {
+	nr, end := n.start("RemoveBlackholeRoute", attrPrefix.String(destinationSubnet.String()))
+	defer end(&err)
+	return nr.RemoveBlackholeRoute(destinationSubnet)
+}
+
+func (n *tracedNetroute) GetLoopbackInterfaceIndex() (index int, err error) {
+	nr, end := n.start("GetLoopbackInterfaceIndex")
+	defer end(&err)
+	return nr.GetLoopbackInterfaceIndex()
+}
+
+func (n *tracedNetroute) GetNetIPAddresses(filter netroute.IPAddressFilter) (addresses []netroute.IPAddress, err error) {
+	nr, end := n.start("GetNetIPAddresses")
+	defer end(&err)
+	return nr.GetNetIPAddresses(filter)
+}
+
+func (n *tracedNetroute) NewNetIPAddress(linkIndex int, address net.IP, prefixLength int) (err error) {
+	nr, end := n.start("NewNetIPAddress", attrLinkIndex.Int(linkIndex), attrAddress.String(address.String()), attribute.Int("gonetsh.prefix_length", prefixLength))
+	defer end(&err)
+	return nr.NewNetIPAddress(linkIndex, address, prefixLength)
+}
+
+func (n *tracedNetroute) RemoveNetIPAddress(linkIndex int, address net.IP) (err error) {
+	nr, end := n.start("RemoveNetIPAddress", attrLinkIndex.Int(linkIndex), attrAddress.String(address.String()))
+	defer end(&err)
+	return nr.RemoveNetIPAddress(linkIndex, address)
+}
+
+func (n *tracedNetroute) GetNetAdapters() (adapters []netroute.NetAdapter, err error) {
+	nr, end := n.start("GetNetAdapters")
+	defer end(&err)
+	return nr.GetNetAdapters()
+}
+
+func (n *tracedNetroute) GetNetNats() (nats []netroute.NetNat, err error) {
+	nr, end := n.start("GetNetNats")
+	defer end(&err)
+	return nr.GetNetNats()
+}
+
+func (n *tracedNetroute) NewNetNat(name string, internalPrefix *net.IPNet) (err error) {
+	nr, end := n.start("NewNetNat", attrName.String(name), attrPrefix.String(internalPrefix.String()))
+	defer end(&err)
+	return nr.NewNetNat(name, internalPrefix)
+}
+
+func (n *tracedNetroute) RemoveNetNat(name string) (err error) {
+	nr, end := n.start("RemoveNetNat", attrName.String(name))
+	defer end(&err)
+	return nr.RemoveNetNat(name)
+}
+
+func (n *tracedNetroute) GetNetNatStaticMappings(natName string) (mappings []netroute.NetNatStaticMapping, err error) {
+	nr, end := n.start("GetNetNatStaticMappings", attrName.String(natName))
+	defer end(&err)
+	return nr.GetNetNatStaticMappings(natName)
+}
+
+func (n *tracedNetroute) EnsureNetNatStaticMapping(mapping netroute.NetNatStaticMapping) (changed bool, err error) {
+	nr, end := n.start("EnsureNetNatStaticMapping", attrName.String(mapping.NatName), attrAddress.String(mapping.ExternalIPAddress.String()))
+	defer end(&err)
+	return nr.EnsureNetNatStaticMapping(mapping)
+}
+
+func (n *tracedNetroute) RemoveNetNatStaticMapping(natName string, staticMappingID int) (err error) {
+	nr, end := n.start("RemoveNetNatStaticMapping", attrName.String(natName))
+	defer end(&err)
+	return nr.RemoveNetNatStaticMapping(natName, staticMappingID)
+}
+
+func (n *tracedNetroute) GetDNSCache() (entries []netroute.DNSCacheEntry, err error) {
+	nr, end := n.start("GetDNSCache")
+	defer end(&err)
+	return nr.GetDNSCache()
+}
+
+func (n *tracedNetroute) ClearDNSCache() (err error) {
+	nr, end := n.start("ClearDNSCache")
+	defer end(&err)
+	return nr.ClearDNSCache()
+}
+
+func (n *tracedNetroute) ResolveName(name string, recordType string, server net.IP) (records []netroute.DNSRecord, err error) {
+	nr, end := n.start("ResolveName", attrName.String(name))
+	defer end(&err)
+	return nr.ResolveName(name, recordType, server)
+}
+
+func (n *tracedNetroute) GetNetCompartments() (compartments []netroute.Compartment, err error) {
+	nr, end := n.start("GetNetCompartments")
+	defer end(&err)
+	return nr.GetNetCompartments()
+}
+
+// InCompartment traces the scoped Interface the same way
+func (n *tracedNetroute) InCompartment(id int) netroute.Interface {
+	return n.tracer.Netroute(n.ctx, n.Interface.InCompartment(id))
+}
+
+func multipathAttributes(route netroute.MultipathRoute) []attribute.KeyValue {
+	hops := make([]string, len(route.NextHops))
+	for i, hop := range route.NextHops {
+		hops[i] = nextHop(hop.GatewayAddress).Value.AsString()
+	}
+	return []attribute.KeyValue{attrPrefix.String(route.DestinationSubnet.String()), attrNextHop.StringSlice(hops)}
+}
@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeShell answers every script with stdout, or fails it with err
+type fakeShell struct {
+	stdout string
+	err    error
+}
+
+func (fs *fakeShell) Execute(cmd string) (string, string, error) {
+	return fs.stdout, "", fs.err
+}
+
+func (fs *fakeShell) Exit() {
+}
+
+func TestNetroute(t *testing.T) {
+	shell, err := netroute.NewWithOptions(netroute.Options{Shell: &fakeShell{}})
+	assert.NoError(t, err)
+	tracer, exporter := newTestTracer()
+	nr := tracer.Netroute(context.Background(), shell)
+
+	_, subnet, _ := net.ParseCIDR("10.244.1.0/24")
+	assert.NoError(t, nr.NewNetRoute(4, subnet, net.ParseIP("10.0.0.5")))
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	command, operation := spans[0], spans[1]
+	assert.Equal(t, "powershell", command.Name)
+	assert.Equal(t, operation.SpanContext.SpanID(), command.Parent.SpanID())
+	assert.Equal(t, "netroute.NewNetRoute", operation.Name)
+	assert.Equal(t, map[string]interface{}{
+		"gonetsh.link_index": int64(4),
+		"gonetsh.prefix":     "10.244.1.0/24",
+		"gonetsh.next_hop":   "10.0.0.5",
+	}, attributes(operation))
+}
+
+func TestNetrouteError(t *testing.T) {
+	shell, err := netroute.NewWithOptions(netroute.Options{Shell: &fakeShell{err: errors.New("Access is denied")}})
+	assert.NoError(t, err)
+	tracer, exporter := newTestTracer()
+	nr := tracer.Netroute(context.Background(), shell).InCompartment(2)
+
+	assert.Error(t, nr.ClearDNSCache())
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	command, operation := spans[0], spans[1]
+	assert.Equal(t, codes.Error, command.Status.Code)
+	assert.Equal(t, "netroute.ClearDNSCache", operation.Name)
+	assert.Equal(t, codes.Error, operation.Status.Code)
+	assert.Equal(t, "Access is denied", operation.Status.Description)
+}
+
+func TestMultipathAttributes(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.244.0.0/16")
+	attrs := multipathAttributes(netroute.MultipathRoute{
+		DestinationSubnet: subnet,
+		NextHops: []netroute.NextHop{
+			{LinkIndex: 4, GatewayAddress: net.ParseIP("10.0.0.5")},
+			{LinkIndex: 5},
+		},
+	})
+
+	assert.Equal(t, "10.244.0.0/16", attrs[0].Value.AsString())
+	assert.Equal(t, []string{"10.0.0.5", "on-link"}, attrs[1].Value.AsStringSlice())
+}
+
+func TestNetrouteWithContext(t *testing.T) {
+	shell, err := netroute.NewWithOptions(netroute.Options{Shell: &fakeShell{}})
+	assert.NoError(t, err)
+	tracer, exporter := newTestTracer()
+	nr := tracer.Netroute(context.Background(), shell)
+	request := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}, TraceFlags: trace.FlagsSampled})
+
+	_, subnet, _ := net.ParseCIDR("10.244.1.0/24")
+	assert.NoError(t, NetrouteWithContext(trace.ContextWithSpanContext(context.Background(), request), nr).NewNetRoute(4, subnet, nil))
+
+	spans := exporter.GetSpans()
+	operation := spans[len(spans)-1]
+	assert.Equal(t, "netroute.NewNetRoute", operation.Name)
+	assert.Equal(t, request.SpanID(), operation.Parent.SpanID())
+}
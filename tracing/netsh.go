@@ -0,0 +1,228 @@
+package tracing
+
+import (
+	"context"
+	"net"
+
+	netsh "github.com/rakelkar/gonetsh/netsh"
+	"github.com/rakelkar/gonetsh/observer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// observableNetsh is implemented by the netsh runner, it reports the commands of a single call
+type observableNetsh interface {
+	WithObserver(obs observer.Observer) netsh.Interface
+}
+
+// tracedNetsh creates a span for every call to the wrapped Interface
+type tracedNetsh struct {
+	netsh.Interface
+	tracer *Tracer
+	ctx    context.Context
+}
+
+// Netsh returns nsh traced by t, its spans are children of the span in ctx. ctx is fixed for the life of the
+// returned Interface, rebind a long lived one to the span of each request with NetshWithContext. The command spans
+// need nsh to be the Interface returned by netsh.New or netsh.NewWithOptions, other decorators must wrap the traced
+// Interface. Batches are returned as is and are not traced.
+func (t *Tracer) Netsh(ctx context.Context, nsh netsh.Interface) netsh.Interface {
+	return &tracedNetsh{
+		Interface: nsh,
+		tracer:    t,
+		ctx:       ctx,
+	}
+}
+
+// NetshWithContext returns nsh with its spans made children of the span in ctx. nsh must be the Interface
+// returned by Tracer.Netsh, anything else is returned as is.
+func NetshWithContext(ctx context.Context, nsh netsh.Interface) netsh.Interface {
+	if traced, ok := nsh.(*tracedNetsh); ok {
+		return traced.WithContext(ctx)
+	}
+	return nsh
+}
+
+// WithContext returns the wrapped Interface traced as a child of the span in ctx
+func (n *tracedNetsh) WithContext(ctx context.Context) netsh.Interface {
+	return n.tracer.Netsh(ctx, n.Interface)
+}
+
+// start starts the span of operation and returns the Interface that runs the call
+func (n *tracedNetsh) start(operation string, attrs ...attribute.KeyValue) (netsh.Interface, func(err *error)) {
+	ctx, end := n.tracer.start(n.ctx, "netsh."+operation, attrs)
+	if observable, ok := n.Interface.(observableNetsh); ok {
+		return observable.WithObserver(n.tracer.commands(ctx)), end
+	}
+	return n.Interface, end
+}
+
+func (n *tracedNetsh) EnsurePortProxyRule(args []string) (exists bool, err error) {
+	nsh, end := n.start("EnsurePortProxyRule", attrArgs.StringSlice(args))
+	defer end(&err)
+	return nsh.EnsurePortProxyRule(args)
+}
+
+func (n *tracedNetsh) DeletePortProxyRule(args []string) (err error) {
+	nsh, end := n.start("DeletePortProxyRule", attrArgs.StringSlice(args))
+	defer end(&err)
+	return nsh.DeletePortProxyRule(args)
+}
+
+func (n *tracedNetsh) GetPortProxyRules() (rules []netsh.PortProxyRule, err error) {
+	nsh, end := n.start("GetPortProxyRules")
+	defer end(&err)
+	return nsh.GetPortProxyRules()
+}
+
+func (n *tracedNetsh) DeleteIPAddress(args []string) (err error) {
+	nsh, end := n.start("DeleteIPAddress", attrArgs.StringSlice(args))
+	defer end(&err)
+	return nsh.DeleteIPAddress(args)
+}
+
+func (n *tracedNetsh) Restore(args []string) (err error) {
+	nsh, end := n.start("Restore", attrArgs.StringSlice(args))
+	defer end(&err)
+	return nsh.Restore(args)
+}
+
+func (n *tracedNetsh) GetDefaultGatewayIfaceName() (name string, err error) {
+	nsh, end := n.start("GetDefaultGatewayIfaceName")
+	defer end(&err)
+	return nsh.GetDefaultGatewayIfaceName()
+}
+
+func (n *tracedNetsh) GetInterfaces() (interfaces []netsh.Ipv4Interface, err error) {
+	nsh, end := n.start("GetInterfaces")
+	defer end(&err)
+	return nsh.GetInterfaces()
+}
+
+func (n *tracedNetsh) GetInterfaceByName(name string) (iface netsh.Ipv4Interface, err error) {
+	nsh, end := n.start("GetInterfaceByName", attrInterface.String(name))
+	defer end(&err)
+	return nsh.GetInterfaceByName(name)
+}
+
+func (n *tracedNetsh) GetInterfaceByIP(ipAddr string) (iface netsh.Ipv4Interface, err error) {
+	nsh, end := n.start("GetInterfaceByIP", attrAddress.String(ipAddr))
+	defer end(&err)
+	return nsh.GetInterfaceByIP(ipAddr)
+}
+
+func (n *tracedNetsh) EnableForwarding(iface string) (err error) {
+	nsh, end := n.start("EnableForwarding", attrInterface.String(iface))
+	defer end(&err)
+	return nsh.EnableForwarding(iface)
+}
+
+func (n *tracedNetsh) DisableForwarding(iface string) (err error) {
+	nsh, end := n.start("DisableForwarding", attrInterface.String(iface))
+	defer end(&err)
+	return nsh.DisableForwarding(iface)
+}
+
+func (n *tracedNetsh) GetForwarding(iface string) (enabled bool, err error) {
+	nsh, end := n.start("GetForwarding", attrInterface.String(iface))
+	defer end(&err)
+	return nsh.GetForwarding(iface)
+}
+
+func (n *tracedNetsh) GetIPAddresses(iface string) (addresses []*net.IPNet, err error) {
+	nsh, end := n.start("GetIPAddresses", attrInterface.String(iface))
+	defer end(&err)
+	return nsh.GetIPAddresses(iface)
+}
+
+func (n *tracedNetsh) AddIPAddress(iface string, address *net.IPNet) (err error) {
+	nsh, end := n.start("AddIPAddress", attrInterface.String(iface), attrAddress.String(address.String()))
+	defer end(&err)
+	return nsh.AddIPAddress(iface, address)
+}
+
+func (n *tracedNetsh) RemoveIPAddress(iface string, ip net.IP) (err error) {
+	nsh, end := n.start("RemoveIPAddress", attrInterface.String(iface), attrAddress.String(ip.String()))
+	defer end(&err)
+	return nsh.RemoveIPAddress(iface, ip)
+}
+
+func (n *tracedNetsh) SetDNSServer(iface string, dns string) (err error) {
+	nsh, end := n.start("SetDNSServer", attrInterface.String(iface), attrDNSServers.StringSlice([]string{dns}))
+	defer end(&err)
+	return nsh.SetDNSServer(iface, dns)
+}
+
+func (n *tracedNetsh) GetDNSClientConfig(iface string) (config netsh.DNSClientConfig, err error) {
+	nsh, end := n.start("GetDNSClientConfig", attrInterface.String(iface))
+	defer end(&err)
+	return nsh.GetDNSClientConfig(iface)
+}
+
+func (n *tracedNetsh) SetDNSServers(iface string, servers []net.IP) (err error) {
+	addresses := make([]string, len(servers))
+	for i, server := range servers {
+		addresses[i] = server.String()
+	}
+	nsh, end := n.start("SetDNSServers", attrInterface.String(iface), attrDNSServers.StringSlice(addresses))
+	defer end(&err)
+	return nsh.SetDNSServers(iface, servers)
+}
+
+func (n *tracedNetsh) ResetDNSToDHCP(iface string) (err error) {
+	nsh, end := n.start("ResetDNSToDHCP", attrInterface.String(iface))
+	defer end(&err)
+	return nsh.ResetDNSToDHCP(iface)
+}
+
+func (n *tracedNetsh) SetDNSSuffix(iface string, suffix string) (err error) {
+	nsh, end := n.start("SetDNSSuffix", attrInterface.String(iface), attrDNSSuffix.String(suffix))
+	defer end(&err)
+	return nsh.SetDNSSuffix(iface, suffix)
+}
+
+func (n *tracedNetsh) SetSuffixSearchList(suffixes []string) (err error) {
+	nsh, end := n.start("SetSuffixSearchList", attrDNSSearch.StringSlice(suffixes))
+	defer end(&err)
+	return nsh.SetSuffixSearchList(suffixes)
+}
+
+func (n *tracedNetsh) SetDNSRegistration(iface string, register bool, useSuffix bool) (err error) {
+	nsh, end := n.start("SetDNSRegistration", attrInterface.String(iface))
+	defer end(&err)
+	return nsh.SetDNSRegistration(iface, register, useSuffix)
+}
+
+func (n *tracedNetsh) GetCompartments() (compartments []netsh.Compartment, err error) {
+	nsh, end := n.start("GetCompartments")
+	defer end(&err)
+	return nsh.GetCompartments()
+}
+
+// InCompartment traces the scoped Interface the same way
+func (n *tracedNetsh) InCompartment(id int) netsh.Interface {
+	return n.tracer.Netsh(n.ctx, n.Interface.InCompartment(id))
+}
+
+func (n *tracedNetsh) GetFirewallRules(name string) (rules []netsh.FirewallRule, err error) {
+	nsh, end := n.start("GetFirewallRules", attrName.String(name))
+	defer end(&err)
+	return nsh.GetFirewallRules(name)
+}
+
+func (n *tracedNetsh) AddFirewallRule(rule netsh.FirewallRule) (err error) {
+	nsh, end := n.start("AddFirewallRule", attrName.String(rule.Name))
+	defer end(&err)
+	return nsh.AddFirewallRule(rule)
+}
+
+func (n *tracedNetsh) DeleteFirewallRule(name string) (err error) {
+	nsh, end := n.start("DeleteFirewallRule", attrName.String(name))
+	defer end(&err)
+	return nsh.DeleteFirewallRule(name)
+}
+
+func (n *tracedNetsh) RunScript(script string) (output []byte, err error) {
+	nsh, end := n.start("RunScript")
+	defer end(&err)
+	return nsh.RunScript(script)
+}
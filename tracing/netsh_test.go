@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	netsh "github.com/rakelkar/gonetsh/netsh"
+	fakenetsh "github.com/rakelkar/gonetsh/netsh/testing"
+	"github.com/rakelkar/gonetsh/observer"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestNetsh(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte("Forwarding                         : enabled\r\n"), nil, nil
+			},
+			func() ([]byte, []byte, error) {
+				return []byte("Element not found."), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+		},
+	}
+	fakeExec := fakeexec.FakeExec{}
+	for range fakeCmd.CombinedOutputScript {
+		fakeExec.CommandScript = append(fakeExec.CommandScript, func(cmd string, args ...string) exec.Cmd {
+			return fakeexec.InitFakeCmd(&fakeCmd, cmd, args...)
+		})
+	}
+	runner, err := netsh.NewWithOptions(netsh.Options{Exec: &fakeExec})
+	assert.NoError(t, err)
+	tracer, exporter := newTestTracer()
+	nsh := tracer.Netsh(context.Background(), runner)
+
+	enabled, err := nsh.InCompartment(2).GetForwarding("Ethernet")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Error(t, nsh.EnableForwarding("Ethernet"))
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 4, len(spans))
+
+	command, operation := spans[0], spans[1]
+	assert.Equal(t, "netsh", command.Name)
+	assert.Equal(t, operation.SpanContext.SpanID(), command.Parent.SpanID())
+	assert.Equal(t, []string{"interface", "ipv4", "show", "interface", "Ethernet", "compartment=2"}, attributes(command)["process.command_args"])
+	assert.Equal(t, "netsh.GetForwarding", operation.Name)
+	assert.Equal(t, "Ethernet", attributes(operation)["gonetsh.interface"])
+	assert.Equal(t, codes.Unset, operation.Status.Code)
+
+	command, operation = spans[2], spans[3]
+	assert.Equal(t, operation.SpanContext.SpanID(), command.Parent.SpanID())
+	assert.Equal(t, int64(1), attributes(command)["process.exit_code"])
+	assert.Equal(t, codes.Error, command.Status.Code)
+	assert.Equal(t, "netsh.EnableForwarding", operation.Name)
+	assert.Equal(t, codes.Error, operation.Status.Code)
+	assert.Equal(t, 1, len(operation.Events))
+}
+
+func TestNetshRedacting(t *testing.T) {
+	fakeCmd := fakeexec.FakeCmd{
+		CombinedOutputScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				return []byte("Cannot resolve secret-host."), nil, &fakeexec.FakeExitError{Status: 1}
+			},
+		},
+	}
+	fakeExec := fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			func(cmd string, args ...string) exec.Cmd { return fakeexec.InitFakeCmd(&fakeCmd, cmd, args...) },
+		},
+	}
+	runner, err := netsh.NewWithOptions(netsh.Options{Exec: &fakeExec})
+	assert.NoError(t, err)
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := NewWithOptions(Options{
+		Provider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		Redact:   observer.RedactMatching(regexp.MustCompile(`secret\S*`)),
+	})
+	nsh := tracer.Netsh(context.Background(), runner)
+
+	args := []string{"interface", "portproxy", "delete", "v4tov4", "listenport=80", "listenaddress=secret-host"}
+	assert.Error(t, nsh.DeletePortProxyRule(args))
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	command, operation := spans[0], spans[1]
+	assert.Equal(t, "listenaddress=[REDACTED]", attributes(command)["process.command_args"].([]string)[5])
+	assert.Equal(t, "listenaddress=[REDACTED]", attributes(operation)["gonetsh.args"].([]string)[5])
+	assert.Equal(t, "listenaddress=secret-host", args[5])
+	for _, span := range spans {
+		assert.NotContains(t, span.Status.Description, "secret")
+		for _, event := range span.Events {
+			for _, attr := range event.Attributes {
+				assert.NotContains(t, attr.Value.Emit(), "secret")
+			}
+		}
+	}
+}
+
+func TestNetshWithoutObserver(t *testing.T) {
+	tracer, exporter := newTestTracer()
+	nsh := tracer.Netsh(context.Background(), fakenetsh.NewFake())
+
+	_, err := nsh.GetInterfaces()
+	assert.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "netsh.GetInterfaces", spans[0].Name)
+}
+
+func TestNetshWithContext(t *testing.T) {
+	tracer, exporter := newTestTracer()
+	nsh := tracer.Netsh(context.Background(), fakenetsh.NewFake())
+	request := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	_, err := NetshWithContext(trace.ContextWithSpanContext(context.Background(), request), nsh).GetInterfaces()
+	assert.NoError(t, err)
+	_, err = nsh.GetInterfaces()
+	assert.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, request.TraceID(), spans[0].SpanContext.TraceID())
+	assert.Equal(t, request.SpanID(), spans[0].Parent.SpanID())
+	assert.False(t, spans[1].Parent.IsValid())
+
+	runner := fakenetsh.NewFake()
+	assert.Equal(t, runner, NetshWithContext(context.Background(), runner))
+}
@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/rakelkar/gonetsh/observer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName string = "github.com/rakelkar/gonetsh/tracing"
+)
+
+// Attributes of the operation spans
+const (
+	attrInterface  = attribute.Key("gonetsh.interface")
+	attrLinkIndex  = attribute.Key("gonetsh.link_index")
+	attrPrefix     = attribute.Key("gonetsh.prefix")
+	attrNextHop    = attribute.Key("gonetsh.next_hop")
+	attrAddress    = attribute.Key("gonetsh.address")
+	attrName       = attribute.Key("gonetsh.name")
+	attrArgs       = attribute.Key("gonetsh.args")
+	attrDNSServers = attribute.Key("gonetsh.dns_servers")
+	attrDNSSuffix  = attribute.Key("gonetsh.dns_suffix")
+	attrDNSSearch  = attribute.Key("gonetsh.dns_search_list")
+	attrOps        = attribute.Key("gonetsh.ops")
+)
+
+// Attributes of the command spans
+const (
+	attrProgram  = attribute.Key("process.executable.name")
+	attrCmdArgs  = attribute.Key("process.command_args")
+	attrExitCode = attribute.Key("process.exit_code")
+	attrScript   = attribute.Key("gonetsh.script")
+)
+
+// Tracer creates a span for every call to a traced netsh or netroute Interface, with a child span for every
+// netsh.exe or powershell command the call ran
+type Tracer struct {
+	tracer trace.Tracer
+	redact observer.Redactor
+}
+
+// Options configure a Tracer
+type Options struct {
+	// Provider creates the spans, nil uses the global one
+	Provider trace.TracerProvider
+	// Redact, if set, rewrites the arguments, scripts, names and error messages recorded on spans, the same way
+	// observer.Redacting does for events
+	Redact observer.Redactor
+}
+
+// New returns a Tracer that creates its spans with provider, a nil provider uses the global one
+func New(provider trace.TracerProvider) *Tracer {
+	return NewWithOptions(Options{Provider: provider})
+}
+
+// NewWithOptions returns a Tracer configured by opts
+func NewWithOptions(opts Options) *Tracer {
+	provider := opts.Provider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{
+		tracer: provider.Tracer(instrumentationName),
+		redact: opts.Redact,
+	}
+}
+
+// start starts the span of an operation, end must be called with its error
+func (t *Tracer) start(ctx context.Context, name string, attrs []attribute.KeyValue) (context.Context, func(err *error)) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(t.redactAttributes(attrs)...))
+	return ctx, func(err *error) {
+		endSpan(span, t.redactError(*err))
+	}
+}
+
+// redactAttributes runs the Redactor over the string and string slice attributes of an operation span
+func (t *Tracer) redactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if t.redact == nil {
+		return attrs
+	}
+	redacted := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		switch attr.Value.Type() {
+		case attribute.STRING:
+			attr = attr.Key.String(t.redact(attr.Value.AsString()))
+		case attribute.STRINGSLICE:
+			values := attr.Value.AsStringSlice()
+			for j, value := range values {
+				values[j] = t.redact(value)
+			}
+			attr = attr.Key.StringSlice(values)
+		}
+		redacted[i] = attr
+	}
+	return redacted
+}
+
+// redactError runs the Redactor over the message of err
+func (t *Tracer) redactError(err error) error {
+	if t.redact == nil || err == nil {
+		return err
+	}
+	return errors.New(t.redact(err.Error()))
+}
+
+// commands returns an Observer that turns every command into a child span of the span in ctx
+func (t *Tracer) commands(ctx context.Context) observer.Observer {
+	spans := observer.Func(func(event observer.Event) {
+		attrs := []attribute.KeyValue{
+			attrProgram.String(event.Program),
+			attrCmdArgs.StringSlice(event.Args),
+			attrExitCode.Int(event.ExitStatus),
+		}
+		if event.Input != "" {
+			attrs = append(attrs, attrScript.String(event.Input))
+		}
+
+		_, span := t.tracer.Start(ctx, event.Program, trace.WithTimestamp(event.Start), trace.WithAttributes(attrs...))
+		endSpan(span, event.Err, trace.WithTimestamp(event.Start.Add(event.Duration)))
+	})
+	if t.redact != nil {
+		return observer.Redacting(spans, t.redact)
+	}
+	return spans
+}
+
+func endSpan(span trace.Span, err error, options ...trace.SpanEndOption) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(options...)
+}
+
+func nextHop(gatewayAddress net.IP) attribute.KeyValue {
+	if gatewayAddress == nil {
+		return attrNextHop.String("on-link")
+	}
+	return attrNextHop.String(gatewayAddress.String())
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rakelkar/gonetsh/observer"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestTracer() (*Tracer, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return New(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))), exporter
+}
+
+func attributes(span tracetest.SpanStub) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	for _, attr := range span.Attributes {
+		attrs[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	return attrs
+}
+
+func TestCommands(t *testing.T) {
+	tracer, exporter := newTestTracer()
+	start := time.Now()
+
+	ctx, end := tracer.start(context.Background(), "netsh.RunScript", nil)
+	tracer.commands(ctx).Observe(observer.Event{
+		Program:    "netsh",
+		Args:       []string{"-f", "script.txt"},
+		Input:      "interface ipv4 show interfaces\r\n",
+		Start:      start,
+		Duration:   time.Second,
+		ExitStatus: 1,
+		Err:        errors.New("exit status 1"),
+	})
+	err := errors.New("exit status 1")
+	end(&err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	command, operation := spans[0], spans[1]
+	assert.Equal(t, "netsh", command.Name)
+	assert.Equal(t, operation.SpanContext.SpanID(), command.Parent.SpanID())
+	assert.Equal(t, start, command.StartTime)
+	assert.Equal(t, start.Add(time.Second), command.EndTime)
+	assert.Equal(t, map[string]interface{}{
+		"process.executable.name": "netsh",
+		"process.command_args":    []string{"-f", "script.txt"},
+		"process.exit_code":       int64(1),
+		"gonetsh.script":          "interface ipv4 show interfaces\r\n",
+	}, attributes(command))
+	assert.Equal(t, codes.Error, command.Status.Code)
+	assert.Equal(t, 1, len(command.Events))
+
+	assert.Equal(t, "netsh.RunScript", operation.Name)
+	assert.Equal(t, codes.Error, operation.Status.Code)
+	assert.Equal(t, "exit status 1", operation.Status.Description)
+}
@@ -0,0 +1,283 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+)
+
+// ErrDone is returned by every call on a Tx after it was committed or rolled back
+var ErrDone = errors.New("transaction already committed or rolled back")
+
+// Tx applies host network changes through netsh and netroute, recording the inverse of each successful change
+// so a half-done setup can be rolled back. It is goroutine-safe, changes are applied one at a time.
+type Tx struct {
+	mu       sync.Mutex
+	netsh    netsh.Interface
+	netroute netroute.Interface
+	undo     []step
+	done     bool
+}
+
+// step is the inverse of a change, description names the change it reverts
+type step struct {
+	description string
+	undo        func() error
+}
+
+// New returns an empty Tx. Either interface may be nil if no changes of its kind are made.
+func New(nsh netsh.Interface, nr netroute.Interface) *Tx {
+	return &Tx{
+		netsh:    nsh,
+		netroute: nr,
+	}
+}
+
+// Run calls fn with a new Tx, rolling it back if fn returns an error and committing it otherwise
+func Run(nsh netsh.Interface, nr netroute.Interface, fn func(tx *Tx) error) error {
+	tx := New(nsh, nr)
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%v, %v", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Do applies a custom change, undo is recorded as its inverse if do succeeds
+func (tx *Tx) Do(description string, do func() error, undo func() error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+	if err := do(); err != nil {
+		return err
+	}
+	tx.record(description, undo)
+	return nil
+}
+
+// Commit ends the transaction, keeping every change
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+	tx.done = true
+	tx.undo = nil
+	return nil
+}
+
+// Rollback ends the transaction, reverting the changes in reverse order. Every inverse is attempted even if an
+// earlier one fails, the failures are reported together.
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+	tx.done = true
+
+	var failures []string
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		if err := tx.undo[i].undo(); err != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", tx.undo[i].description, err))
+		}
+	}
+	tx.undo = nil
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to roll back: %v", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// EnableForwarding enables forwarding on iface, rollback disables it again if it was off
+func (tx *Tx) EnableForwarding(iface string) error {
+	return tx.setForwarding(iface, true)
+}
+
+// DisableForwarding disables forwarding on iface, rollback enables it again if it was on
+func (tx *Tx) DisableForwarding(iface string) error {
+	return tx.setForwarding(iface, false)
+}
+
+func (tx *Tx) setForwarding(iface string, enabled bool) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+
+	previous, err := tx.netsh.GetForwarding(iface)
+	if err != nil {
+		return err
+	}
+
+	enable, disable := func() error { return tx.netsh.EnableForwarding(iface) }, func() error { return tx.netsh.DisableForwarding(iface) }
+	do, undo := enable, disable
+	if !enabled {
+		do, undo = disable, enable
+	}
+	if err := do(); err != nil {
+		return err
+	}
+	if previous != enabled {
+		tx.record(fmt.Sprintf("set forwarding %v on [%v]", enabled, iface), undo)
+	}
+	return nil
+}
+
+// AddIPAddress adds a static address to iface, rollback removes it
+func (tx *Tx) AddIPAddress(iface string, address *net.IPNet) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+	if err := tx.netsh.AddIPAddress(iface, address); err != nil {
+		return err
+	}
+	tx.record(fmt.Sprintf("add address %v to [%v]", address, iface), func() error {
+		return tx.netsh.RemoveIPAddress(iface, address.IP)
+	})
+	return nil
+}
+
+// RemoveIPAddress removes a static address from iface, rollback adds it back with its previous prefix
+func (tx *Tx) RemoveIPAddress(iface string, ip net.IP) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+
+	addresses, err := tx.netsh.GetIPAddresses(iface)
+	if err != nil {
+		return err
+	}
+	var previous *net.IPNet
+	for _, address := range addresses {
+		if address.IP.Equal(ip) {
+			previous = address
+		}
+	}
+	if previous == nil {
+		return fmt.Errorf("address %v not found on [%v]", ip, iface)
+	}
+
+	if err := tx.netsh.RemoveIPAddress(iface, ip); err != nil {
+		return err
+	}
+	tx.record(fmt.Sprintf("remove address %v from [%v]", ip, iface), func() error {
+		return tx.netsh.AddIPAddress(iface, previous)
+	})
+	return nil
+}
+
+// SetDNSServers replaces the DNS servers of iface, rollback restores the previous static servers or DHCP
+func (tx *Tx) SetDNSServers(iface string, servers []net.IP) error {
+	return tx.setDNS(iface, fmt.Sprintf("set dns servers %v on [%v]", servers, iface), func() error {
+		return tx.netsh.SetDNSServers(iface, servers)
+	})
+}
+
+// ResetDNSToDHCP reverts iface to the DHCP provided DNS servers, rollback restores the previous static servers
+func (tx *Tx) ResetDNSToDHCP(iface string) error {
+	return tx.setDNS(iface, fmt.Sprintf("reset dns to dhcp on [%v]", iface), func() error {
+		return tx.netsh.ResetDNSToDHCP(iface)
+	})
+}
+
+func (tx *Tx) setDNS(iface string, description string, do func() error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+
+	previous, err := tx.netsh.GetDNSClientConfig(iface)
+	if err != nil {
+		return err
+	}
+	if err := do(); err != nil {
+		return err
+	}
+	tx.record(description, func() error {
+		if previous.Dhcp || len(previous.Servers) == 0 {
+			return tx.netsh.ResetDNSToDHCP(iface)
+		}
+		return tx.netsh.SetDNSServers(iface, previous.Servers)
+	})
+	return nil
+}
+
+// NewNetRoute creates a route, rollback removes it
+func (tx *Tx) NewNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+	if err := tx.netroute.NewNetRoute(linkIndex, destinationSubnet, gatewayAddress); err != nil {
+		return err
+	}
+	tx.record(fmt.Sprintf("add route %v via %v on %v", destinationSubnet, gatewayAddress, linkIndex), func() error {
+		return tx.netroute.RemoveNetRoute(linkIndex, destinationSubnet, gatewayAddress)
+	})
+	return nil
+}
+
+// RemoveNetRoute removes a route, rollback creates it again with its previous metric
+func (tx *Tx) RemoveNetRoute(linkIndex int, destinationSubnet *net.IPNet, gatewayAddress net.IP) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrDone
+	}
+
+	routes, err := tx.netroute.GetNetRoutes(linkIndex, destinationSubnet)
+	if err != nil {
+		return err
+	}
+	removed := netroute.Route{LinkIndex: linkIndex, DestinationSubnet: destinationSubnet, GatewayAddress: gatewayAddress}
+	metric := 0
+	for _, route := range routes {
+		if route.Equal(removed) {
+			metric = route.RouteMetric
+		}
+	}
+
+	if err := tx.netroute.RemoveNetRoute(linkIndex, destinationSubnet, gatewayAddress); err != nil {
+		return err
+	}
+	tx.record(fmt.Sprintf("remove route %v via %v on %v", destinationSubnet, gatewayAddress, linkIndex), func() error {
+		return tx.netroute.NewNetRouteMultipath(netroute.MultipathRoute{
+			DestinationSubnet: destinationSubnet,
+			NextHops:          []netroute.NextHop{{LinkIndex: linkIndex, GatewayAddress: gatewayAddress, RouteMetric: metric}},
+		})
+	})
+	return nil
+}
+
+// record appends an inverse, must be called with mu held
+func (tx *Tx) record(description string, undo func() error) {
+	tx.undo = append(tx.undo, step{description: description, undo: undo})
+}
@@ -0,0 +1,158 @@
+package transaction
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	netroute "github.com/rakelkar/gonetsh/netroute"
+	fakenetroute "github.com/rakelkar/gonetsh/netroute/testing"
+	netsh "github.com/rakelkar/gonetsh/netsh"
+	fakenetsh "github.com/rakelkar/gonetsh/netsh/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRecording() (*[]string, *fakenetsh.RecordingNetsh, *fakenetroute.RecordingNetroute) {
+	calls := &[]string{}
+	return calls, fakenetsh.NewRecording(calls), fakenetroute.NewRecording(calls)
+}
+
+func TestRunRollsBackInReverseOrder(t *testing.T) {
+	calls, nsh, nr := newRecording()
+	nsh.DNS["Ethernet"] = netsh.DNSClientConfig{Servers: []net.IP{net.ParseIP("10.0.0.10")}}
+	_, pods, _ := net.ParseCIDR("10.244.0.0/16")
+	_, services, _ := net.ParseCIDR("10.96.0.0/12")
+	nr.Fail["add route 4 10.96.0.0/12 10.0.0.1"] = errors.New("element not found")
+
+	address := &net.IPNet{IP: net.ParseIP("10.0.0.5").To4(), Mask: net.CIDRMask(24, 32)}
+	err := Run(nsh, nr, func(tx *Tx) error {
+		if err := tx.EnableForwarding("Ethernet"); err != nil {
+			return err
+		}
+		if err := tx.AddIPAddress("Ethernet", address); err != nil {
+			return err
+		}
+		if err := tx.SetDNSServers("Ethernet", []net.IP{net.ParseIP("1.1.1.1")}); err != nil {
+			return err
+		}
+		if err := tx.NewNetRoute(4, pods, net.ParseIP("10.0.0.1")); err != nil {
+			return err
+		}
+		return tx.NewNetRoute(4, services, net.ParseIP("10.0.0.1"))
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "element not found")
+	assert.Equal(t, []string{
+		"enable forwarding Ethernet",
+		"add address Ethernet 10.0.0.5/24",
+		"set dns Ethernet [1.1.1.1]",
+		"add route 4 10.244.0.0/16 10.0.0.1",
+		"add route 4 10.96.0.0/12 10.0.0.1",
+		"remove route 4 10.244.0.0/16 10.0.0.1",
+		"set dns Ethernet [10.0.0.10]",
+		"remove address Ethernet 10.0.0.5",
+		"disable forwarding Ethernet",
+	}, *calls)
+}
+
+func TestRunCommits(t *testing.T) {
+	calls, nsh, nr := newRecording()
+
+	err := Run(nsh, nr, func(tx *Tx) error {
+		return tx.EnableForwarding("Ethernet")
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"enable forwarding Ethernet"}, *calls)
+}
+
+func TestRollbackKeepsPreviousState(t *testing.T) {
+	calls, nsh, nr := newRecording()
+	nsh.Forwarding["Ethernet"] = true
+	nsh.DNS["Ethernet"] = netsh.DNSClientConfig{Dhcp: true, Servers: []net.IP{net.ParseIP("168.63.129.16")}}
+	nsh.Addresses["Ethernet"] = []*net.IPNet{{IP: net.ParseIP("10.0.0.6").To4(), Mask: net.CIDRMask(20, 32)}}
+	_, pods, _ := net.ParseCIDR("10.244.0.0/16")
+	nr.Routes = []netroute.Route{{LinkIndex: 4, DestinationSubnet: pods, GatewayAddress: net.ParseIP("10.0.0.1"), RouteMetric: 42}}
+
+	tx := New(nsh, nr)
+	assert.NoError(t, tx.EnableForwarding("Ethernet"))
+	assert.NoError(t, tx.SetDNSServers("Ethernet", []net.IP{net.ParseIP("1.1.1.1")}))
+	assert.NoError(t, tx.RemoveIPAddress("Ethernet", net.ParseIP("10.0.0.6")))
+	assert.Error(t, tx.RemoveIPAddress("Ethernet", net.ParseIP("10.0.0.7")))
+	assert.NoError(t, tx.RemoveNetRoute(4, pods, net.ParseIP("10.0.0.1")))
+	assert.NoError(t, tx.Rollback())
+
+	assert.Equal(t, []string{
+		"enable forwarding Ethernet",
+		"set dns Ethernet [1.1.1.1]",
+		"remove address Ethernet 10.0.0.6",
+		"remove route 4 10.244.0.0/16 10.0.0.1",
+		"add route 4 10.244.0.0/16 10.0.0.1 metric 42",
+		"add address Ethernet 10.0.0.6/20",
+		"reset dns Ethernet",
+	}, *calls)
+
+	assert.Equal(t, ErrDone, tx.Rollback())
+	assert.Equal(t, ErrDone, tx.Commit())
+	assert.Equal(t, ErrDone, tx.EnableForwarding("Ethernet"))
+}
+
+func TestRollbackAttemptsEveryInverse(t *testing.T) {
+	calls, nsh, nr := newRecording()
+	nsh.Fail["disable forwarding a"] = errors.New("access denied")
+
+	tx := New(nsh, nr)
+	assert.NoError(t, tx.EnableForwarding("a"))
+	assert.NoError(t, tx.EnableForwarding("b"))
+	assert.NoError(t, tx.Do("custom", func() error { return nil }, func() error { return errors.New("custom failed") }))
+	assert.Error(t, tx.Do("failing", func() error { return errors.New("nope") }, nil))
+
+	err := tx.Rollback()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "custom failed")
+	assert.Contains(t, err.Error(), "access denied")
+	assert.Equal(t, []string{
+		"enable forwarding a",
+		"enable forwarding b",
+		"disable forwarding b",
+		"disable forwarding a",
+	}, *calls)
+}
+
+// scriptShell answers queries with the output of get-netroute and logs every powershell script
+type scriptShell struct {
+	stdout  string
+	scripts []string
+}
+
+func (s *scriptShell) Execute(cmd string) (string, string, error) {
+	s.scripts = append(s.scripts, cmd)
+	if strings.HasPrefix(cmd, "get-netroute") {
+		return s.stdout, "", nil
+	}
+	return "", "", nil
+}
+
+func (s *scriptShell) Exit() {
+}
+
+func TestRollbackRestoresRouteMetricFromHost(t *testing.T) {
+	shell := &scriptShell{stdout: `
+ifIndex DestinationPrefix                              NextHop                                  RouteMetric ifMetric PolicyStore
+------- -----------------                              -------                                  ----------- -------- -----------
+4       10.244.0.0/16                                  10.0.0.2                                         256 15       ActiveStore
+4       10.244.0.0/16                                  10.0.0.1                                          42 15       ActiveStore`}
+	nr, err := netroute.NewWithOptions(netroute.Options{Shell: shell})
+	assert.NoError(t, err)
+	_, pods, _ := net.ParseCIDR("10.244.0.0/16")
+
+	tx := New(fakenetsh.NewFake(), nr)
+	assert.NoError(t, tx.RemoveNetRoute(4, pods, net.ParseIP("10.0.0.1")))
+	assert.NoError(t, tx.Rollback())
+
+	last := shell.scripts[len(shell.scripts)-1]
+	assert.Contains(t, last, "new-netroute -InterfaceIndex 4 -DestinationPrefix 10.244.0.0/16")
+	assert.Contains(t, last, "-RouteMetric 42")
+}